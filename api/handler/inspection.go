@@ -1,57 +1,363 @@
 package handler
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	clusterfile "inspection-service/cluster/file"
+	"inspection-service/config"
 	"inspection-service/service/inspection"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/sunshineOfficial/golib/gohttp/gorouter"
-	"github.com/sunshineOfficial/golib/pagination"
 )
 
+const idempotencyKeyHeader = "Idempotency-Key"
+
 // GetAllInspections godoc
 // @Summary List inspections
-// @Description Returns all inspections.
+// @Description Returns inspections matching the filters as an array. Kept for clients written before /v2/inspections,
+// @Description which adds cursor pagination, sort keys and the total count. List filters take comma-separated values.
+// @Description Dates are RFC 3339 timestamps or YYYY-MM-DD dates in Moscow time; ranges include From and exclude To.
 // @Tags inspections
 // @Produce json
+// @Deprecated
 // @Param limit query int false "Maximum number of items to return; 0 means no limit"
 // @Param offset query int false "Number of items to skip"
 // @Param sort query string false "Sort by InspectAt direction: asc or desc"
+// @Param status query string false "Statuses, e.g. 3,5"
+// @Param type query string false "Inspection types"
+// @Param resolution query string false "Resolutions"
+// @Param taskID query string false "Task IDs"
+// @Param deviceID query int false "Inspected device ID"
+// @Param inspectAtFrom query string false "Inspected at or after"
+// @Param inspectAtTo query string false "Inspected before"
+// @Param energyActionAtFrom query string false "Energy limited or resumed at or after"
+// @Param energyActionAtTo query string false "Energy limited or resumed before"
+// @Param createdAtFrom query string false "Created at or after"
+// @Param createdAtTo query string false "Created before"
+// @Param isRestrictionChecked query bool false "Restriction was checked"
+// @Param isViolationDetected query bool false "Violation was detected"
+// @Param isExpenseAvailable query bool false "Consumption after limitation was detected"
+// @Param isUnauthorizedConsumers query bool false "Unauthorized consumers were detected"
 // @Success 200 {array} inspection.Inspection
-// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 400 {object} api.ValidationErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections [get]
 func GetAllInspections(s *inspection.Service) gorouter.Handler {
 	return func(c gorouter.Context) error {
-		var vars inspectionListQueryVars
-		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read query params: %w", err)
+		list, err := readInspectionList(c, s)
+		if err != nil {
+			return err
 		}
 
-		response, err := s.GetAll(c.Ctx(), vars.Pagination(), vars.Sort, clusterfile.NewForwardedHeaders(c.Request()))
+		return c.WriteJson(http.StatusOK, list.Items)
+	}
+}
+
+// ListInspections godoc
+// @Summary List inspections
+// @Description Returns a page of inspections matching the filters with their total count.
+// @Description The next page is requested with NextCursor and the same sort; it is absent on the last page.
+// @Description offset may be used instead of cursor. List filters take comma-separated values.
+// @Description Dates are RFC 3339 timestamps or YYYY-MM-DD dates in Moscow time; ranges include From and exclude To.
+// @Tags inspections
+// @Produce json
+// @Param cursor query string false "NextCursor of the previous page; empty for the first page"
+// @Param offset query int false "Number of items to skip; cannot be combined with cursor"
+// @Param limit query int false "Maximum number of items to return; 0 means no limit"
+// @Param sortBy query string false "Sort key: id, created_at, updated_at, or inspect_at; inspect_at when only sort is given, id otherwise"
+// @Param sort query string false "Sort direction: asc or desc"
+// @Param status query string false "Statuses, e.g. 3,5"
+// @Param type query string false "Inspection types"
+// @Param resolution query string false "Resolutions"
+// @Param taskID query string false "Task IDs"
+// @Param deviceID query int false "Inspected device ID"
+// @Param inspectAtFrom query string false "Inspected at or after"
+// @Param inspectAtTo query string false "Inspected before"
+// @Param energyActionAtFrom query string false "Energy limited or resumed at or after"
+// @Param energyActionAtTo query string false "Energy limited or resumed before"
+// @Param createdAtFrom query string false "Created at or after"
+// @Param createdAtTo query string false "Created before"
+// @Param isRestrictionChecked query bool false "Restriction was checked"
+// @Param isViolationDetected query bool false "Violation was detected"
+// @Param isExpenseAvailable query bool false "Consumption after limitation was detected"
+// @Param isUnauthorizedConsumers query bool false "Unauthorized consumers were detected"
+// @Success 200 {object} inspection.InspectionList
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /v2/inspections [get]
+func ListInspections(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		list, err := readInspectionList(c, s)
 		if err != nil {
-			return fmt.Errorf("failed to get all inspections: %w", err)
+			return err
 		}
 
-		return c.WriteJson(http.StatusOK, response)
+		return c.WriteJson(http.StatusOK, list)
 	}
 }
 
-type inspectionListQueryVars struct {
-	Limit  int                      `query:"limit"`
+func readInspectionList(c gorouter.Context, s *inspection.Service) (inspection.InspectionList, error) {
+	filter, err := readFilter(c)
+	if err != nil {
+		return inspection.InspectionList{}, err
+	}
+
+	var pageVars pageQueryVars
+	if err = c.Vars(&pageVars); err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("failed to read pagination: %w: %w", inspection.ErrValidation, err)
+	}
+
+	list, err := s.GetAll(c.Ctx(), filter, pageVars.Page(), clusterfile.NewForwardedHeaders(c.Request()))
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("failed to get all inspections: %w", err)
+	}
+
+	return list, nil
+}
+
+// pageQueryVars are query params of cursor pagination. Offset serves clients that page by offset.
+type pageQueryVars struct {
+	Cursor string                   `query:"cursor"`
 	Offset int                      `query:"offset"`
+	Limit  int                      `query:"limit"`
+	SortBy inspection.SortKey       `query:"sortBy"`
 	Sort   inspection.SortDirection `query:"sort"`
 }
 
-func (v inspectionListQueryVars) Pagination() pagination.Pagination {
-	return pagination.Pagination{
-		Limit:  v.Limit,
-		Offset: v.Offset,
+func (v pageQueryVars) Page() inspection.Page {
+	return inspection.Page{
+		Cursor:    v.Cursor,
+		Offset:    v.Offset,
+		Limit:     v.Limit,
+		SortBy:    v.SortBy,
+		Direction: v.Sort,
 	}
 }
 
+type filterQueryVars struct {
+	Status                  string `query:"status"`
+	Type                    string `query:"type"`
+	Resolution              string `query:"resolution"`
+	TaskID                  string `query:"taskID"`
+	DeviceID                string `query:"deviceID"`
+	InspectAtFrom           string `query:"inspectAtFrom"`
+	InspectAtTo             string `query:"inspectAtTo"`
+	EnergyActionAtFrom      string `query:"energyActionAtFrom"`
+	EnergyActionAtTo        string `query:"energyActionAtTo"`
+	CreatedAtFrom           string `query:"createdAtFrom"`
+	CreatedAtTo             string `query:"createdAtTo"`
+	IsRestrictionChecked    string `query:"isRestrictionChecked"`
+	IsViolationDetected     string `query:"isViolationDetected"`
+	IsExpenseAvailable      string `query:"isExpenseAvailable"`
+	IsUnauthorizedConsumers string `query:"isUnauthorizedConsumers"`
+}
+
+func readFilter(c gorouter.Context) (inspection.Filter, error) {
+	var vars filterQueryVars
+	if err := c.Vars(&vars); err != nil {
+		return inspection.Filter{}, fmt.Errorf("failed to read query params: %w: %w", inspection.ErrValidation, err)
+	}
+
+	filter, err := vars.Filter()
+	if err != nil {
+		return inspection.Filter{}, fmt.Errorf("failed to read filter: %w", err)
+	}
+
+	return filter, nil
+}
+
+// Filter parses filter query params. All malformed params are reported at once as inspection.ValidationError.
+func (v filterQueryVars) Filter() (inspection.Filter, error) {
+	p := queryParser{}
+
+	filter := inspection.Filter{
+		Statuses:                parseList[inspection.Status](&p, "status", v.Status),
+		Types:                   parseList[inspection.Type](&p, "type", v.Type),
+		Resolutions:             parseList[inspection.Resolution](&p, "resolution", v.Resolution),
+		TaskIDs:                 parseList[int](&p, "taskID", v.TaskID),
+		InspectAtFrom:           p.time("inspectAtFrom", v.InspectAtFrom),
+		InspectAtTo:             p.time("inspectAtTo", v.InspectAtTo),
+		EnergyActionAtFrom:      p.time("energyActionAtFrom", v.EnergyActionAtFrom),
+		EnergyActionAtTo:        p.time("energyActionAtTo", v.EnergyActionAtTo),
+		CreatedAtFrom:           p.time("createdAtFrom", v.CreatedAtFrom),
+		CreatedAtTo:             p.time("createdAtTo", v.CreatedAtTo),
+		IsRestrictionChecked:    p.bool("isRestrictionChecked", v.IsRestrictionChecked),
+		IsViolationDetected:     p.bool("isViolationDetected", v.IsViolationDetected),
+		IsExpenseAvailable:      p.bool("isExpenseAvailable", v.IsExpenseAvailable),
+		IsUnauthorizedConsumers: p.bool("isUnauthorizedConsumers", v.IsUnauthorizedConsumers),
+	}
+
+	if deviceIDs := parseList[int](&p, "deviceID", v.DeviceID); len(deviceIDs) == 1 {
+		filter.DeviceID = &deviceIDs[0]
+	} else if len(deviceIDs) > 1 {
+		p.fail("deviceID", "expected a single device id")
+	}
+
+	if len(p.fields) != 0 {
+		return inspection.Filter{}, inspection.ValidationError{Fields: p.fields}
+	}
+
+	return filter, nil
+}
+
+// GetInspectionStats godoc
+// @Summary Inspection statistics
+// @Description Returns counts of inspections matching the filters, split into groups by the given keys and by period of the inspection start.
+// @Description Each group also has the number and share of inspections with a violation and the average time from start to submission of results.
+// @Description Periods start at midnight in Moscow time, weeks start on Monday. Filters are the same as for the inspection list.
+// @Tags inspections
+// @Produce json
+// @Param groupBy query string false "Comma-separated group keys: type, resolution, status, brigade"
+// @Param period query string false "Period: day, week, or month"
+// @Param status query string false "Statuses, e.g. 3,5"
+// @Param type query string false "Inspection types"
+// @Param resolution query string false "Resolutions"
+// @Param taskID query string false "Task IDs"
+// @Param deviceID query int false "Inspected device ID"
+// @Param inspectAtFrom query string false "Inspected at or after"
+// @Param inspectAtTo query string false "Inspected before"
+// @Param energyActionAtFrom query string false "Energy limited or resumed at or after"
+// @Param energyActionAtTo query string false "Energy limited or resumed before"
+// @Param createdAtFrom query string false "Created at or after"
+// @Param createdAtTo query string false "Created before"
+// @Param isRestrictionChecked query bool false "Restriction was checked"
+// @Param isViolationDetected query bool false "Violation was detected"
+// @Param isExpenseAvailable query bool false "Consumption after limitation was detected"
+// @Param isUnauthorizedConsumers query bool false "Unauthorized consumers were detected"
+// @Success 200 {object} inspection.Stats
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/stats [get]
+func GetInspectionStats(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		filter, err := readFilter(c)
+		if err != nil {
+			return err
+		}
+
+		var vars statsQueryVars
+		if err = c.Vars(&vars); err != nil {
+			return fmt.Errorf("failed to read stats params: %w: %w", inspection.ErrValidation, err)
+		}
+
+		response, err := s.GetStats(c.Ctx(), vars.StatsRequest(filter))
+		if err != nil {
+			return fmt.Errorf("failed to get inspection stats: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+type statsQueryVars struct {
+	GroupBy string                 `query:"groupBy"`
+	Period  inspection.StatsPeriod `query:"period"`
+}
+
+func (v statsQueryVars) StatsRequest(filter inspection.Filter) inspection.StatsRequest {
+	request := inspection.StatsRequest{
+		Filter: filter,
+		Period: v.Period,
+	}
+
+	if len(v.GroupBy) != 0 {
+		for _, key := range strings.Split(v.GroupBy, ",") {
+			request.GroupBy = append(request.GroupBy, inspection.StatsGroupKey(strings.TrimSpace(key)))
+		}
+	}
+
+	return request
+}
+
+// ExportInspections godoc
+// @Summary Export inspections
+// @Description Streams inspections matching the filters as a CSV or XLSX table with one row per inspected device.
+// @Description Filters are the same as for the inspection list.
+// @Tags inspections
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string true "File format: csv or xlsx"
+// @Param status query string false "Statuses, e.g. 3,5"
+// @Param type query string false "Inspection types"
+// @Param resolution query string false "Resolutions"
+// @Param taskID query string false "Task IDs"
+// @Param deviceID query int false "Inspected device ID"
+// @Param inspectAtFrom query string false "Inspected at or after"
+// @Param inspectAtTo query string false "Inspected before"
+// @Param energyActionAtFrom query string false "Energy limited or resumed at or after"
+// @Param energyActionAtTo query string false "Energy limited or resumed before"
+// @Param createdAtFrom query string false "Created at or after"
+// @Param createdAtTo query string false "Created before"
+// @Param isRestrictionChecked query bool false "Restriction was checked"
+// @Param isViolationDetected query bool false "Violation was detected"
+// @Param isExpenseAvailable query bool false "Consumption after limitation was detected"
+// @Param isUnauthorizedConsumers query bool false "Unauthorized consumers were detected"
+// @Success 200 {file} file
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/export [get]
+func ExportInspections(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		filter, err := readFilter(c)
+		if err != nil {
+			return err
+		}
+
+		var vars exportQueryVars
+		if err = c.Vars(&vars); err != nil {
+			return fmt.Errorf("failed to read export params: %w: %w", inspection.ErrValidation, err)
+		}
+
+		w := &exportResponseWriter{w: c.ResponseWriter(), format: vars.Format}
+		err = s.Export(c.Ctx(), inspection.ExportRequest{Filter: filter, Format: vars.Format}, w, clusterfile.NewForwardedHeaders(c.Request()))
+		if err != nil && w.started {
+			// The status is already sent, so the client gets a truncated file.
+			c.Log().Errorf("failed to export inspections: %v", err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export inspections: %w", err)
+		}
+
+		return nil
+	}
+}
+
+type exportQueryVars struct {
+	Format inspection.ExportFormat `query:"format"`
+}
+
+// exportResponseWriter sends the file headers with the first write, so that errors found before any data
+// is exported are still written as regular error responses.
+type exportResponseWriter struct {
+	w       http.ResponseWriter
+	format  inspection.ExportFormat
+	started bool
+}
+
+func (e *exportResponseWriter) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", e.format.ContentType())
+		e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.format.FileName()))
+		e.w.WriteHeader(http.StatusOK)
+	}
+
+	return e.w.Write(p)
+}
+
 type taskIDVars struct {
 	TaskID int `path:"taskID"`
 }
@@ -59,6 +365,7 @@ type taskIDVars struct {
 // GetInspectionByTaskID godoc
 // @Summary Get inspection by task ID
 // @Description Returns an inspection linked to a task.
+// @Description Seals of an unknown device are listed in UnassignedSeals, as for the inspection by ID.
 // @Tags inspections
 // @Produce json
 // @Param taskID path int true "Task ID"
@@ -66,12 +373,13 @@ type taskIDVars struct {
 // @Failure 400 {object} gorouter.ErrorResponse
 // @Failure 404 {object} gorouter.ErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections/task/{taskID} [get]
 func GetInspectionByTaskID(s *inspection.Service) gorouter.Handler {
 	return func(c gorouter.Context) error {
 		var vars taskIDVars
 		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read task id: %w", err)
+			return fmt.Errorf("failed to read task id: %w: %w", inspection.ErrValidation, err)
 		}
 
 		response, err := s.GetByTaskID(c.Ctx(), vars.TaskID, clusterfile.NewForwardedHeaders(c.Request()))
@@ -89,35 +397,75 @@ type brigadeIDVars struct {
 
 // GetInspectionsByBrigade godoc
 // @Summary List brigade inspections
-// @Description Returns inspections linked to tasks assigned to a brigade.
+// @Description Returns inspections linked to tasks assigned to a brigade as an array. Kept for clients written before
+// @Description /v2/inspections/brigades/{brigadeID}, which adds cursor pagination, sort keys and the total count.
 // @Tags inspections
 // @Produce json
+// @Deprecated
 // @Param brigadeID path int true "Brigade ID"
 // @Param limit query int false "Maximum number of items to return; 0 means no limit"
 // @Param offset query int false "Number of items to skip"
+// @Param sort query string false "Sort by InspectAt direction: asc or desc"
 // @Success 200 {array} inspection.Inspection
-// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 400 {object} api.ValidationErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections/brigades/{brigadeID} [get]
 func GetInspectionsByBrigade(s *inspection.Service) gorouter.Handler {
 	return func(c gorouter.Context) error {
-		var vars brigadeIDVars
-		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read brigade id: %w", err)
+		list, err := readBrigadeInspectionList(c, s)
+		if err != nil {
+			return err
 		}
 
-		var pageVars pagination.Pagination
-		if err := c.Vars(&pageVars); err != nil {
-			return fmt.Errorf("failed to read pagination: %w", err)
-		}
+		return c.WriteJson(http.StatusOK, list.Items)
+	}
+}
 
-		response, err := s.GetByBrigade(c.Ctx(), vars.BrigadeID, pageVars, clusterfile.NewForwardedHeaders(c.Request()))
+// ListInspectionsByBrigade godoc
+// @Summary List brigade inspections
+// @Description Returns a page of inspections linked to tasks assigned to a brigade with their total count.
+// @Tags inspections
+// @Produce json
+// @Param brigadeID path int true "Brigade ID"
+// @Param cursor query string false "NextCursor of the previous page; empty for the first page"
+// @Param offset query int false "Number of items to skip; cannot be combined with cursor"
+// @Param limit query int false "Maximum number of items to return; 0 means no limit"
+// @Param sortBy query string false "Sort key: id, created_at, updated_at, or inspect_at; inspect_at when only sort is given, id otherwise"
+// @Param sort query string false "Sort direction: asc or desc"
+// @Success 200 {object} inspection.InspectionList
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /v2/inspections/brigades/{brigadeID} [get]
+func ListInspectionsByBrigade(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		list, err := readBrigadeInspectionList(c, s)
 		if err != nil {
-			return fmt.Errorf("failed to get inspections by brigade id: %w", err)
+			return err
 		}
 
-		return c.WriteJson(http.StatusOK, response)
+		return c.WriteJson(http.StatusOK, list)
+	}
+}
+
+func readBrigadeInspectionList(c gorouter.Context, s *inspection.Service) (inspection.InspectionList, error) {
+	var vars brigadeIDVars
+	if err := c.Vars(&vars); err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("failed to read brigade id: %w: %w", inspection.ErrValidation, err)
+	}
+
+	var pageVars pageQueryVars
+	if err := c.Vars(&pageVars); err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("failed to read pagination: %w: %w", inspection.ErrValidation, err)
+	}
+
+	list, err := s.GetByBrigade(c.Ctx(), vars.BrigadeID, pageVars.Page(), clusterfile.NewForwardedHeaders(c.Request()))
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("failed to get inspections by brigade id: %w", err)
 	}
+
+	return list, nil
 }
 
 type inspectionIDVars struct {
@@ -127,6 +475,8 @@ type inspectionIDVars struct {
 // GetInspectionByID godoc
 // @Summary Get inspection by ID
 // @Description Returns an inspection by its ID.
+// @Description Seals are listed under their InspectedDevices. Inspections finished before seals were bound to devices
+// @Description list seals of an unknown device in UnassignedSeals; it is absent for the other inspections.
 // @Tags inspections
 // @Produce json
 // @Param id path int true "Inspection ID"
@@ -134,12 +484,13 @@ type inspectionIDVars struct {
 // @Failure 400 {object} gorouter.ErrorResponse
 // @Failure 404 {object} gorouter.ErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections/{id} [get]
 func GetInspectionByID(s *inspection.Service) gorouter.Handler {
 	return func(c gorouter.Context) error {
 		var vars inspectionIDVars
 		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read inspection id: %w", err)
+			return fmt.Errorf("failed to read inspection id: %w: %w", inspection.ErrValidation, err)
 		}
 
 		response, err := s.GetByID(c.Ctx(), vars.ID, clusterfile.NewForwardedHeaders(c.Request()))
@@ -151,127 +502,409 @@ func GetInspectionByID(s *inspection.Service) gorouter.Handler {
 	}
 }
 
+// GetInspectionStatusHistory godoc
+// @Summary Get inspection status history
+// @Description Returns status changes of an inspection in chronological order.
+// @Tags inspections
+// @Produce json
+// @Param id path int true "Inspection ID"
+// @Success 200 {array} inspection.StatusChange
+// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Router /inspections/{id}/history [get]
+func GetInspectionStatusHistory(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		var vars inspectionIDVars
+		if err := c.Vars(&vars); err != nil {
+			return fmt.Errorf("failed to read inspection id: %w: %w", inspection.ErrValidation, err)
+		}
+
+		response, err := s.GetStatusHistory(c.Ctx(), vars.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get inspection status history: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
 // AttachPhotoToInspection godoc
-// @Summary Attach inspection photo
-// @Description Uploads one device or seal photo and attaches it to an inspection.
+// @Summary Attach inspection photos
+// @Description Uploads a batch of device and seal photos and attaches them to an inspection.
+// @Description Photo may be repeated; AttachmentType, DeviceID and SealID are matched to the photos by position.
+// @Description AttachmentType is given for every photo. DeviceID and SealID may be omitted when no photo needs them,
+// @Description otherwise they are given for every photo, empty for photos of the other type.
+// @Description Photos are checked by the analyzer concurrently and attached independently: the response lists the attachment
+// @Description or the error of every photo in request order, and a rejected photo does not fail the others.
+// @Description Photos larger than the configured size or of a type that is not allowed fail the whole request;
+// @Description the type is detected from the content of a photo.
 // @Tags inspections
 // @Accept multipart/form-data
 // @Produce json
 // @Param id path int true "Inspection ID"
-// @Param Photo formData file true "Inspection photo"
-// @Param AttachmentType formData int true "Attachment type: 1=device photo, 2=seal photo"
-// @Param DeviceID formData int false "Device ID, required when AttachmentType is 1"
-// @Param SealID formData int false "Seal ID, required when AttachmentType is 2"
-// @Success 200 {object} inspection.Attachment
+// @Param Photo formData file true "Inspection photo, repeated for every photo"
+// @Param AttachmentType formData int true "Attachment type of the photo: 1=device photo, 2=seal photo"
+// @Param DeviceID formData int false "Device ID of the photo, required when AttachmentType is 1"
+// @Param SealID formData int false "Seal ID of the photo, required when AttachmentType is 2"
+// @Success 200 {array} inspection.PhotoResult
 // @Failure 400 {object} gorouter.ErrorResponse
 // @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections/{id}/photo [post]
-func AttachPhotoToInspection(s *inspection.Service) gorouter.Handler {
+func AttachPhotoToInspection(s *inspection.Service, limits config.Photos) gorouter.Handler {
 	return func(c gorouter.Context) error {
 		var vars inspectionIDVars
 		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read inspection id: %w", err)
+			return fmt.Errorf("failed to read inspection id: %w: %w", inspection.ErrValidation, err)
+		}
+
+		// Photos are limited while the form is streamed, so an oversized photo or batch fails the request
+		// as soon as it is read instead of being spooled first.
+		if limits.MaxSize > 0 {
+			rq := c.Request()
+			rq.Body = http.MaxBytesReader(c.ResponseWriter(), rq.Body, inspection.MaxBatchPhotos*limits.MaxSize+maxFormOverhead)
+
+			body, err := limitPhotoParts(rq, limits.MaxSize)
+			if err != nil {
+				return err
+			}
+
+			defer body.Close()
 		}
 
 		files, err := c.FormFiles("Photo")
 		if err != nil {
-			return fmt.Errorf("parse photo from form: %w", err)
+			var partErr photoPartError
+			if errors.As(err, &partErr) {
+				return partErr.validationError()
+			}
+
+			return fmt.Errorf("parse photos from form: %w: %w", inspection.ErrValidation, err)
 		}
-		if len(files) != 1 {
-			return fmt.Errorf("got %d photos, expected 1", len(files))
+		if len(files) == 0 {
+			return fmt.Errorf("%w: no photos", inspection.ErrValidation)
+		}
+
+		if err = checkPhotos(files, limits); err != nil {
+			return err
 		}
 
 		attachmentTypes, err := c.FormValues("AttachmentType")
 		if err != nil {
-			return fmt.Errorf("parse attachment type from form: %w", err)
+			return fmt.Errorf("parse attachment types from form: %w: %w", inspection.ErrValidation, err)
 		}
-		if len(attachmentTypes) != 1 {
-			return fmt.Errorf("got %d attachment types, expected 1", len(attachmentTypes))
+		if len(attachmentTypes) != len(files) {
+			return fmt.Errorf("%w: got %d attachment types for %d photos", inspection.ErrValidation, len(attachmentTypes), len(files))
 		}
 
-		attachmentTypeRaw, err := strconv.Atoi(attachmentTypes[0])
+		// The form is parsed by FormFiles. DeviceID and SealID may be absent, so they are read from it directly.
+		form := c.Request().MultipartForm
+
+		deviceIDs, err := photoFormIDs(form, "DeviceID", len(files))
 		if err != nil {
-			return fmt.Errorf("invalid attachment type: %s", attachmentTypes[0])
+			return err
 		}
 
-		attachmentType := inspection.AttachmentType(attachmentTypeRaw)
-
-		var deviceID, sealID int
-		switch attachmentType {
-		case inspection.AttachmentTypeDevicePhoto:
-			deviceIDs, fErr := c.FormValues("DeviceID")
-			if fErr != nil {
-				return fmt.Errorf("parse device id from form: %w", fErr)
-			}
-			if len(deviceIDs) != 1 {
-				return fmt.Errorf("got %d device ids, expected 1", len(deviceIDs))
-			}
-
-			deviceID, fErr = strconv.Atoi(deviceIDs[0])
-			if fErr != nil {
-				return fmt.Errorf("invalid device id: %s", deviceIDs[0])
-			}
-
-		case inspection.AttachmentTypeSealPhoto:
-			sealIDs, fErr := c.FormValues("SealID")
-			if fErr != nil {
-				return fmt.Errorf("parse seal id from form: %w", fErr)
-			}
-			if len(sealIDs) != 1 {
-				return fmt.Errorf("got %d seal ids, expected 1", len(sealIDs))
-			}
+		sealIDs, err := photoFormIDs(form, "SealID", len(files))
+		if err != nil {
+			return err
+		}
 
-			sealID, fErr = strconv.Atoi(sealIDs[0])
-			if fErr != nil {
-				return fmt.Errorf("invalid seal id: %s", sealIDs[0])
+		photos := make([]inspection.Photo, 0, len(files))
+		for i, f := range files {
+			attachmentType, err := strconv.Atoi(attachmentTypes[i])
+			if err != nil {
+				return fmt.Errorf("%w: invalid attachment type: %s", inspection.ErrValidation, attachmentTypes[i])
 			}
 
-		default:
-			return fmt.Errorf("invalid attachment type: %d", attachmentType)
+			photos = append(photos, inspection.Photo{
+				Type:       inspection.AttachmentType(attachmentType),
+				DeviceID:   deviceIDs[i],
+				SealID:     sealIDs[i],
+				FileHeader: f,
+			})
 		}
 
-		response, err := s.AttachPhoto(c.Ctx(), c.Log().WithTags("AttachPhoto"), inspection.AttachPhotoRequest{
+		response, err := s.AttachPhotos(c.Ctx(), c.Log().WithTags("AttachPhotos"), inspection.AttachPhotosRequest{
 			InspectionID: vars.ID,
-			Type:         attachmentType,
-			DeviceID:     deviceID,
-			SealID:       sealID,
-			FileHeader:   files[0],
+			Photos:       photos,
 			FileHeaders:  clusterfile.NewForwardedHeaders(c.Request()),
 		})
 		if err != nil {
-			return fmt.Errorf("failed to attach photo to inspection: %w", err)
+			return fmt.Errorf("failed to attach photos to inspection: %w", err)
 		}
 
 		return c.WriteJson(http.StatusOK, response)
 	}
 }
 
+// maxFormOverhead is the room left in a photo form for the part headers and the other fields.
+const maxFormOverhead = 1 << 20
+
+// photoPartError rejects a part of a photo form while the form is streamed. Index is the position of the photo,
+// TooLarge tells an oversized photo from a photo beyond the batch.
+type photoPartError struct {
+	Index    int
+	MaxSize  int64
+	TooLarge bool
+}
+
+func (e photoPartError) Error() string {
+	if e.TooLarge {
+		return fmt.Sprintf("photo %d exceeds %d bytes", e.Index, e.MaxSize)
+	}
+
+	return fmt.Sprintf("photo %d exceeds the batch of %d photos", e.Index, inspection.MaxBatchPhotos)
+}
+
+func (e photoPartError) validationError() error {
+	if e.TooLarge {
+		field := inspection.FieldError{Field: fmt.Sprintf("Photo[%d]", e.Index), Message: fmt.Sprintf("must not exceed %d bytes", e.MaxSize)}
+		return inspection.ValidationError{Fields: []inspection.FieldError{field}}
+	}
+
+	field := inspection.FieldError{Field: "Photo", Message: fmt.Sprintf("at most %d photos are allowed", inspection.MaxBatchPhotos)}
+	return inspection.ValidationError{Fields: []inspection.FieldError{field}}
+}
+
+// limitPhotoParts replaces the multipart body of the request with a copy streamed part by part, which fails with
+// photoPartError as soon as a photo exceeds maxSize or the batch has too many photos; other fields are limited
+// to maxFormOverhead. The returned body is closed after the form is read.
+func limitPhotoParts(rq *http.Request, maxSize int64) (io.Closer, error) {
+	// The parts are read without rq.MultipartReader, which would keep the form from being parsed afterwards.
+	mediaType, params, err := mime.ParseMediaType(rq.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || len(params["boundary"]) == 0 {
+		return nil, fmt.Errorf("%w: photos must be sent as multipart/form-data", inspection.ErrValidation)
+	}
+
+	parts := multipart.NewReader(rq.Body, params["boundary"])
+
+	body, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(copyPhotoParts(w, parts, maxSize))
+	}()
+
+	rq.Body = body
+	rq.Header.Set("Content-Type", w.FormDataContentType())
+
+	return body, nil
+}
+
+// copyPhotoParts copies the parts of a photo form to w and closes it.
+func copyPhotoParts(w *multipart.Writer, parts *multipart.Reader, maxSize int64) error {
+	for photos := 0; ; {
+		part, err := parts.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return w.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		isPhoto := part.FormName() == "Photo"
+
+		limit := int64(maxFormOverhead)
+		if isPhoto {
+			if photos == inspection.MaxBatchPhotos {
+				return photoPartError{Index: photos}
+			}
+
+			limit = maxSize
+			photos++
+		}
+
+		dst, err := w.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+
+		n, err := io.Copy(dst, io.LimitReader(part, limit+1))
+		if err != nil {
+			return err
+		}
+
+		if n > limit && isPhoto {
+			return photoPartError{Index: photos - 1, MaxSize: limit, TooLarge: true}
+		}
+		if n > limit {
+			return fmt.Errorf("field %s exceeds %d bytes", part.FormName(), limit)
+		}
+	}
+}
+
+// checkPhotos checks the size and the type of every photo and reports all photos that do not pass at once.
+func checkPhotos(files []*multipart.FileHeader, limits config.Photos) error {
+	var fields []inspection.FieldError
+	for i, f := range files {
+		field := fmt.Sprintf("Photo[%d]", i)
+
+		if limits.MaxSize > 0 && f.Size > limits.MaxSize {
+			fields = append(fields, inspection.FieldError{Field: field, Message: fmt.Sprintf("must not exceed %d bytes", limits.MaxSize)})
+			continue
+		}
+
+		if len(limits.AllowedTypes) == 0 {
+			continue
+		}
+
+		contentType, err := photoContentType(f)
+		if err != nil {
+			return fmt.Errorf("detect type of photo %d: %w", i, err)
+		}
+
+		if !slices.Contains(limits.AllowedTypes, contentType) {
+			fields = append(fields, inspection.FieldError{Field: field, Message: fmt.Sprintf("type %s is not allowed", contentType)})
+		}
+	}
+
+	if len(fields) != 0 {
+		return inspection.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// photoContentType detects the MIME type of the photo from its first bytes; the type declared in the part header
+// is not trusted. HEIC and HEIF photos, which the standard detection does not know, are told by their file type box.
+func photoContentType(f *multipart.FileHeader) (string, error) {
+	file, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if contentType, ok := heifContentType(head[:n]); ok {
+		return contentType, nil
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+
+	return contentType, nil
+}
+
+// heifBrands are the brands of the file type box of HEIF files with the type of each: HEIC for HEVC coded images
+// and sequences, HEIF for the other images.
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"hevc": "image/heic",
+	"hevx": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"heif": "image/heif",
+}
+
+// heifContentType detects HEIC and HEIF files by the ftyp box they start with. Both major and compatible brands
+// are checked, since phones write HEIC photos with the generic mif1 major brand; a HEIC brand wins over a HEIF one.
+func heifContentType(head []byte) (string, bool) {
+	if len(head) < 16 || string(head[4:8]) != "ftyp" {
+		return "", false
+	}
+
+	size := min(int(binary.BigEndian.Uint32(head[:4])), len(head))
+
+	contentType := ""
+	for i := 8; i+4 <= size; i += 4 {
+		// The minor version follows the major brand.
+		if i == 12 {
+			continue
+		}
+
+		switch t := heifBrands[string(head[i:i+4])]; {
+		case t == "image/heic":
+			return t, true
+		case len(t) != 0:
+			contentType = t
+		}
+	}
+
+	return contentType, len(contentType) != 0
+}
+
+// photoFormIDs reads the IDs of the form field, one per photo by position. The field may be omitted when no photo
+// needs it, and values of photos it does not apply to may be empty.
+func photoFormIDs(form *multipart.Form, name string, photos int) ([]int, error) {
+	ids := make([]int, photos)
+
+	var values []string
+	if form != nil {
+		values = form.Value[name]
+	}
+
+	if len(values) == 0 {
+		return ids, nil
+	}
+	if len(values) != photos {
+		return nil, fmt.Errorf("%w: got %d values of %s for %d photos", inspection.ErrValidation, len(values), name, photos)
+	}
+
+	for i, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s: %s", inspection.ErrValidation, name, v)
+		}
+
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
 // FinishInspection godoc
 // @Summary Finish inspection
 // @Description Saves inspection results, generated data, and completion state.
+// @Description The request is validated against the rules of its Type; invalid fields are listed in the response.
+// @Description Consumption is computed from the previous reading of each device; the declared one is kept to flag discrepancies.
+// @Description A device without a previous reading gets null Consumption and the act shows "нет предыдущих показаний".
+// @Description Repeated requests with the same Idempotency-Key get the response of the first one, or 409 while it is in progress.
+// @Description The act is generated in every format listed in ActFormats ("docx", "pdf"; "docx" when empty) and attached to the inspection;
+// @Description the response is the file of the first format.
+// @Description Handwritten Signatures (PNG, base64) of the consumer and the inspectors are stored and drawn at the end of the act.
+// @Description Every act file is signed with the Ed25519 key of the service; the signature is stored with its attachment.
+// @Description The act gets the next number of its series (act kind, year and branch), which is kept by the inspection for retries.
+// @Description The number is taken before the act is uploaded, so a series has a gap when an inspection is never submitted with its number.
 // @Tags inspections
 // @Produce json
 // @Param id path int true "Inspection ID"
+// @Param Idempotency-Key header string false "Client-generated key that makes retries safe"
 // @Param request body inspection.FinishInspectionRequest true "Inspection completion payload"
 // @Success 200 {object} inspection.Inspection
-// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 400 {object} api.ValidationErrorResponse
 // @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
 // @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
 // @Router /inspections/{id}/finish [patch]
 func FinishInspection(s *inspection.Service) gorouter.Handler {
 	return func(c gorouter.Context) error {
 		var vars inspectionIDVars
 		if err := c.Vars(&vars); err != nil {
-			return fmt.Errorf("failed to read id: %w", err)
+			return fmt.Errorf("failed to read id: %w: %w", inspection.ErrValidation, err)
 		}
 
 		var request inspection.FinishInspectionRequest
 		if err := c.ReadJson(&request); err != nil {
-			return fmt.Errorf("failed to read finish inspection request: %w", err)
+			return fmt.Errorf("failed to read finish inspection request: %w: %w", inspection.ErrValidation, err)
 		}
 
 		request.ID = vars.ID
+		request.IdempotencyKey = c.Request().Header.Get(idempotencyKeyHeader)
 
 		response, err := s.FinishInspection(c.Ctx(), c.Log().WithTags("FinishInspection"), request, clusterfile.NewForwardedHeaders(c.Request()))
 		if err != nil {
@@ -281,3 +914,258 @@ func FinishInspection(s *inspection.Service) gorouter.Handler {
 		return c.WriteJson(http.StatusOK, response)
 	}
 }
+
+// PreviewAct godoc
+// @Summary Preview inspection act
+// @Description Generates the act from an inspection completion payload and returns the document without finishing the inspection.
+// @Description The payload is validated the same way as on finish. Nothing is uploaded or saved, and the status is not changed.
+// @Tags inspections
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.wordprocessingml.document
+// @Produce application/pdf
+// @Param id path int true "Inspection ID"
+// @Param format query string false "Act format: docx (default) or pdf"
+// @Param request body inspection.FinishInspectionRequest true "Inspection completion payload"
+// @Success 200 {file} file
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/{id}/act/preview [post]
+func PreviewAct(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		var vars inspectionIDVars
+		if err := c.Vars(&vars); err != nil {
+			return fmt.Errorf("failed to read id: %w: %w", inspection.ErrValidation, err)
+		}
+
+		var query actPreviewQueryVars
+		if err := c.Vars(&query); err != nil {
+			return fmt.Errorf("failed to read preview params: %w: %w", inspection.ErrValidation, err)
+		}
+
+		var request inspection.FinishInspectionRequest
+		if err := c.ReadJson(&request); err != nil {
+			return fmt.Errorf("failed to read finish inspection request: %w: %w", inspection.ErrValidation, err)
+		}
+
+		request.ID = vars.ID
+
+		document, err := s.PreviewAct(c.Ctx(), request, query.Format)
+		if err != nil {
+			return fmt.Errorf("failed to preview act: %w", err)
+		}
+
+		w := c.ResponseWriter()
+		w.Header().Set("Content-Type", document.Format.ContentType())
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": document.FileName}))
+		w.WriteHeader(http.StatusOK)
+
+		if _, err = document.Content.WriteTo(w); err != nil {
+			// The status is already sent, so the client gets a truncated file.
+			c.Log().Errorf("failed to write act preview: %v", err)
+		}
+
+		return nil
+	}
+}
+
+type actPreviewQueryVars struct {
+	Format inspection.ActFormat `query:"format"`
+}
+
+// RegenerateAct godoc
+// @Summary Regenerate inspection act
+// @Description Generates the act of a submitted inspection again from its stored results with the latest template,
+// @Description keeping the inspection date and readings. The new files are attached with the reason; the previous act
+// @Description files stay attached with SupersededAt set. ActFormats works as on finish.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path int true "Inspection ID"
+// @Param request body inspection.RegenerateActRequest true "Regeneration reason and formats"
+// @Success 200 {array} inspection.Attachment
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/{id}/act/regenerate [post]
+func RegenerateAct(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		var vars inspectionIDVars
+		if err := c.Vars(&vars); err != nil {
+			return fmt.Errorf("failed to read id: %w: %w", inspection.ErrValidation, err)
+		}
+
+		var request inspection.RegenerateActRequest
+		if err := c.ReadJson(&request); err != nil {
+			return fmt.Errorf("failed to read regenerate act request: %w: %w", inspection.ErrValidation, err)
+		}
+
+		request.ID = vars.ID
+
+		response, err := s.RegenerateAct(c.Ctx(), c.Log().WithTags("RegenerateAct"), request, clusterfile.NewForwardedHeaders(c.Request()))
+		if err != nil {
+			return fmt.Errorf("failed to regenerate act: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+// GetActSigningKey godoc
+// @Summary Get act signing key
+// @Description Returns the base64 Ed25519 public key of the signatures of issued acts.
+// @Description A signature stored with an act attachment is made over the content of the act file.
+// @Tags inspections
+// @Produce json
+// @Success 200 {object} inspection.ActSigningKey
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Router /inspections/acts/signing-key [get]
+func GetActSigningKey(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		response, err := s.GetActSigningKey()
+		if err != nil {
+			return fmt.Errorf("failed to get act signing key: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+// VerifyAct godoc
+// @Summary Verify inspection act
+// @Description Reports whether a document is an act issued by the service, for which inspection, and when.
+// @Description An uploaded File is matched by its SHA-256; the Ed25519 signature of every matching act is checked as well.
+// @Description With fileID the file is downloaded from the file service and checked against its act attachment;
+// @Description an act issued before digests were recorded is reported as Unverifiable.
+// @Description A changed document matches no act.
+// @Tags inspections
+// @Accept multipart/form-data
+// @Produce json
+// @Param File formData file false "Document to verify, required without fileID"
+// @Param fileID query int false "ID of the file in the file service, required without File"
+// @Success 200 {object} inspection.ActVerification
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/acts/verify [post]
+func VerifyAct(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		var query actVerifyQueryVars
+		if err := c.Vars(&query); err != nil {
+			return fmt.Errorf("failed to read verify params: %w: %w", inspection.ErrValidation, err)
+		}
+
+		request := inspection.VerifyActRequest{FileID: query.FileID}
+		if query.FileID == 0 {
+			files, err := c.FormFiles("File")
+			if err != nil {
+				return fmt.Errorf("parse document from form: %w: %w", inspection.ErrValidation, err)
+			}
+			if len(files) != 1 {
+				return fmt.Errorf("%w: got %d documents, expected 1", inspection.ErrValidation, len(files))
+			}
+
+			request.FileHeader = files[0]
+		}
+
+		response, err := s.VerifyAct(c.Ctx(), request)
+		if err != nil {
+			return fmt.Errorf("failed to verify act: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+type actVerifyQueryVars struct {
+	FileID int `query:"fileID"`
+}
+
+// ApproveInspection godoc
+// @Summary Approve inspection
+// @Description Accepts a submitted inspection after review by a dispatcher.
+// @Description Only reviewers may approve, and not an inspection they submitted themselves.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path int true "Inspection ID"
+// @Param request body inspection.ReviewRequest false "Review comment"
+// @Success 200 {object} inspection.Inspection
+// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 403 {object} gorouter.ErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/{id}/approve [patch]
+func ApproveInspection(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		request, err := readReviewRequest(c)
+		if err != nil {
+			return err
+		}
+
+		response, err := s.ApproveInspection(c.Ctx(), request, clusterfile.NewForwardedHeaders(c.Request()))
+		if err != nil {
+			return fmt.Errorf("failed to approve inspection: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+// RejectInspection godoc
+// @Summary Reject inspection
+// @Description Returns a submitted inspection to the inspector with a comment; the inspector finishes it again with a new act.
+// @Description Only reviewers may reject, and not an inspection they submitted themselves.
+// @Tags inspections
+// @Accept json
+// @Produce json
+// @Param id path int true "Inspection ID"
+// @Param request body inspection.ReviewRequest true "Rejection reason"
+// @Success 200 {object} inspection.Inspection
+// @Failure 400 {object} gorouter.ErrorResponse
+// @Failure 403 {object} gorouter.ErrorResponse
+// @Failure 404 {object} gorouter.ErrorResponse
+// @Failure 409 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Failure 503 {object} gorouter.ErrorResponse
+// @Router /inspections/{id}/reject [patch]
+func RejectInspection(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		request, err := readReviewRequest(c)
+		if err != nil {
+			return err
+		}
+
+		response, err := s.RejectInspection(c.Ctx(), request, clusterfile.NewForwardedHeaders(c.Request()))
+		if err != nil {
+			return fmt.Errorf("failed to reject inspection: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+func readReviewRequest(c gorouter.Context) (inspection.ReviewRequest, error) {
+	var vars inspectionIDVars
+	if err := c.Vars(&vars); err != nil {
+		return inspection.ReviewRequest{}, fmt.Errorf("failed to read inspection id: %w: %w", inspection.ErrValidation, err)
+	}
+
+	var request inspection.ReviewRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.ReadJson(&request); err != nil {
+			return inspection.ReviewRequest{}, fmt.Errorf("failed to read review request: %w: %w", inspection.ErrValidation, err)
+		}
+	}
+
+	request.ID = vars.ID
+
+	return request, nil
+}
@@ -1,14 +1,23 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"inspection-service/config"
 	"inspection-service/service/inspection"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
 	"testing"
 
 	routerreflect "github.com/sunshineOfficial/golib/gohttp/gorouter/reflect"
 )
 
 func TestInspectionListQueryVarsReadsPaginationWithSort(t *testing.T) {
-	var vars inspectionListQueryVars
+	var vars pageQueryVars
 	err := routerreflect.SetValuesToItem(map[string][]string{
 		"limit":  {"10"},
 		"offset": {"20"},
@@ -28,3 +37,257 @@ func TestInspectionListQueryVarsReadsPaginationWithSort(t *testing.T) {
 		t.Fatalf("sort = %q, want %q", vars.Sort, inspection.SortAsc)
 	}
 }
+
+func TestPageQueryVarsReadsCursorPagination(t *testing.T) {
+	var vars pageQueryVars
+	err := routerreflect.SetValuesToItem(map[string][]string{
+		"cursor": {"eyJpIjo0Mn0"},
+		"limit":  {"10"},
+		"sortBy": {"created_at"},
+		"sort":   {"asc"},
+	}, "query", &vars)
+	if err != nil {
+		t.Fatalf("SetValuesToItem returned error: %v", err)
+	}
+
+	want := inspection.Page{
+		Cursor:    "eyJpIjo0Mn0",
+		Limit:     10,
+		SortBy:    inspection.SortByCreatedAt,
+		Direction: inspection.SortAsc,
+	}
+	if got := vars.Page(); got != want {
+		t.Fatalf("page = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilterQueryVarsParsesFilter(t *testing.T) {
+	vars := filterQueryVars{
+		Status:              "3, 4",
+		Type:                "4",
+		DeviceID:            "11",
+		InspectAtFrom:       "2026-09-01",
+		InspectAtTo:         "2026-10-01T00:00:00+03:00",
+		IsViolationDetected: "true",
+	}
+
+	filter, err := vars.Filter()
+	if err != nil {
+		t.Fatalf("Filter returned error: %v", err)
+	}
+
+	if len(filter.Statuses) != 2 || filter.Statuses[0] != inspection.StatusSubmitted || filter.Statuses[1] != inspection.StatusApproved {
+		t.Fatalf("statuses = %v, want [3 4]", filter.Statuses)
+	}
+	if len(filter.Types) != 1 || filter.Types[0] != inspection.TypeUnauthorizedConnection {
+		t.Fatalf("types = %v, want [4]", filter.Types)
+	}
+	if filter.DeviceID == nil || *filter.DeviceID != 11 {
+		t.Fatalf("device id = %v, want 11", filter.DeviceID)
+	}
+	if filter.InspectAtFrom == nil || filter.InspectAtTo == nil || !filter.InspectAtFrom.Before(*filter.InspectAtTo) {
+		t.Fatalf("inspect at range = %v - %v", filter.InspectAtFrom, filter.InspectAtTo)
+	}
+	if filter.IsViolationDetected == nil || !*filter.IsViolationDetected {
+		t.Fatalf("is violation detected = %v, want true", filter.IsViolationDetected)
+	}
+}
+
+func TestFilterQueryVarsReportsInvalidFilter(t *testing.T) {
+	vars := filterQueryVars{
+		Status:        "submitted",
+		InspectAtFrom: "September",
+	}
+
+	_, err := vars.Filter()
+
+	var validationErr inspection.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Filter error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 2 {
+		t.Fatalf("fields = %+v, want 2 fields", validationErr.Fields)
+	}
+}
+
+func TestPhotoFormIDsMatchesValuesToPhotos(t *testing.T) {
+	form := &multipart.Form{Value: map[string][]string{"DeviceID": {"11", "", "12"}}}
+
+	ids, err := photoFormIDs(form, "DeviceID", 3)
+	if err != nil {
+		t.Fatalf("photoFormIDs returned error: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != 11 || ids[1] != 0 || ids[2] != 12 {
+		t.Fatalf("ids = %v, want [11 0 12]", ids)
+	}
+
+	ids, err = photoFormIDs(form, "SealID", 3)
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("photoFormIDs of an absent field = %v, %v, want 3 empty ids", ids, err)
+	}
+
+	if _, err = photoFormIDs(form, "DeviceID", 2); !errors.Is(err, inspection.ErrValidation) {
+		t.Fatalf("photoFormIDs error = %v, want ErrValidation for a value count mismatch", err)
+	}
+}
+
+// newTestPhotoForm returns a photo form with a part of each declared type and content, and its content type.
+func newTestPhotoForm(t *testing.T, parts ...[2]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	for i, part := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="Photo"; filename="photo%d"`, i))
+		header.Set("Content-Type", part[0])
+
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			t.Fatalf("CreatePart returned error: %v", err)
+		}
+		if _, err = pw.Write([]byte(part[1])); err != nil {
+			t.Fatalf("write part: %v", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+
+	return &body, w.FormDataContentType()
+}
+
+// newTestPhotos returns the parsed photo parts of a form, each with the declared type and the content.
+func newTestPhotos(t *testing.T, parts ...[2]string) []*multipart.FileHeader {
+	t.Helper()
+
+	body, contentType := newTestPhotoForm(t, parts...)
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("ParseMediaType returned error: %v", err)
+	}
+
+	form, err := multipart.NewReader(body, params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = form.RemoveAll() })
+
+	return form.File["Photo"]
+}
+
+func TestCheckPhotosRejectsLargeAndDisallowedPhotos(t *testing.T) {
+	limits := config.Photos{MaxSize: 64, AllowedTypes: []string{"image/jpeg", "image/png", "image/heic"}}
+
+	files := newTestPhotos(t,
+		[2]string{"image/jpeg", "\xff\xd8\xff\xe0 jpeg"},
+		[2]string{"image/jpeg", "not a photo"},
+		[2]string{"image/jpeg", testHEICPhoto},
+		[2]string{"image/png", "\x89PNG\r\n\x1a\n" + string(make([]byte, 64))},
+		[2]string{"image/heic", "not a photo"},
+	)
+
+	err := checkPhotos(files, limits)
+
+	var validationErr inspection.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("checkPhotos error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 3 || validationErr.Fields[0].Field != "Photo[1]" || validationErr.Fields[1].Field != "Photo[3]" ||
+		validationErr.Fields[2].Field != "Photo[4]" {
+		t.Fatalf("fields = %+v, want the text files and the large photo", validationErr.Fields)
+	}
+
+	if err = checkPhotos(files, config.Photos{}); err != nil {
+		t.Fatalf("checkPhotos without limits returned error: %v", err)
+	}
+}
+
+func TestCheckPhotosAcceptsHEIFPhotoOfAllowedType(t *testing.T) {
+	limits := config.Photos{MaxSize: 64, AllowedTypes: []string{"image/heic", "image/heif"}}
+
+	files := newTestPhotos(t, [2]string{"image/heif", testHEIFPhoto}, [2]string{"image/heic", testHEICPhoto})
+
+	if err := checkPhotos(files, limits); err != nil {
+		t.Fatalf("checkPhotos returned error: %v", err)
+	}
+}
+
+// testHEICPhoto starts like a HEIC photo from a phone: the generic HEIF major brand with HEIC among compatible ones.
+const testHEICPhoto = "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic"
+
+// testHEIFPhoto starts like a HEIF photo that is not HEVC coded: only generic HEIF brands.
+const testHEIFPhoto = "\x00\x00\x00\x14ftypmif1\x00\x00\x00\x00mif1"
+
+func TestHEIFContentTypeReadsFileTypeBox(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		head string
+		want string
+	}{
+		{name: "heic major brand", head: "\x00\x00\x00\x14ftypheic\x00\x00\x00\x00mif1", want: "image/heic"},
+		{name: "heic compatible brand", head: testHEICPhoto, want: "image/heic"},
+		{name: "heif", head: testHEIFPhoto, want: "image/heif"},
+		{name: "brand beyond the box", head: "\x00\x00\x00\x10ftypisom\x00\x00\x00\x00heic", want: ""},
+		{name: "other file", head: "\xff\xd8\xff\xe0 jpeg photo", want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := heifContentType([]byte(tc.head))
+			if got != tc.want || ok != (len(tc.want) != 0) {
+				t.Fatalf("heifContentType = %q, %t, want %q", got, ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestLimitPhotoPartsRejectsPhotosWhileStreaming(t *testing.T) {
+	small := [2]string{"image/jpeg", "\xff\xd8\xff\xe0 jpeg"}
+	large := [2]string{"image/jpeg", "\xff\xd8\xff\xe0" + string(make([]byte, 64))}
+
+	tooMany := make([][2]string, inspection.MaxBatchPhotos+1)
+	for i := range tooMany {
+		tooMany[i] = small
+	}
+
+	for _, tc := range []struct {
+		name  string
+		parts [][2]string
+		want  *photoPartError
+	}{
+		{name: "within limits", parts: [][2]string{small, small}},
+		{name: "large photo", parts: [][2]string{small, large, small}, want: &photoPartError{Index: 1, MaxSize: 64, TooLarge: true}},
+		{name: "too many photos", parts: tooMany, want: &photoPartError{Index: inspection.MaxBatchPhotos}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType := newTestPhotoForm(t, tc.parts...)
+
+			rq := httptest.NewRequest(http.MethodPost, "/inspections/42/photo", body)
+			rq.Header.Set("Content-Type", contentType)
+
+			limited, err := limitPhotoParts(rq, 64)
+			if err != nil {
+				t.Fatalf("limitPhotoParts returned error: %v", err)
+			}
+			defer limited.Close()
+
+			err = rq.ParseMultipartForm(1 << 20)
+			if tc.want == nil {
+				if err != nil || len(rq.MultipartForm.File["Photo"]) != len(tc.parts) {
+					t.Fatalf("ParseMultipartForm = %v, want %d photos", err, len(tc.parts))
+				}
+				return
+			}
+
+			var partErr photoPartError
+			if !errors.As(err, &partErr) || partErr != *tc.want {
+				t.Fatalf("ParseMultipartForm error = %v, want %v", err, *tc.want)
+			}
+			if !errors.Is(partErr.validationError(), inspection.ErrValidation) {
+				t.Fatalf("validationError = %v, want ErrValidation", partErr.validationError())
+			}
+		})
+	}
+}
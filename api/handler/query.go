@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"inspection-service/service/inspection"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+// queryParser parses optional query params and collects errors instead of stopping at the first one.
+type queryParser struct {
+	fields []inspection.FieldError
+}
+
+func (p *queryParser) fail(field, format string, args ...any) {
+	p.fields = append(p.fields, inspection.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// parseList parses comma-separated integers. An empty value gives nil.
+func parseList[T ~int](p *queryParser, field, value string) []T {
+	if len(value) == 0 {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	result := make([]T, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			p.fail(field, "invalid number %q", part)
+			continue
+		}
+
+		result = append(result, T(n))
+	}
+
+	return result
+}
+
+// time parses an RFC 3339 timestamp or a YYYY-MM-DD date, which is taken as midnight in Moscow.
+func (p *queryParser) time(field, value string) *time.Time {
+	if len(value) == 0 {
+		return nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, gotime.Moscow); err == nil {
+		return &t
+	}
+
+	p.fail(field, "invalid date %q, expected RFC 3339 or YYYY-MM-DD", value)
+
+	return nil
+}
+
+func (p *queryParser) bool(field, value string) *bool {
+	if len(value) == 0 {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(field, "invalid boolean %q", value)
+		return nil
+	}
+
+	return &b
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"inspection-service/service/inspection"
+	"net/http"
+	"strconv"
+
+	"github.com/sunshineOfficial/golib/gohttp/gorouter"
+)
+
+// GetActTemplates godoc
+// @Summary Get act templates
+// @Description Returns all versions of act templates, the latest version of each inspection type first.
+// @Description Acts are generated from the latest version of the template of the inspection type.
+// @Description Only admins may list templates.
+// @Tags act templates
+// @Produce json
+// @Success 200 {array} inspection.ActTemplate
+// @Failure 403 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Router /admin/act-templates [get]
+func GetActTemplates(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		response, err := s.GetActTemplates(c.Ctx())
+		if err != nil {
+			return fmt.Errorf("failed to get act templates: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
+
+// UploadActTemplate godoc
+// @Summary Upload act template
+// @Description Stores a docx template as the next version of the template of an inspection type.
+// @Description The template must contain every placeholder filled for acts of the type and no others;
+// @Description placeholders of the device table must share one table row. Only admins may upload templates.
+// @Tags act templates
+// @Accept multipart/form-data
+// @Produce json
+// @Param File formData file true "Act template in docx format"
+// @Param Type formData int true "Inspection type: 1=limitation, 2=resumption, 3=verification, 4=unauthorized connection"
+// @Success 200 {object} inspection.ActTemplate
+// @Failure 400 {object} api.ValidationErrorResponse
+// @Failure 403 {object} gorouter.ErrorResponse
+// @Failure 500 {object} gorouter.ErrorResponse
+// @Router /admin/act-templates [post]
+func UploadActTemplate(s *inspection.Service) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		files, err := c.FormFiles("File")
+		if err != nil {
+			return fmt.Errorf("parse template from form: %w: %w", inspection.ErrValidation, err)
+		}
+		if len(files) != 1 {
+			return fmt.Errorf("%w: got %d templates, expected 1", inspection.ErrValidation, len(files))
+		}
+
+		types, err := c.FormValues("Type")
+		if err != nil {
+			return fmt.Errorf("parse type from form: %w: %w", inspection.ErrValidation, err)
+		}
+		if len(types) != 1 {
+			return fmt.Errorf("%w: got %d types, expected 1", inspection.ErrValidation, len(types))
+		}
+
+		inspectionType, err := strconv.Atoi(types[0])
+		if err != nil {
+			return fmt.Errorf("%w: invalid type: %s", inspection.ErrValidation, types[0])
+		}
+
+		response, err := s.UploadActTemplate(c.Ctx(), inspection.UploadActTemplateRequest{
+			Type:       inspection.Type(inspectionType),
+			FileHeader: files[0],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload act template: %w", err)
+		}
+
+		return c.WriteJson(http.StatusOK, response)
+	}
+}
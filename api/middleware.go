@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"inspection-service/service/inspection"
+	"net/http"
+
+	"github.com/sunshineOfficial/golib/gohttp/gorouter"
+)
+
+var errorKinds = []struct {
+	kind   error
+	status int
+}{
+	{kind: inspection.ErrNotFound, status: http.StatusNotFound},
+	{kind: inspection.ErrValidation, status: http.StatusBadRequest},
+	{kind: inspection.ErrConflict, status: http.StatusConflict},
+	{kind: inspection.ErrUpstreamUnavailable, status: http.StatusServiceUnavailable},
+	{kind: inspection.ErrForbidden, status: http.StatusForbidden},
+}
+
+// ValidationErrorResponse is gorouter.ErrorResponse extended with the list of invalid request fields.
+type ValidationErrorResponse struct {
+	gorouter.ErrorResponse
+	Fields []inspection.FieldError `json:"Fields"`
+}
+
+// DomainErrors writes domain errors returned by handlers as gorouter.ErrorResponse with a matching status.
+// Unknown errors are passed on unchanged and end up as 500.
+func DomainErrors(next gorouter.Handler) gorouter.Handler {
+	return func(c gorouter.Context) error {
+		err := next(c)
+		if err == nil {
+			return nil
+		}
+
+		for _, k := range errorKinds {
+			if !errors.Is(err, k.kind) {
+				continue
+			}
+
+			code, message := inspection.ErrorCode(err), err.Error()
+			var coded inspection.CodedError
+			if errors.As(err, &coded) {
+				message = coded.Error()
+			}
+
+			if k.status >= http.StatusInternalServerError {
+				c.Log().Errorf("%s: %v", code, err)
+			}
+
+			response := gorouter.ErrorResponse{
+				Error: gorouter.ErrorInfo{
+					Code:    code,
+					Message: message,
+				},
+			}
+
+			var validationErr inspection.ValidationError
+			if errors.As(err, &validationErr) {
+				return c.WriteJson(k.status, ValidationErrorResponse{
+					ErrorResponse: response,
+					Fields:        validationErr.Fields,
+				})
+			}
+
+			return c.WriteJson(k.status, response)
+		}
+
+		return err
+	}
+}
@@ -17,6 +17,7 @@ import (
 type ServerBuilder struct {
 	server goserver.Server
 	router *gorouter.Router
+	photos config.Photos
 }
 
 func NewServerBuilder(ctx context.Context, log golog.Logger, settings config.Settings) *ServerBuilder {
@@ -26,7 +27,9 @@ func NewServerBuilder(ctx context.Context, log golog.Logger, settings config.Set
 			middleware.Metrics(),
 			middleware.Recover,
 			middleware.LogError,
+			DomainErrors,
 		),
+		photos: settings.Photos,
 	}
 }
 
@@ -37,11 +40,32 @@ func (s *ServerBuilder) AddDebug() {
 func (s *ServerBuilder) AddInspections(service *inspection.Service) {
 	r := s.router.SubRouter("/inspections")
 	r.HandleGet("", handler.GetAllInspections(service))
+	r.HandleGet("/stats", handler.GetInspectionStats(service))
+	r.HandleGet("/export", handler.ExportInspections(service))
 	r.HandleGet("/{id}", handler.GetInspectionByID(service))
+	r.HandleGet("/{id}/history", handler.GetInspectionStatusHistory(service))
 	r.HandleGet("/task/{taskID}", handler.GetInspectionByTaskID(service))
 	r.HandleGet("/brigades/{brigadeID}", handler.GetInspectionsByBrigade(service))
-	r.HandlePost("/{id}/photo", handler.AttachPhotoToInspection(service))
+	r.HandleGet("/acts/signing-key", handler.GetActSigningKey(service))
+	r.HandlePost("/acts/verify", handler.VerifyAct(service))
+	r.HandlePost("/{id}/photo", handler.AttachPhotoToInspection(service, s.photos))
+	r.HandlePost("/{id}/act/preview", handler.PreviewAct(service))
+	r.HandlePost("/{id}/act/regenerate", handler.RegenerateAct(service))
 	r.HandlePatch("/{id}/finish", handler.FinishInspection(service))
+	r.HandlePatch("/{id}/approve", handler.ApproveInspection(service))
+	r.HandlePatch("/{id}/reject", handler.RejectInspection(service))
+
+	// The second version of the lists returns a page envelope instead of an array.
+	v2 := s.router.SubRouter("/v2/inspections")
+	v2.HandleGet("", handler.ListInspections(service))
+	v2.HandleGet("/brigades/{brigadeID}", handler.ListInspectionsByBrigade(service))
+}
+
+// AddActTemplates registers the admin API of act templates. The service lets in only the users of Access.Admins.
+func (s *ServerBuilder) AddActTemplates(service *inspection.Service) {
+	r := s.router.SubRouter("/admin/act-templates")
+	r.HandleGet("", handler.GetActTemplates(service))
+	r.HandlePost("", handler.UploadActTemplate(service))
 }
 
 func (s *ServerBuilder) Build() goserver.Server {
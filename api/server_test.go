@@ -14,17 +14,30 @@ func TestInspectionRoutesAllowUnauthenticatedRequests(t *testing.T) {
 		Port: 80,
 	})
 	builder.AddInspections(nil)
+	builder.AddActTemplates(nil)
 
 	routes := []struct {
 		method string
 		path   string
 	}{
 		{method: http.MethodGet, path: "/inspections"},
+		{method: http.MethodGet, path: "/inspections/stats"},
+		{method: http.MethodGet, path: "/inspections/export"},
 		{method: http.MethodGet, path: "/inspections/1"},
+		{method: http.MethodGet, path: "/inspections/1/history"},
 		{method: http.MethodGet, path: "/inspections/task/1"},
 		{method: http.MethodGet, path: "/inspections/brigades/1"},
+		{method: http.MethodPost, path: "/inspections/acts/verify"},
 		{method: http.MethodPost, path: "/inspections/1/photo"},
+		{method: http.MethodPost, path: "/inspections/1/act/preview"},
+		{method: http.MethodPost, path: "/inspections/1/act/regenerate"},
 		{method: http.MethodPatch, path: "/inspections/1/finish"},
+		{method: http.MethodPatch, path: "/inspections/1/approve"},
+		{method: http.MethodPatch, path: "/inspections/1/reject"},
+		{method: http.MethodGet, path: "/v2/inspections"},
+		{method: http.MethodGet, path: "/v2/inspections/brigades/1"},
+		{method: http.MethodGet, path: "/admin/act-templates"},
+		{method: http.MethodPost, path: "/admin/act-templates"},
 	}
 
 	for _, route := range routes {
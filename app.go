@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"inspection-service/api"
 	"inspection-service/cluster/analyzer"
@@ -45,6 +46,7 @@ type App struct {
 
 	/* services */
 	inspectionService *inspection.Service
+	inspectionRelay   *inspection.Relay
 }
 
 func NewApp(mainCtx context.Context, log golog.Logger, settings config.Settings) *App {
@@ -86,7 +88,7 @@ func (a *App) InitDatabases(fs fs.FS, path string) (err error) {
 func (a *App) InitServices() error {
 	inspectionRepository := dbinspection.NewRepository(a.postgres)
 
-	inspectionPublisher := inspection.NewPublisher(a.mainCtx, a.inspectionProducer)
+	a.inspectionRelay = inspection.NewRelay(inspectionRepository, a.inspectionProducer)
 
 	httpClient := gohttp.NewClient(gohttp.WithTimeout(1 * time.Minute))
 
@@ -96,17 +98,46 @@ func (a *App) InitServices() error {
 	taskClient := task.NewClient(httpClient, a.settings.Cluster.TaskService)
 	brigadeClient := brigade.NewClient(httpClient, a.settings.Cluster.BrigadeService)
 
+	actSigner, err := inspection.NewActSigner(a.settings.Signing.Key)
+	if err != nil {
+		return fmt.Errorf("init act signer: %w", err)
+	}
+
+	if !actSigner.Enabled() {
+		if a.settings.Signing.Required {
+			return errors.New("ACT_SIGNING_KEY is not set, acts must be signed in this environment")
+		}
+
+		a.log.Errorf("ACT_SIGNING_KEY is not set, acts are not signed")
+	}
+
+	if len(a.settings.Access.Reviewers) == 0 {
+		a.log.Errorf("no reviewers are set in ACCESS_REVIEWERS, inspections cannot be approved or rejected")
+	}
+	if len(a.settings.Access.Admins) == 0 {
+		a.log.Errorf("no admins are set in ACCESS_ADMINS, act templates cannot be managed")
+	}
+
 	a.inspectionService = inspection.NewService(
 		inspectionRepository,
-		inspectionPublisher,
 		analyzerClient,
 		subscriberClient,
 		fileClient,
 		taskClient,
 		brigadeClient,
 		a.settings.Templates,
+		actSigner,
+		a.settings.ActNumbering,
+		a.settings.Access,
 	)
 
+	templatesCtx, cancelTemplatesCtx := context.WithTimeout(a.mainCtx, dbTimeout)
+	defer cancelTemplatesCtx()
+
+	if err = a.inspectionService.InitActTemplates(templatesCtx); err != nil {
+		return fmt.Errorf("init act templates: %w", err)
+	}
+
 	return nil
 }
 
@@ -114,13 +145,25 @@ func (a *App) InitServer() {
 	sb := api.NewServerBuilder(a.mainCtx, a.log, a.settings)
 	sb.AddDebug()
 	sb.AddInspections(a.inspectionService)
+	sb.AddActTemplates(a.inspectionService)
 
 	a.server = sb.Build()
 }
 
 func (a *App) Start() {
 	a.server.Start()
+	a.inspectionRelay.Start(a.mainCtx, a.log.WithTags("inspectionRelay"))
 	a.taskConsumer.Subscribe(a.inspectionService.SubscriberOnTaskEvent(a.mainCtx, a.log.WithTags("taskSubscriber")))
+
+	go a.backfillBrigades(a.log.WithTags("brigadeBackfill"))
+}
+
+// backfillBrigades fills the brigades of inspections started before migration 0008. Inspections left after a failure
+// are filled on the next start.
+func (a *App) backfillBrigades(log golog.Logger) {
+	if err := a.inspectionService.BackfillBrigades(a.mainCtx); err != nil {
+		log.Errorf("failed to backfill inspection brigades: %v", err)
+	}
 }
 
 func (a *App) Stop(ctx context.Context) {
@@ -134,6 +177,8 @@ func (a *App) Stop(ctx context.Context) {
 
 	a.server.Stop()
 
+	a.inspectionRelay.Stop()
+
 	producerCtx, cancelProducerCtx := context.WithTimeout(ctx, dbTimeout)
 	defer cancelProducerCtx()
 
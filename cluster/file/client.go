@@ -113,6 +113,39 @@ func (c *Client) GetByIDs(ctx goctx.Context, ids []int, page pagination.Paginati
 	return response, nil
 }
 
+// Download returns the content of a file by the URL the file service reported for it. The caller closes the content.
+func (c *Client) Download(ctx goctx.Context, fileURL string) (io.ReadCloser, error) {
+	rq, err := gohttp.NewRequest(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest: %w", err)
+	}
+
+	rs, err := c.client.Do(rq)
+	if err != nil {
+		if rs != nil && rs.Body != nil {
+			closeErr := rs.Body.Close()
+			err = errors.Join(err, closeErr)
+		}
+
+		return nil, fmt.Errorf("c.client.Do: %w", err)
+	}
+
+	if rs == nil {
+		return nil, errors.New("got nil response from server")
+	}
+
+	if rs.StatusCode != http.StatusOK {
+		if rs.Body != nil {
+			closeErr := rs.Body.Close()
+			err = errors.Join(err, closeErr)
+		}
+
+		return nil, errors.Join(err, fmt.Errorf("got status code %d", rs.StatusCode))
+	}
+
+	return rs.Body, nil
+}
+
 func filesQuery(ids []int, page pagination.Pagination) string {
 	values := make(url.Values)
 	for _, id := range ids {
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/sunshineOfficial/golib/config"
 	"github.com/sunshineOfficial/golib/golog"
@@ -23,6 +25,49 @@ func Get(log golog.Logger) (Settings, error) {
 	}
 
 	settings.Databases.Postgres = fmt.Sprintf(settings.Databases.Postgres, os.Getenv("POSTGRES_PASSWORD"))
+	settings.Signing.Key = os.Getenv("ACT_SIGNING_KEY")
+
+	reviewers, err := userIDsFromEnv("ACCESS_REVIEWERS")
+	if err != nil {
+		return Settings{}, err
+	}
+	if reviewers != nil {
+		settings.Access.Reviewers = reviewers
+	}
+
+	admins, err := userIDsFromEnv("ACCESS_ADMINS")
+	if err != nil {
+		return Settings{}, err
+	}
+	if admins != nil {
+		settings.Access.Admins = admins
+	}
 
 	return settings, nil
 }
+
+// userIDsFromEnv reads a comma-separated list of user IDs from the environment variable. It returns nil
+// when the variable is not set.
+func userIDsFromEnv(name string) ([]int, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, nil
+	}
+
+	ids := make([]int, 0)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid user id %q", name, field)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
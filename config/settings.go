@@ -1,10 +1,14 @@
 package config
 
 type Settings struct {
-	Port      int       `json:"port"`
-	Databases Databases `json:"databases"`
-	Cluster   Cluster   `json:"cluster"`
-	Templates Templates `json:"templates"`
+	Port         int          `json:"port"`
+	Databases    Databases    `json:"databases"`
+	Cluster      Cluster      `json:"cluster"`
+	Templates    Templates    `json:"templates"`
+	Signing      Signing      `json:"signing"`
+	ActNumbering ActNumbering `json:"actNumbering"`
+	Photos       Photos       `json:"photos"`
+	Access       Access       `json:"access"`
 }
 
 type Databases struct {
@@ -30,7 +34,38 @@ type Cluster struct {
 	TaskService       string `json:"taskService"`
 }
 
+// Templates holds the bundled act templates, which become the first versions of the act templates of inspection types
+// on startup, and the font of PDF acts.
 type Templates struct {
 	Universal string `json:"universal"`
 	Control   string `json:"control"`
+	Font      string `json:"font"`
+}
+
+// Signing holds the key that signs act files: a base64 Ed25519 seed read from ACT_SIGNING_KEY.
+// Acts are not signed without it; Required deployments fail to start without the key instead.
+type Signing struct {
+	Key      string `json:"-"`
+	Required bool   `json:"required"`
+}
+
+// ActNumbering holds the code of the branch the service issues acts for. Acts of each branch are numbered separately
+// and their numbers are prefixed with the code; without it acts are numbered across the whole service.
+type ActNumbering struct {
+	Branch string `json:"branch"`
+}
+
+// Access lists the users with roles beyond an inspector's. Reviewers approve and reject submitted inspections,
+// admins manage act templates. The lists are set per deployment: ACCESS_REVIEWERS and ACCESS_ADMINS hold
+// comma-separated user IDs that replace the lists of the config. Nobody has a role when a list is empty.
+type Access struct {
+	Reviewers []int `json:"reviewers"`
+	Admins    []int `json:"admins"`
+}
+
+// Photos limits the photos attached to inspections. MaxSize is the size of a photo in bytes, AllowedTypes are MIME types
+// detected from the content of a photo. Zero values disable the limits.
+type Photos struct {
+	MaxSize      int64    `json:"maxSize"`
+	AllowedTypes []string `json:"allowedTypes"`
 }
@@ -0,0 +1,135 @@
+package inspection
+
+import (
+	"fmt"
+	"inspection-service/service/inspection"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// filterQuery collects where conditions of an inspection list query. Conditions are fixed strings,
+// all values are passed as named arguments.
+type filterQuery struct {
+	conditions []string
+	args       map[string]any
+}
+
+func newFilterQuery(f inspection.Filter) filterQuery {
+	q := filterQuery{args: make(map[string]any)}
+
+	addIn(&q, "status", "statuses", f.Statuses)
+	addIn(&q, "type", "types", f.Types)
+	addIn(&q, "resolution", "resolutions", f.Resolutions)
+	addIn(&q, "task_id", "task_ids", f.TaskIDs)
+
+	if f.DeviceID != nil {
+		q.add("exists (select 1 from inspected_devices d where d.inspection_id = inspections.id and d.device_id = :device_id)", "device_id", *f.DeviceID)
+	}
+
+	addOptional(&q, "inspect_at >= :inspect_at_from", "inspect_at_from", f.InspectAtFrom)
+	addOptional(&q, "inspect_at < :inspect_at_to", "inspect_at_to", f.InspectAtTo)
+	addOptional(&q, "energy_action_at >= :energy_action_at_from", "energy_action_at_from", f.EnergyActionAtFrom)
+	addOptional(&q, "energy_action_at < :energy_action_at_to", "energy_action_at_to", f.EnergyActionAtTo)
+	addOptional(&q, "created_at >= :created_at_from", "created_at_from", f.CreatedAtFrom)
+	addOptional(&q, "created_at < :created_at_to", "created_at_to", f.CreatedAtTo)
+
+	addOptional(&q, "is_restriction_checked = :is_restriction_checked", "is_restriction_checked", f.IsRestrictionChecked)
+	addOptional(&q, "is_violation_detected = :is_violation_detected", "is_violation_detected", f.IsViolationDetected)
+	addOptional(&q, "is_expense_available = :is_expense_available", "is_expense_available", f.IsExpenseAvailable)
+	addOptional(&q, "is_unauthorized_consumers = :is_unauthorized_consumers", "is_unauthorized_consumers", f.IsUnauthorizedConsumers)
+
+	return q
+}
+
+func (q *filterQuery) add(condition, name string, value any) {
+	q.conditions = append(q.conditions, condition)
+	q.args[name] = value
+}
+
+func addOptional[T any](q *filterQuery, condition, name string, value *T) {
+	if value != nil {
+		q.add(condition, name, *value)
+	}
+}
+
+func addIn[T any](q *filterQuery, column, name string, values []T) {
+	if len(values) != 0 {
+		q.add(fmt.Sprintf("%s in (:%s)", column, name), name, values)
+	}
+}
+
+var sortColumns = map[inspection.SortKey]string{
+	inspection.SortByID:        "id",
+	inspection.SortByCreatedAt: "created_at",
+	inspection.SortByUpdatedAt: "updated_at",
+	inspection.SortByInspectAt: "inspect_at",
+}
+
+// addKeyset limits the query to rows that follow the cursor in the order of a normalized page.
+func (q *filterQuery) addKeyset(page inspection.Page, after *inspection.Cursor) {
+	if after == nil {
+		return
+	}
+
+	op := ">"
+	if page.Direction == inspection.SortDesc {
+		op = "<"
+	}
+
+	q.args["cursor_id"] = after.ID
+
+	switch {
+	case page.SortBy == inspection.SortByID:
+		q.conditions = append(q.conditions, fmt.Sprintf("id %s :cursor_id", op))
+	case after.Value == nil:
+		// Rows with null values are the last ones, so only they can follow a null cursor.
+		q.conditions = append(q.conditions, fmt.Sprintf("(%s is null and id %s :cursor_id)", sortColumns[page.SortBy], op))
+	default:
+		column := sortColumns[page.SortBy]
+		q.conditions = append(q.conditions, fmt.Sprintf("((%s, id) %s (:cursor_value, :cursor_id) or %s is null)", column, op, column))
+		q.args["cursor_value"] = *after.Value
+	}
+}
+
+// orderBy returns the order clause of a normalized page.
+func orderBy(page inspection.Page) string {
+	direction := "asc"
+	if page.Direction == inspection.SortDesc {
+		direction = "desc"
+	}
+
+	if page.SortBy == inspection.SortByID {
+		return "id " + direction
+	}
+
+	return fmt.Sprintf("%s %s nulls last, id %s", sortColumns[page.SortBy], direction, direction)
+}
+
+func (q filterQuery) where() string {
+	if len(q.conditions) == 0 {
+		return "true"
+	}
+
+	return strings.Join(q.conditions, " and ")
+}
+
+// bind puts the conditions into the first %s of query, which is in its where clause, and clauses into the following ones.
+// It returns the query with positional arguments for the current driver.
+func (q filterQuery) bind(db sqlx.ExtContext, query string, args map[string]any, clauses ...any) (string, []any, error) {
+	for name, value := range q.args {
+		args[name] = value
+	}
+
+	query, bound, err := sqlx.Named(fmt.Sprintf(query, append([]any{q.where()}, clauses...)...), args)
+	if err != nil {
+		return "", nil, fmt.Errorf("sqlx.Named: %w", err)
+	}
+
+	query, bound, err = sqlx.In(query, bound...)
+	if err != nil {
+		return "", nil, fmt.Errorf("sqlx.In: %w", err)
+	}
+
+	return db.Rebind(query), bound, nil
+}
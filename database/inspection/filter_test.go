@@ -0,0 +1,72 @@
+package inspection
+
+import (
+	"inspection-service/service/inspection"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestFilterQueryBindsConditionsAsArguments(t *testing.T) {
+	deviceID := 11
+	isViolationDetected := true
+	from := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	query, args, err := newFilterQuery(inspection.Filter{
+		Types:               []inspection.Type{inspection.TypeUnauthorizedConnection},
+		Statuses:            []inspection.Status{inspection.StatusSubmitted, inspection.StatusApproved},
+		DeviceID:            &deviceID,
+		InspectAtFrom:       &from,
+		IsViolationDetected: &isViolationDetected,
+	}).bind(sqlx.NewDb(nil, "postgres"), "select id from inspections where %s limit :limit", map[string]any{"limit": 10})
+	if err != nil {
+		t.Fatalf("bind returned error: %v", err)
+	}
+
+	want := "select id from inspections where status in ($1, $2) and type in ($3) and " +
+		"exists (select 1 from inspected_devices d where d.inspection_id = inspections.id and d.device_id = $4) and " +
+		"inspect_at >= $5 and is_violation_detected = $6 limit $7"
+	if query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+	if len(args) != 7 {
+		t.Fatalf("len(args) = %d, want 7", len(args))
+	}
+	if args[3] != deviceID {
+		t.Fatalf("args[3] = %v, want %d", args[3], deviceID)
+	}
+}
+
+func TestFilterQueryWithoutConditionsMatchesAll(t *testing.T) {
+	query, _, err := newFilterQuery(inspection.Filter{}).bind(sqlx.NewDb(nil, "postgres"), "select id from inspections where %s", map[string]any{})
+	if err != nil {
+		t.Fatalf("bind returned error: %v", err)
+	}
+
+	if query != "select id from inspections where true" {
+		t.Fatalf("query = %q", query)
+	}
+}
+
+func TestFilterQueryAddsKeysetAfterCursor(t *testing.T) {
+	value := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	page := inspection.Page{SortBy: inspection.SortByInspectAt, Direction: inspection.SortDesc}
+
+	q := newFilterQuery(inspection.Filter{})
+	q.addKeyset(page, &inspection.Cursor{SortBy: page.SortBy, Direction: page.Direction, Value: &value, ID: 42})
+
+	query, args, err := q.bind(sqlx.NewDb(nil, "postgres"), "select id from inspections where %s order by %s", map[string]any{}, orderBy(page))
+	if err != nil {
+		t.Fatalf("bind returned error: %v", err)
+	}
+
+	want := "select id from inspections where ((inspect_at, id) < ($1, $2) or inspect_at is null) " +
+		"order by inspect_at desc nulls last, id desc"
+	if query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+	if args[0] != value || args[1] != 42 {
+		t.Fatalf("args = %v, want [%s 42]", args, value)
+	}
+}
@@ -2,9 +2,25 @@ package inspection
 
 import (
 	"inspection-service/service/inspection"
+
+	"github.com/shopspring/decimal"
 )
 
 func MapFromDB(i Inspection) inspection.Inspection {
+	var actNumber *inspection.ActNumber
+	if i.ActNumber != nil {
+		actNumber = &inspection.ActNumber{Number: *i.ActNumber}
+		if i.ActKind != nil {
+			actNumber.Kind = *i.ActKind
+		}
+		if i.ActYear != nil {
+			actNumber.Year = *i.ActYear
+		}
+		if i.ActBranch != nil {
+			actNumber.Branch = *i.ActBranch
+		}
+	}
+
 	return inspection.Inspection{
 		ID:                      i.ID,
 		TaskID:                  i.TaskID,
@@ -25,7 +41,10 @@ func MapFromDB(i Inspection) inspection.Inspection {
 		UnauthorizedExplanation: i.UnauthorizedExplanation,
 		InspectAt:               i.InspectAt,
 		EnergyActionAt:          i.EnergyActionAt,
+		ActNumber:               actNumber,
 		Attachments:             MapAttachmentsSliceFromDB(i.Attachments),
+		InspectedDevices:        MapInspectedDevicesSliceFromDB(i.InspectedDevices),
+		UnassignedSeals:         MapInspectedSealsSliceFromDB(i.UnassignedSeals),
 		CreatedAt:               i.CreatedAt,
 		UpdatedAt:               i.UpdatedAt,
 	}
@@ -62,12 +81,22 @@ func MapFinishInspectionRequestToDB(r inspection.FinishInspectionRequest) Finish
 }
 
 func MapAttachmentFromDB(a Attachment) inspection.Attachment {
+	var sha256 string
+	if a.SHA256 != nil {
+		sha256 = *a.SHA256
+	}
+
 	return inspection.Attachment{
-		ID:           a.ID,
-		InspectionID: a.InspectionID,
-		Type:         inspection.AttachmentType(a.Type),
-		FileID:       a.FileID,
-		CreatedAt:    a.CreatedAt,
+		ID:            a.ID,
+		InspectionID:  a.InspectionID,
+		Type:          inspection.AttachmentType(a.Type),
+		FileID:        a.FileID,
+		ActTemplateID: a.ActTemplateID,
+		SupersededAt:  a.SupersededAt,
+		Reason:        a.Reason,
+		Signature:     a.Signature,
+		SHA256:        sha256,
+		CreatedAt:     a.CreatedAt,
 	}
 }
 
@@ -81,14 +110,26 @@ func MapAttachmentsSliceFromDB(attachments []Attachment) []inspection.Attachment
 }
 
 func MapInspectedDeviceFromDB(d InspectedDevice) inspection.InspectedDevice {
-	return inspection.InspectedDevice{
-		ID:           d.ID,
-		DeviceID:     d.DeviceID,
-		InspectionID: d.InspectionID,
-		Value:        d.Value,
-		Consumption:  d.Consumption,
-		CreatedAt:    d.CreatedAt,
+	device := inspection.InspectedDevice{
+		ID:             d.ID,
+		DeviceID:       d.DeviceID,
+		InspectionID:   d.InspectionID,
+		Value:          d.Value,
+		IsReplaced:     d.IsReplaced,
+		IsRollover:     d.IsRollover,
+		InspectedSeals: MapInspectedSealsSliceFromDB(d.InspectedSeals),
+		CreatedAt:      d.CreatedAt,
 	}
+
+	if d.Consumption.Valid {
+		device.Consumption = &d.Consumption.Decimal
+	}
+	if d.DeclaredConsumption.Valid {
+		device.DeclaredConsumption = &d.DeclaredConsumption.Decimal
+		device.HasDiscrepancy = d.Consumption.Valid && !d.DeclaredConsumption.Decimal.Equal(d.Consumption.Decimal)
+	}
+
+	return device
 }
 
 func MapInspectedDevicesSliceFromDB(devices []InspectedDevice) []inspection.InspectedDevice {
@@ -100,18 +141,44 @@ func MapInspectedDevicesSliceFromDB(devices []InspectedDevice) []inspection.Insp
 	return result
 }
 
-func MapInspectedSealRequestToDB(r inspection.InspectedSealRequest, inspectionID int) InspectedSeal {
+func MapInspectedSealFromDB(s InspectedSeal) inspection.InspectedSeal {
+	seal := inspection.InspectedSeal{
+		ID:           s.ID,
+		SealID:       s.SealID,
+		InspectionID: s.InspectionID,
+		IsBroken:     s.IsBroken,
+		CreatedAt:    s.CreatedAt,
+	}
+
+	if s.DeviceID != nil {
+		seal.DeviceID = *s.DeviceID
+	}
+
+	return seal
+}
+
+func MapInspectedSealsSliceFromDB(seals []InspectedSeal) []inspection.InspectedSeal {
+	result := make([]inspection.InspectedSeal, 0, len(seals))
+	for _, seal := range seals {
+		result = append(result, MapInspectedSealFromDB(seal))
+	}
+
+	return result
+}
+
+func MapInspectedSealRequestToDB(r inspection.InspectedSealRequest, inspectionID, deviceID int) InspectedSeal {
 	return InspectedSeal{
 		SealID:       r.SealID,
+		DeviceID:     &deviceID,
 		InspectionID: inspectionID,
 		IsBroken:     r.IsBroken,
 	}
 }
 
-func MapInspectedSealRequestsSliceToDB(requests []inspection.InspectedSealRequest, inspectionID int) []InspectedSeal {
+func MapInspectedSealRequestsSliceToDB(requests []inspection.InspectedSealRequest, inspectionID, deviceID int) []InspectedSeal {
 	result := make([]InspectedSeal, 0, len(requests))
 	for _, request := range requests {
-		result = append(result, MapInspectedSealRequestToDB(request, inspectionID))
+		result = append(result, MapInspectedSealRequestToDB(request, inspectionID, deviceID))
 	}
 
 	return result
@@ -122,8 +189,14 @@ func MapInspectedDeviceRequestToDB(r inspection.InspectedDeviceRequest, inspecti
 		DeviceID:     r.DeviceID,
 		InspectionID: inspectionID,
 		Value:        r.Value,
-		Consumption:  r.Consumption,
-	}, MapInspectedSealRequestsSliceToDB(r.InspectedSeals, inspectionID)
+		Consumption:  nullDecimal(r.ComputedConsumption),
+		DeclaredConsumption: decimal.NullDecimal{
+			Decimal: r.Consumption,
+			Valid:   true,
+		},
+		IsReplaced: r.IsReplaced,
+		IsRollover: r.IsRollover,
+	}, MapInspectedSealRequestsSliceToDB(r.InspectedSeals, inspectionID, r.DeviceID)
 }
 
 func MapInspectedDeviceRequestsSliceToDB(requests []inspection.InspectedDeviceRequest, inspectionID int) ([]InspectedDevice, []InspectedSeal) {
@@ -140,3 +213,179 @@ func MapInspectedDeviceRequestsSliceToDB(requests []inspection.InspectedDeviceRe
 
 	return devices, seals
 }
+
+func MapEventFromDB(e Event) inspection.OutboxEvent {
+	var inspectionID int
+	if e.InspectionID != nil {
+		inspectionID = *e.InspectionID
+	}
+
+	return inspection.OutboxEvent{
+		ID:           e.ID,
+		Type:         inspection.EventType(e.Type),
+		InspectionID: inspectionID,
+		Payload:      e.Payload,
+		Attempts:     e.Attempts,
+		CreatedAt:    e.CreatedAt,
+	}
+}
+
+func MapEventsSliceFromDB(events []Event) []inspection.OutboxEvent {
+	result := make([]inspection.OutboxEvent, 0, len(events))
+	for _, e := range events {
+		result = append(result, MapEventFromDB(e))
+	}
+
+	return result
+}
+
+func MapActUploadFromDB(u ActUpload) inspection.ActUpload {
+	return inspection.ActUpload{
+		FileID:       u.FileID,
+		InspectionID: u.InspectionID,
+		Format:       inspection.ActFormat(u.Format),
+		SHA256:       u.SHA256,
+		DrawnAt:      u.DrawnAt,
+		Reason:       u.Reason,
+		CreatedAt:    u.CreatedAt,
+	}
+}
+
+func MapActUploadsSliceFromDB(uploads []ActUpload) []inspection.ActUpload {
+	result := make([]inspection.ActUpload, 0, len(uploads))
+	for _, u := range uploads {
+		result = append(result, MapActUploadFromDB(u))
+	}
+
+	return result
+}
+
+func MapIdempotencyKeyFromDB(k IdempotencyKey) inspection.IdempotencyKey {
+	var fileID int
+	if k.FileID != nil {
+		fileID = *k.FileID
+	}
+
+	return inspection.IdempotencyKey{
+		Key:          k.Key,
+		InspectionID: k.InspectionID,
+		FileID:       fileID,
+		Response:     k.Response,
+		CreatedAt:    k.CreatedAt,
+	}
+}
+
+func MapStatusChangeToDB(c inspection.StatusChange) StatusChange {
+	var userID *int
+	if c.UserID != 0 {
+		userID = &c.UserID
+	}
+
+	return StatusChange{
+		InspectionID: c.InspectionID,
+		FromStatus:   (*int)(c.From),
+		ToStatus:     int(c.To),
+		UserID:       userID,
+		Comment:      c.Comment,
+	}
+}
+
+func MapStatusChangeFromDB(c StatusChange) inspection.StatusChange {
+	var userID int
+	if c.UserID != nil {
+		userID = *c.UserID
+	}
+
+	return inspection.StatusChange{
+		ID:           c.ID,
+		InspectionID: c.InspectionID,
+		From:         (*inspection.Status)(c.FromStatus),
+		To:           inspection.Status(c.ToStatus),
+		UserID:       userID,
+		Comment:      c.Comment,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+func MapStatusChangesSliceFromDB(changes []StatusChange) []inspection.StatusChange {
+	result := make([]inspection.StatusChange, 0, len(changes))
+	for _, c := range changes {
+		result = append(result, MapStatusChangeFromDB(c))
+	}
+
+	return result
+}
+
+func MapStatsGroupsFromDB(groups []StatsGroup) []inspection.StatsGroup {
+	result := make([]inspection.StatsGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, inspection.StatsGroup{
+			Type:        (*inspection.Type)(g.Type),
+			Resolution:  (*inspection.Resolution)(g.Resolution),
+			Status:      (*inspection.Status)(g.Status),
+			BrigadeID:   g.BrigadeID,
+			Period:      g.Period,
+			StatsTotals: inspection.NewStatsTotals(g.Count, g.ViolationCount, g.FinishedCount, g.AverageDurationSeconds),
+		})
+	}
+
+	return result
+}
+
+func MapActTemplateFromDB(t ActTemplate) inspection.ActTemplate {
+	var createdBy int
+	if t.CreatedBy != nil {
+		createdBy = *t.CreatedBy
+	}
+
+	return inspection.ActTemplate{
+		ID:        t.ID,
+		Type:      inspection.Type(t.Type),
+		Version:   t.Version,
+		FileName:  t.FileName,
+		CreatedBy: createdBy,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func MapActTemplatesSliceFromDB(templates []ActTemplate) []inspection.ActTemplate {
+	result := make([]inspection.ActTemplate, 0, len(templates))
+	for _, t := range templates {
+		result = append(result, MapActTemplateFromDB(t))
+	}
+
+	return result
+}
+
+func MapSignaturesSliceToDB(signatures []inspection.Signature, inspectionID int) []Signature {
+	result := make([]Signature, 0, len(signatures))
+	for _, s := range signatures {
+		result = append(result, Signature{
+			InspectionID: inspectionID,
+			Signer:       string(s.Signer),
+			Image:        s.Image,
+		})
+	}
+
+	return result
+}
+
+func MapSignaturesSliceFromDB(signatures []Signature) []inspection.Signature {
+	result := make([]inspection.Signature, 0, len(signatures))
+	for _, s := range signatures {
+		result = append(result, inspection.Signature{
+			Signer: inspection.Signer(s.Signer),
+			Image:  s.Image,
+		})
+	}
+
+	return result
+}
+
+func nullDecimal(d *decimal.Decimal) decimal.NullDecimal {
+	if d == nil {
+		return decimal.NullDecimal{}
+	}
+
+	return decimal.NullDecimal{Decimal: *d, Valid: true}
+}
@@ -1,8 +1,11 @@
 package inspection
 
 import (
+	"inspection-service/service/inspection"
 	"testing"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 func TestMapFromDBIncludesAttachments(t *testing.T) {
@@ -43,3 +46,102 @@ func TestMapFromDBIncludesAttachments(t *testing.T) {
 		t.Fatalf("attachment.CreatedAt = %s, want %s", attachment.CreatedAt, createdAt)
 	}
 }
+
+func TestMapFromDBIncludesActNumber(t *testing.T) {
+	number, kind, year, branch := 15, 2, 2026, "ЮЗ"
+
+	got := MapFromDB(Inspection{ID: 10, ActNumber: &number, ActKind: &kind, ActYear: &year, ActBranch: &branch})
+	if got.ActNumber == nil || *got.ActNumber != (inspection.ActNumber{Kind: 2, Year: 2026, Branch: "ЮЗ", Number: 15}) {
+		t.Fatalf("got.ActNumber = %+v, want ЮЗ-15 of 2026", got.ActNumber)
+	}
+
+	if got = MapFromDB(Inspection{ID: 10}); got.ActNumber != nil {
+		t.Fatalf("got.ActNumber = %+v, want nil for an inspection without a number", got.ActNumber)
+	}
+}
+
+func TestMapInspectedDeviceFromDBFlagsConsumptionDiscrepancy(t *testing.T) {
+	got := MapInspectedDeviceFromDB(InspectedDevice{
+		Value:               decimal.RequireFromString("150"),
+		Consumption:         decimal.NewNullDecimal(decimal.RequireFromString("50")),
+		DeclaredConsumption: decimal.NewNullDecimal(decimal.RequireFromString("45")),
+	})
+
+	if got.DeclaredConsumption == nil || !got.DeclaredConsumption.Equal(decimal.RequireFromString("45")) {
+		t.Fatalf("got.DeclaredConsumption = %v, want 45", got.DeclaredConsumption)
+	}
+	if !got.HasDiscrepancy {
+		t.Fatal("got.HasDiscrepancy = false, want true")
+	}
+}
+
+func TestMapInspectedDeviceFromDBLeavesConsumptionWithoutPreviousReadingEmpty(t *testing.T) {
+	got := MapInspectedDeviceFromDB(InspectedDevice{
+		Value:               decimal.RequireFromString("150"),
+		DeclaredConsumption: decimal.NewNullDecimal(decimal.RequireFromString("45")),
+	})
+
+	if got.Consumption != nil {
+		t.Fatalf("got.Consumption = %v, want nil", got.Consumption)
+	}
+	if got.HasDiscrepancy {
+		t.Fatal("got.HasDiscrepancy = true, want false without a computed consumption")
+	}
+}
+
+func TestMapFromDBNestsSealsUnderDevices(t *testing.T) {
+	deviceID := 11
+
+	got := MapFromDB(Inspection{
+		ID: 10,
+		InspectedDevices: []InspectedDevice{
+			{
+				ID:           1,
+				DeviceID:     deviceID,
+				InspectionID: 10,
+				InspectedSeals: []InspectedSeal{
+					{ID: 2, SealID: 21, DeviceID: &deviceID, InspectionID: 10, IsBroken: true},
+				},
+			},
+		},
+	})
+
+	if len(got.InspectedDevices) != 1 {
+		t.Fatalf("len(got.InspectedDevices) = %d, want 1", len(got.InspectedDevices))
+	}
+
+	seals := got.InspectedDevices[0].InspectedSeals
+	if len(seals) != 1 {
+		t.Fatalf("len(seals) = %d, want 1", len(seals))
+	}
+	if seals[0].SealID != 21 || seals[0].DeviceID != deviceID || !seals[0].IsBroken {
+		t.Fatalf("seals[0] = %+v, want seal 21 of device 11 broken", seals[0])
+	}
+}
+
+func TestMapFromDBKeepsUnassignedSeals(t *testing.T) {
+	got := MapFromDB(Inspection{
+		ID:              10,
+		UnassignedSeals: []InspectedSeal{{ID: 3, SealID: 22, InspectionID: 10}},
+	})
+
+	if len(got.UnassignedSeals) != 1 || got.UnassignedSeals[0].SealID != 22 || got.UnassignedSeals[0].DeviceID != 0 {
+		t.Fatalf("got.UnassignedSeals = %+v, want seal 22 without device", got.UnassignedSeals)
+	}
+}
+
+func TestMapInspectedDeviceRequestsSliceToDBLinksSealsToDevice(t *testing.T) {
+	_, seals := MapInspectedDeviceRequestsSliceToDB([]inspection.InspectedDeviceRequest{
+		{DeviceID: 11, InspectedSeals: []inspection.InspectedSealRequest{{SealID: 21}}},
+		{DeviceID: 12, InspectedSeals: []inspection.InspectedSealRequest{{SealID: 22}}},
+	}, 10)
+
+	if len(seals) != 2 {
+		t.Fatalf("len(seals) = %d, want 2", len(seals))
+	}
+	for i, want := range []int{11, 12} {
+		if seals[i].DeviceID == nil || *seals[i].DeviceID != want {
+			t.Fatalf("seals[%d].DeviceID = %v, want %d", i, seals[i].DeviceID, want)
+		}
+	}
+}
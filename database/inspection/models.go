@@ -51,7 +51,13 @@ type Inspection struct {
 	UnauthorizedExplanation *string    `db:"unauthorized_explanation"`
 	InspectAt               *time.Time `db:"inspect_at"`
 	EnergyActionAt          *time.Time `db:"energy_action_at"`
+	ActNumber               *int       `db:"act_number"`
+	ActKind                 *int       `db:"act_kind"`
+	ActYear                 *int       `db:"act_year"`
+	ActBranch               *string    `db:"act_branch"`
 	Attachments             []Attachment
+	InspectedDevices        []InspectedDevice
+	UnassignedSeals         []InspectedSeal
 	CreatedAt               time.Time `db:"created_at"`
 	UpdatedAt               time.Time `db:"updated_at"`
 }
@@ -76,17 +82,22 @@ type FinishInspectionRequest struct {
 }
 
 type InspectedDevice struct {
-	ID           int             `db:"id"`
-	DeviceID     int             `db:"device_id"`
-	InspectionID int             `db:"inspection_id"`
-	Value        decimal.Decimal `db:"value"`
-	Consumption  decimal.Decimal `db:"consumption"`
-	CreatedAt    time.Time       `db:"created_at"`
+	ID                  int                 `db:"id"`
+	DeviceID            int                 `db:"device_id"`
+	InspectionID        int                 `db:"inspection_id"`
+	Value               decimal.Decimal     `db:"value"`
+	Consumption         decimal.NullDecimal `db:"consumption"`
+	DeclaredConsumption decimal.NullDecimal `db:"declared_consumption"`
+	IsReplaced          bool                `db:"is_replaced"`
+	IsRollover          bool                `db:"is_rollover"`
+	CreatedAt           time.Time           `db:"created_at"`
+	InspectedSeals      []InspectedSeal
 }
 
 type InspectedSeal struct {
 	ID           int       `db:"id"`
 	SealID       int       `db:"seal_id"`
+	DeviceID     *int      `db:"device_id"`
 	InspectionID int       `db:"inspection_id"`
 	IsBroken     bool      `db:"is_broken"`
 	CreatedAt    time.Time `db:"created_at"`
@@ -98,9 +109,80 @@ type AttachmentType struct {
 }
 
 type Attachment struct {
+	ID            int        `db:"id"`
+	InspectionID  int        `db:"inspection_id"`
+	Type          int        `db:"type"`
+	FileID        int        `db:"file_id"`
+	ActTemplateID *int       `db:"act_template_id"`
+	SupersededAt  *time.Time `db:"superseded_at"`
+	Reason        *string    `db:"reason"`
+	Signature     []byte     `db:"signature"`
+	SHA256        *string    `db:"sha256"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+type Event struct {
 	ID           int       `db:"id"`
-	InspectionID int       `db:"inspection_id"`
 	Type         int       `db:"type"`
+	InspectionID *int      `db:"inspection_id"`
+	Payload      []byte    `db:"payload"`
+	Attempts     int       `db:"attempts"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type ActUpload struct {
 	FileID       int       `db:"file_id"`
+	InspectionID int       `db:"inspection_id"`
+	Format       string    `db:"format"`
+	SHA256       string    `db:"sha256"`
+	DrawnAt      time.Time `db:"drawn_at"`
+	Reason       *string   `db:"reason"`
 	CreatedAt    time.Time `db:"created_at"`
 }
+
+type IdempotencyKey struct {
+	Key          string    `db:"key"`
+	InspectionID int       `db:"inspection_id"`
+	FileID       *int      `db:"file_id"`
+	Response     []byte    `db:"response"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type StatusChange struct {
+	ID           int       `db:"id"`
+	InspectionID int       `db:"inspection_id"`
+	FromStatus   *int      `db:"from_status"`
+	ToStatus     int       `db:"to_status"`
+	UserID       *int      `db:"user_id"`
+	Comment      *string   `db:"comment"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type ActTemplate struct {
+	ID        int       `db:"id"`
+	Type      int       `db:"type"`
+	Version   int       `db:"version"`
+	FileName  string    `db:"file_name"`
+	CreatedBy *int      `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Signature struct {
+	ID           int       `db:"id"`
+	InspectionID int       `db:"inspection_id"`
+	Signer       string    `db:"signer"`
+	Image        []byte    `db:"image"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type StatsGroup struct {
+	Type                   *int       `db:"type"`
+	Resolution             *int       `db:"resolution"`
+	Status                 *int       `db:"status"`
+	BrigadeID              *int       `db:"brigade_id"`
+	Period                 *time.Time `db:"period"`
+	Count                  int        `db:"count"`
+	ViolationCount         int        `db:"violation_count"`
+	FinishedCount          int        `db:"finished_count"`
+	AverageDurationSeconds float64    `db:"average_duration_seconds"`
+}
@@ -2,43 +2,218 @@ package inspection
 
 import (
 	"context"
+	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"inspection-service/service/inspection"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/sunshineOfficial/golib/pagination"
 )
 
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx.
+type queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+}
+
 type Repository struct {
-	db *sqlx.DB
+	conn *sqlx.DB
+	db   queryer
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{
-		db: db,
+		conn: db,
+		db:   db,
 	}
 }
 
+// WithTx runs fn with a repository bound to a single transaction. The transaction is committed when fn returns nil
+// and rolled back otherwise. Nested calls reuse the outer transaction.
+func (r *Repository) WithTx(ctx context.Context, fn func(repository inspection.Repository) error) error {
+	return r.inTx(ctx, nil, func(tx *Repository) error {
+		return fn(tx)
+	})
+}
+
+// snapshot are the options of read-only transactions whose queries have to see the same data.
+var snapshot = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+func (r *Repository) inTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Repository) error) (err error) {
+	if r.conn == nil {
+		return fn(r)
+	}
+
+	tx, err := r.conn.BeginTxx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("r.conn.BeginTxx: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = errors.Join(err, fmt.Errorf("tx.Rollback: %w", rollbackErr))
+			}
+		}
+	}()
+
+	if err = fn(&Repository{db: tx}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	return nil
+}
+
 //go:embed sql/get_all.sql
 var getAllSQL string
 
-func (r *Repository) GetAll(ctx context.Context, page pagination.Pagination, sort inspection.SortDirection) ([]inspection.Inspection, error) {
+//go:embed sql/count.sql
+var countSQL string
+
+// GetAll returns a page of inspections that match the filter with the total count of the filtered ones.
+// The page must be normalized. Both are read in one snapshot, so the count agrees with the page.
+func (r *Repository) GetAll(ctx context.Context, filter inspection.Filter, page inspection.Page) (inspection.InspectionList, error) {
+	var list inspection.InspectionList
+	err := r.inTx(ctx, snapshot, func(tx *Repository) error {
+		var total int
+		query, args, err := newFilterQuery(filter).bind(tx.db, countSQL, map[string]any{})
+		if err != nil {
+			return fmt.Errorf("bind count filter: %w", err)
+		}
+
+		err = tx.db.GetContext(ctx, &total, query, args...)
+		if err != nil {
+			return fmt.Errorf("r.db.GetContext: %w", err)
+		}
+
+		list, err = tx.GetPage(ctx, filter, page)
+		if err != nil {
+			return err
+		}
+
+		list.Total = total
+
+		return nil
+	})
+	if err != nil {
+		return inspection.InspectionList{}, err
+	}
+
+	return list, nil
+}
+
+// GetPage is GetAll without the total count, for callers that walk through all pages.
+func (r *Repository) GetPage(ctx context.Context, filter inspection.Filter, page inspection.Page) (inspection.InspectionList, error) {
+	after, err := page.After()
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("page.After: %w", err)
+	}
+
+	// One extra row shows whether there is a next page.
+	var limit *int
+	if page.Limit > 0 {
+		extended := page.Limit + 1
+		limit = &extended
+	}
+
+	pageQuery := newFilterQuery(filter)
+	pageQuery.addKeyset(page, after)
+
+	query, args, err := pageQuery.bind(r.db, getAllSQL, map[string]any{"limit": limit, "offset": page.Offset}, orderBy(page))
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("bind filter: %w", err)
+	}
+
 	var inspections []Inspection
-	err := r.db.SelectContext(ctx, &inspections, getAllSQL, sort, page.LimitArg(), page.Offset)
+	err = r.db.SelectContext(ctx, &inspections, query, args...)
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("r.db.SelectContext: %w", err)
+	}
+
+	hasNext := limit != nil && len(inspections) > page.Limit
+	if hasNext {
+		inspections = inspections[:page.Limit]
+	}
+
+	err = r.hydrate(ctx, inspections)
+	if err != nil {
+		return inspection.InspectionList{}, fmt.Errorf("hydrate: %w", err)
+	}
+
+	list := inspection.InspectionList{
+		Items: MapSliceFromDB(inspections),
+	}
+
+	if hasNext {
+		list.NextCursor = page.Next(list.Items[len(list.Items)-1])
+	}
+
+	return list, nil
+}
+
+//go:embed sql/get_stats.sql
+var getStatsSQL string
+
+func (r *Repository) GetStats(ctx context.Context, request inspection.StatsRequest) ([]inspection.StatsGroup, error) {
+	columns, groupBy := statsClauses(request)
+
+	query, args, err := newFilterQuery(request.Filter).bind(r.db, getStatsSQL, map[string]any{}, columns, groupBy)
+	if err != nil {
+		return nil, fmt.Errorf("bind filter: %w", err)
+	}
+
+	var groups []StatsGroup
+	err = r.db.SelectContext(ctx, &groups, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
 	}
 
-	err = r.attachAttachments(ctx, inspections)
+	return MapStatsGroupsFromDB(groups), nil
+}
+
+// GetByTaskIDs returns a page of inspections of the tasks. Attachments and devices are loaded for the whole page at once.
+func (r *Repository) GetByTaskIDs(ctx context.Context, taskIDs []int, page inspection.Page) (inspection.InspectionList, error) {
+	if len(taskIDs) == 0 {
+		return inspection.InspectionList{Items: []inspection.Inspection{}}, nil
+	}
+
+	return r.GetAll(ctx, inspection.Filter{TaskIDs: taskIDs}, page)
+}
+
+//go:embed sql/get_without_brigade.sql
+var getWithoutBrigadeSQL string
+
+// GetWithoutBrigade returns up to limit inspections with no brigade that follow afterID. Only ID and TaskID are loaded.
+func (r *Repository) GetWithoutBrigade(ctx context.Context, afterID, limit int) ([]inspection.Inspection, error) {
+	var inspections []Inspection
+	err := r.db.SelectContext(ctx, &inspections, getWithoutBrigadeSQL, afterID, limit)
 	if err != nil {
-		return nil, fmt.Errorf("attach attachments: %w", err)
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
 	}
 
 	return MapSliceFromDB(inspections), nil
 }
 
+//go:embed sql/set_brigade.sql
+var setBrigadeSQL string
+
+func (r *Repository) SetBrigade(ctx context.Context, inspectionID, brigadeID int) error {
+	_, err := r.db.ExecContext(ctx, setBrigadeSQL, inspectionID, brigadeID)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
 //go:embed sql/get_by_task_id.sql
 var getByTaskIDSQL string
 
@@ -49,45 +224,34 @@ func (r *Repository) GetByTaskID(ctx context.Context, taskID int) (inspection.In
 		return inspection.Inspection{}, fmt.Errorf("r.db.GetContext: %w", err)
 	}
 
-	result := MapFromDB(ins)
-	result.Attachments, err = r.getAttachmentsByInspectionID(ctx, ins.ID)
-	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("get attachments by inspection id: %w", err)
+	return r.hydrateOne(ctx, ins)
+}
+
+// hydrate loads attachments and inspected devices with their seals for all inspections in batches.
+func (r *Repository) hydrate(ctx context.Context, inspections []Inspection) error {
+	if err := r.attachAttachments(ctx, inspections); err != nil {
+		return fmt.Errorf("attach attachments: %w", err)
 	}
 
-	result.InspectedDevices, err = r.getDevicesByInspectionID(ctx, ins.ID)
-	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("get devices by inspection id: %w", err)
+	if err := r.attachInspectedDevices(ctx, inspections); err != nil {
+		return fmt.Errorf("attach inspected devices: %w", err)
 	}
 
-	return result, nil
+	return nil
 }
 
-//go:embed sql/get_devices_by_inspection_id.sql
-var getDevicesByInspectionIDSQL string
-
-func (r *Repository) getDevicesByInspectionID(ctx context.Context, inspectionID int) ([]inspection.InspectedDevice, error) {
-	var devices []InspectedDevice
-	err := r.db.SelectContext(ctx, &devices, getDevicesByInspectionIDSQL, inspectionID)
-	if err != nil {
-		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
+func (r *Repository) hydrateOne(ctx context.Context, ins Inspection) (inspection.Inspection, error) {
+	inspections := []Inspection{ins}
+	if err := r.hydrate(ctx, inspections); err != nil {
+		return inspection.Inspection{}, fmt.Errorf("hydrate: %w", err)
 	}
 
-	return MapInspectedDevicesSliceFromDB(devices), nil
+	return MapFromDB(inspections[0]), nil
 }
 
 //go:embed sql/get_attachments_by_inspection_ids.sql
 var getAttachmentsByInspectionIDsSQL string
 
-func (r *Repository) getAttachmentsByInspectionID(ctx context.Context, inspectionID int) ([]inspection.Attachment, error) {
-	attachments, err := r.getAttachmentsByInspectionIDs(ctx, []int{inspectionID})
-	if err != nil {
-		return nil, err
-	}
-
-	return MapAttachmentsSliceFromDB(attachments), nil
-}
-
 func (r *Repository) attachAttachments(ctx context.Context, inspections []Inspection) error {
 	if len(inspections) == 0 {
 		return nil
@@ -116,28 +280,125 @@ func (r *Repository) attachAttachments(ctx context.Context, inspections []Inspec
 }
 
 func (r *Repository) getAttachmentsByInspectionIDs(ctx context.Context, inspectionIDs []int) ([]Attachment, error) {
-	query, args, err := sqlx.In(getAttachmentsByInspectionIDsSQL, inspectionIDs)
+	var attachments []Attachment
+	if err := r.selectIn(ctx, &attachments, getAttachmentsByInspectionIDsSQL, inspectionIDs); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+//go:embed sql/get_devices_by_inspection_ids.sql
+var getDevicesByInspectionIDsSQL string
+
+//go:embed sql/get_seals_by_inspection_ids.sql
+var getSealsByInspectionIDsSQL string
+
+func (r *Repository) attachInspectedDevices(ctx context.Context, inspections []Inspection) error {
+	if len(inspections) == 0 {
+		return nil
+	}
+
+	inspectionIDs := make([]int, 0, len(inspections))
+	for _, ins := range inspections {
+		inspectionIDs = append(inspectionIDs, ins.ID)
+	}
+
+	var devices []InspectedDevice
+	if err := r.selectIn(ctx, &devices, getDevicesByInspectionIDsSQL, inspectionIDs); err != nil {
+		return fmt.Errorf("get devices: %w", err)
+	}
+
+	var seals []InspectedSeal
+	if err := r.selectIn(ctx, &seals, getSealsByInspectionIDsSQL, inspectionIDs); err != nil {
+		return fmt.Errorf("get seals: %w", err)
+	}
+
+	type deviceKey struct {
+		inspectionID int
+		deviceID     int
+	}
+
+	sealsByDevice := make(map[deviceKey][]InspectedSeal, len(devices))
+	unassignedSeals := make(map[int][]InspectedSeal)
+	for _, seal := range seals {
+		// Migration 0006 could bind seals only of single-device inspections, the rest stay on the inspection.
+		if seal.DeviceID == nil {
+			unassignedSeals[seal.InspectionID] = append(unassignedSeals[seal.InspectionID], seal)
+			continue
+		}
+
+		key := deviceKey{inspectionID: seal.InspectionID, deviceID: *seal.DeviceID}
+		sealsByDevice[key] = append(sealsByDevice[key], seal)
+	}
+
+	devicesByInspectionID := make(map[int][]InspectedDevice, len(inspections))
+	for _, device := range devices {
+		device.InspectedSeals = sealsByDevice[deviceKey{inspectionID: device.InspectionID, deviceID: device.DeviceID}]
+		devicesByInspectionID[device.InspectionID] = append(devicesByInspectionID[device.InspectionID], device)
+	}
+
+	for i := range inspections {
+		inspections[i].InspectedDevices = devicesByInspectionID[inspections[i].ID]
+		inspections[i].UnassignedSeals = unassignedSeals[inspections[i].ID]
+	}
+
+	return nil
+}
+
+func (r *Repository) selectIn(ctx context.Context, dest any, query string, ids []int) error {
+	query, args, err := sqlx.In(query, ids)
+	if err != nil {
+		return fmt.Errorf("sqlx.In: %w", err)
+	}
+
+	err = r.db.SelectContext(ctx, dest, r.db.Rebind(query), args...)
 	if err != nil {
-		return nil, fmt.Errorf("sqlx.In: %w", err)
+		return fmt.Errorf("r.db.SelectContext: %w", err)
 	}
 
-	query = r.db.Rebind(query)
+	return nil
+}
+
+//go:embed sql/add_attachment.sql
+var addAttachmentSQL string
 
+func (r *Repository) AddAttachment(ctx context.Context, attachment inspection.Attachment) (inspection.Attachment, error) {
+	var sha256 *string
+	if len(attachment.SHA256) != 0 {
+		sha256 = &attachment.SHA256
+	}
+
+	var a Attachment
+	err := r.db.GetContext(ctx, &a, addAttachmentSQL, attachment.InspectionID, attachment.Type, attachment.FileID,
+		attachment.ActTemplateID, attachment.Reason, attachment.Signature, sha256)
+	if err != nil {
+		return inspection.Attachment{}, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	return MapAttachmentFromDB(a), nil
+}
+
+//go:embed sql/get_acts_by_sha256.sql
+var getActsBySHA256SQL string
+
+// GetActsBySHA256 returns the attachments whose files have the digest.
+func (r *Repository) GetActsBySHA256(ctx context.Context, sha256 string) ([]inspection.Attachment, error) {
 	var attachments []Attachment
-	err = r.db.SelectContext(ctx, &attachments, query, args...)
+	err := r.db.SelectContext(ctx, &attachments, getActsBySHA256SQL, sha256)
 	if err != nil {
 		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
 	}
 
-	return attachments, nil
+	return MapAttachmentsSliceFromDB(attachments), nil
 }
 
-//go:embed sql/add_attachment.sql
-var addAttachmentSQL string
+//go:embed sql/get_attachment_by_file_id.sql
+var getAttachmentByFileIDSQL string
 
-func (r *Repository) AddAttachment(ctx context.Context, inspectionID, fileID int, attachmentType inspection.AttachmentType) (inspection.Attachment, error) {
+func (r *Repository) GetAttachmentByFileID(ctx context.Context, fileID int) (inspection.Attachment, error) {
 	var a Attachment
-	err := r.db.GetContext(ctx, &a, addAttachmentSQL, inspectionID, attachmentType, fileID)
+	err := r.db.GetContext(ctx, &a, getAttachmentByFileIDSQL, fileID)
 	if err != nil {
 		return inspection.Attachment{}, fmt.Errorf("r.db.GetContext: %w", err)
 	}
@@ -145,6 +406,102 @@ func (r *Repository) AddAttachment(ctx context.Context, inspectionID, fileID int
 	return MapAttachmentFromDB(a), nil
 }
 
+//go:embed sql/supersede_acts.sql
+var supersedeActsSQL string
+
+// SupersedeActs marks the current act files of the inspection as replaced by a new version.
+func (r *Repository) SupersedeActs(ctx context.Context, inspectionID int) error {
+	_, err := r.db.ExecContext(ctx, supersedeActsSQL, inspectionID, inspection.AttachmentTypeAct, inspection.AttachmentTypeActPDF)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/add_act_upload.sql
+var addActUploadSQL string
+
+// AddActUpload records an act file uploaded for the inspection until it is attached.
+func (r *Repository) AddActUpload(ctx context.Context, upload inspection.ActUpload) error {
+	_, err := r.db.ExecContext(ctx, addActUploadSQL, upload.FileID, upload.InspectionID, upload.Format, upload.SHA256,
+		upload.DrawnAt, upload.Reason)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/get_act_uploads.sql
+var getActUploadsSQL string
+
+// GetActUploads returns the act files uploaded for the inspection that are neither attached nor abandoned, oldest first.
+func (r *Repository) GetActUploads(ctx context.Context, inspectionID int) ([]inspection.ActUpload, error) {
+	var uploads []ActUpload
+	err := r.db.SelectContext(ctx, &uploads, getActUploadsSQL, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
+	}
+
+	return MapActUploadsSliceFromDB(uploads), nil
+}
+
+//go:embed sql/delete_attached_act_uploads.sql
+var deleteAttachedActUploadsSQL string
+
+//go:embed sql/abandon_act_uploads.sql
+var abandonActUploadsSQL string
+
+// SettleActUploads forgets the act files of the inspection that are attached to it and abandons the others.
+// It is called when the acts are attached, so files of failed attempts with another content are kept for cleanup.
+func (r *Repository) SettleActUploads(ctx context.Context, inspectionID int) error {
+	_, err := r.db.ExecContext(ctx, deleteAttachedActUploadsSQL, inspectionID)
+	if err != nil {
+		return fmt.Errorf("delete attached act uploads: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, abandonActUploadsSQL, inspectionID)
+	if err != nil {
+		return fmt.Errorf("abandon act uploads: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/assign_act_number.sql
+var assignActNumberSQL string
+
+//go:embed sql/get_act_number.sql
+var getActNumberSQL string
+
+// AssignActNumber gives the inspection the next number of the series unless it already has a number from it,
+// and returns the number of the inspection. It must be called on a repository returned by WithTx: the counter row
+// stays locked until the transaction ends, and the number returns to the series when it is rolled back.
+func (r *Repository) AssignActNumber(ctx context.Context, inspectionID int, series inspection.ActNumber) (inspection.ActNumber, error) {
+	if r.conn != nil {
+		return inspection.ActNumber{}, errors.New("act numbers are assigned only in a transaction")
+	}
+
+	_, err := r.db.ExecContext(ctx, assignActNumberSQL, inspectionID, series.Kind, series.Year, series.Branch)
+	if err != nil {
+		return inspection.ActNumber{}, fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	var i Inspection
+	err = r.db.GetContext(ctx, &i, getActNumberSQL, inspectionID)
+	if err != nil {
+		return inspection.ActNumber{}, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	number := MapFromDB(i).ActNumber
+	if number == nil {
+		return inspection.ActNumber{}, fmt.Errorf("inspection %d has no act number", inspectionID)
+	}
+
+	return *number, nil
+}
+
 //go:embed sql/get_by_id.sql
 var getByIDSQL string
 
@@ -155,13 +512,7 @@ func (r *Repository) GetByID(ctx context.Context, id int) (inspection.Inspection
 		return inspection.Inspection{}, fmt.Errorf("r.db.GetContext: %w", err)
 	}
 
-	result := MapFromDB(ins)
-	result.Attachments, err = r.getAttachmentsByInspectionID(ctx, ins.ID)
-	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("get attachments by inspection id: %w", err)
-	}
-
-	return result, nil
+	return r.hydrateOne(ctx, ins)
 }
 
 //go:embed sql/get_previous_device_inspections.sql
@@ -186,11 +537,19 @@ var addSealSQL string
 func (r *Repository) AddInspectedDevices(ctx context.Context, inspectionID int, requests []inspection.InspectedDeviceRequest) error {
 	devices, seals := MapInspectedDeviceRequestsSliceToDB(requests, inspectionID)
 
+	if len(devices) == 0 {
+		return nil
+	}
+
 	_, err := r.db.NamedExecContext(ctx, addDeviceSQL, devices)
 	if err != nil {
 		return fmt.Errorf("add devices: %w", err)
 	}
 
+	if len(seals) == 0 {
+		return nil
+	}
+
 	_, err = r.db.NamedExecContext(ctx, addSealSQL, seals)
 	if err != nil {
 		return fmt.Errorf("add seals: %w", err)
@@ -199,12 +558,33 @@ func (r *Repository) AddInspectedDevices(ctx context.Context, inspectionID int,
 	return nil
 }
 
+//go:embed sql/delete_inspected_devices.sql
+var deleteInspectedDevicesSQL string
+
+//go:embed sql/delete_inspected_seals.sql
+var deleteInspectedSealsSQL string
+
+// DeleteInspectedDevices removes readings and seals of a previous submission of the inspection.
+func (r *Repository) DeleteInspectedDevices(ctx context.Context, inspectionID int) error {
+	_, err := r.db.ExecContext(ctx, deleteInspectedSealsSQL, inspectionID)
+	if err != nil {
+		return fmt.Errorf("delete seals: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, deleteInspectedDevicesSQL, inspectionID)
+	if err != nil {
+		return fmt.Errorf("delete devices: %w", err)
+	}
+
+	return nil
+}
+
 //go:embed sql/start_inspection.sql
 var startInspectionSQL string
 
-func (r *Repository) StartInspection(ctx context.Context, taskID int) (inspection.Inspection, error) {
+func (r *Repository) StartInspection(ctx context.Context, taskID int, brigadeID *int) (inspection.Inspection, error) {
 	var ins Inspection
-	err := r.db.GetContext(ctx, &ins, startInspectionSQL, taskID)
+	err := r.db.GetContext(ctx, &ins, startInspectionSQL, taskID, brigadeID)
 	if err != nil {
 		return inspection.Inspection{}, fmt.Errorf("r.db.GetContext: %w", err)
 	}
@@ -221,33 +601,309 @@ var finishInspectionSQL string
 func (r *Repository) FinishInspection(ctx context.Context, request inspection.FinishInspectionRequest) (inspection.Inspection, error) {
 	dbRequest := MapFinishInspectionRequestToDB(request)
 
-	rows, err := r.db.NamedQueryContext(ctx, finishInspectionSQL, dbRequest)
+	rows, err := sqlx.NamedQueryContext(ctx, r.db, finishInspectionSQL, dbRequest)
+	if err != nil {
+		return inspection.Inspection{}, fmt.Errorf("sqlx.NamedQueryContext: %w", err)
+	}
+
+	// Rows are closed before hydration, because a transaction cannot run another query while they are open.
+	ins, err := scanInspection(rows)
 	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("r.db.NamedQueryContext: %w", err)
+		return inspection.Inspection{}, err
 	}
+
+	return r.hydrateOne(ctx, ins)
+}
+
+func scanInspection(rows *sqlx.Rows) (ins Inspection, err error) {
 	defer func() {
 		err = errors.Join(err, rows.Close())
 	}()
 
 	if !rows.Next() {
-		return inspection.Inspection{}, errors.New("rows.Next == false")
+		return Inspection{}, errors.New("rows.Next == false")
 	}
 
-	var ins Inspection
 	err = rows.StructScan(&ins)
 	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("rows.Scan: %w", err)
+		return Inspection{}, fmt.Errorf("rows.Scan: %w", err)
 	}
 
 	if err = rows.Err(); err != nil {
-		return inspection.Inspection{}, fmt.Errorf("rows.Err: %w", err)
+		return Inspection{}, fmt.Errorf("rows.Err: %w", err)
 	}
 
-	result := MapFromDB(ins)
-	result.Attachments, err = r.getAttachmentsByInspectionID(ctx, ins.ID)
+	return ins, nil
+}
+
+//go:embed sql/add_event.sql
+var addEventSQL string
+
+func (r *Repository) AddEvent(ctx context.Context, event inspection.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, addEventSQL, event.Type, payload, event.Inspection.ID)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/get_pending_events.sql
+var getPendingEventsSQL string
+
+// GetPendingEvents locks unsent events that are due for delivery, so it must be called inside WithTx.
+func (r *Repository) GetPendingEvents(ctx context.Context, limit int) ([]inspection.OutboxEvent, error) {
+	var events []Event
+	err := r.db.SelectContext(ctx, &events, getPendingEventsSQL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
+	}
+
+	return MapEventsSliceFromDB(events), nil
+}
+
+//go:embed sql/mark_event_sent.sql
+var markEventSentSQL string
+
+func (r *Repository) MarkEventSent(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, markEventSentSQL, id)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/mark_event_failed.sql
+var markEventFailedSQL string
+
+func (r *Repository) MarkEventFailed(ctx context.Context, id int, reason string) error {
+	_, err := r.db.ExecContext(ctx, markEventFailedSQL, id, reason)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/get_status_for_update.sql
+var getStatusForUpdateSQL string
+
+// GetStatusForUpdate locks the inspection row until the end of the transaction, so it must be called inside WithTx.
+func (r *Repository) GetStatusForUpdate(ctx context.Context, id int) (inspection.Status, error) {
+	var status int
+	err := r.db.GetContext(ctx, &status, getStatusForUpdateSQL, id)
+	if err != nil {
+		return inspection.StatusUnknown, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	return inspection.Status(status), nil
+}
+
+//go:embed sql/get_idempotency_key.sql
+var getIdempotencyKeySQL string
+
+func (r *Repository) GetIdempotencyKey(ctx context.Context, key string) (inspection.IdempotencyKey, error) {
+	var k IdempotencyKey
+	err := r.db.GetContext(ctx, &k, getIdempotencyKeySQL, key)
+	if err != nil {
+		return inspection.IdempotencyKey{}, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	return MapIdempotencyKeyFromDB(k), nil
+}
+
+//go:embed sql/reserve_idempotency_key.sql
+var reserveIdempotencyKeySQL string
+
+// ReserveIdempotencyKey claims the key for a finish of the inspection and reports whether it was claimed.
+// A reservation left without a response for 10 minutes by a crashed request can be claimed again.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, key string, inspectionID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, reserveIdempotencyKeySQL, key, inspectionID)
+	if err != nil {
+		return false, fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("result.RowsAffected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+//go:embed sql/complete_idempotency_key.sql
+var completeIdempotencyKeySQL string
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, key inspection.IdempotencyKey) error {
+	_, err := r.db.ExecContext(ctx, completeIdempotencyKeySQL, key.Key, key.FileID, []byte(key.Response))
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/release_idempotency_key.sql
+var releaseIdempotencyKeySQL string
+
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, releaseIdempotencyKeySQL, key)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/update_status.sql
+var updateStatusSQL string
+
+func (r *Repository) UpdateStatus(ctx context.Context, id int, status inspection.Status) error {
+	_, err := r.db.ExecContext(ctx, updateStatusSQL, id, status)
+	if err != nil {
+		return fmt.Errorf("r.db.ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/add_status_change.sql
+var addStatusChangeSQL string
+
+func (r *Repository) AddStatusChange(ctx context.Context, change inspection.StatusChange) error {
+	_, err := r.db.NamedExecContext(ctx, addStatusChangeSQL, MapStatusChangeToDB(change))
 	if err != nil {
-		return inspection.Inspection{}, fmt.Errorf("get attachments by inspection id: %w", err)
+		return fmt.Errorf("r.db.NamedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/get_status_history.sql
+var getStatusHistorySQL string
+
+func (r *Repository) GetStatusHistory(ctx context.Context, inspectionID int) ([]inspection.StatusChange, error) {
+	var changes []StatusChange
+	err := r.db.SelectContext(ctx, &changes, getStatusHistorySQL, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
+	}
+
+	return MapStatusChangesSliceFromDB(changes), nil
+}
+
+//go:embed sql/get_latest_act_template.sql
+var getLatestActTemplateSQL string
+
+func (r *Repository) GetLatestActTemplate(ctx context.Context, inspectionType inspection.Type) (inspection.ActTemplate, error) {
+	var t ActTemplate
+	err := r.db.GetContext(ctx, &t, getLatestActTemplateSQL, inspectionType)
+	if err != nil {
+		return inspection.ActTemplate{}, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	return MapActTemplateFromDB(t), nil
+}
+
+//go:embed sql/get_act_template_content.sql
+var getActTemplateContentSQL string
+
+func (r *Repository) GetActTemplateContent(ctx context.Context, id int) ([]byte, error) {
+	var content []byte
+	err := r.db.GetContext(ctx, &content, getActTemplateContentSQL, id)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.GetContext: %w", err)
+	}
+
+	return content, nil
+}
+
+//go:embed sql/get_act_templates.sql
+var getActTemplatesSQL string
+
+func (r *Repository) GetActTemplates(ctx context.Context) ([]inspection.ActTemplate, error) {
+	var templates []ActTemplate
+	err := r.db.SelectContext(ctx, &templates, getActTemplatesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
+	}
+
+	return MapActTemplatesSliceFromDB(templates), nil
+}
+
+//go:embed sql/lock_act_template_type.sql
+var lockActTemplateTypeSQL string
+
+//go:embed sql/add_act_template.sql
+var addActTemplateSQL string
+
+// AddActTemplate stores the template as the next version of its type. Versions of the type are locked until
+// the template is stored, so concurrent uploads get consecutive versions instead of the same one.
+func (r *Repository) AddActTemplate(ctx context.Context, template inspection.ActTemplate) (inspection.ActTemplate, error) {
+	var createdBy *int
+	if template.CreatedBy != 0 {
+		createdBy = &template.CreatedBy
+	}
+
+	var t ActTemplate
+	err := r.inTx(ctx, nil, func(tx *Repository) error {
+		if _, err := tx.db.ExecContext(ctx, lockActTemplateTypeSQL, template.Type); err != nil {
+			return fmt.Errorf("lock act template type: %w", err)
+		}
+
+		if err := tx.db.GetContext(ctx, &t, addActTemplateSQL, template.Type, template.FileName, template.Content, createdBy); err != nil {
+			return fmt.Errorf("r.db.GetContext: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return inspection.ActTemplate{}, err
+	}
+
+	return MapActTemplateFromDB(t), nil
+}
+
+//go:embed sql/delete_signatures.sql
+var deleteSignaturesSQL string
+
+//go:embed sql/add_signature.sql
+var addSignatureSQL string
+
+// ReplaceSignatures stores the handwritten signatures of the inspection instead of those of a previous submission.
+func (r *Repository) ReplaceSignatures(ctx context.Context, inspectionID int, signatures []inspection.Signature) error {
+	_, err := r.db.ExecContext(ctx, deleteSignaturesSQL, inspectionID)
+	if err != nil {
+		return fmt.Errorf("delete signatures: %w", err)
+	}
+
+	if len(signatures) == 0 {
+		return nil
+	}
+
+	_, err = r.db.NamedExecContext(ctx, addSignatureSQL, MapSignaturesSliceToDB(signatures, inspectionID))
+	if err != nil {
+		return fmt.Errorf("add signatures: %w", err)
+	}
+
+	return nil
+}
+
+//go:embed sql/get_signatures.sql
+var getSignaturesSQL string
+
+func (r *Repository) GetSignatures(ctx context.Context, inspectionID int) ([]inspection.Signature, error) {
+	var signatures []Signature
+	err := r.db.SelectContext(ctx, &signatures, getSignaturesSQL, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("r.db.SelectContext: %w", err)
 	}
 
-	return result, err
+	return MapSignaturesSliceFromDB(signatures), nil
 }
@@ -0,0 +1,4 @@
+update act_uploads
+set abandoned_at = now()
+where inspection_id = $1
+  and abandoned_at is null;
@@ -0,0 +1,5 @@
+insert into act_templates (type, version, file_name, content, created_by)
+select $1, coalesce(max(version), 0) + 1, $2, $3, $4
+from act_templates
+where type = $1
+returning id, type, version, file_name, created_by, created_at;
@@ -0,0 +1,2 @@
+insert into act_uploads (file_id, inspection_id, format, sha256, drawn_at, reason)
+values ($1, $2, $3, $4, $5, $6);
@@ -1,3 +1,3 @@
-insert into attachments (inspection_id, type, file_id)
-values ($1, $2, $3)
-returning id, inspection_id, type, file_id, created_at;
+insert into attachments (inspection_id, type, file_id, act_template_id, reason, signature, sha256)
+values ($1, $2, $3, $4, $5, $6, $7)
+returning id, inspection_id, type, file_id, act_template_id, superseded_at, reason, signature, sha256, created_at;
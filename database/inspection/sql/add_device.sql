@@ -1,2 +1,2 @@
-insert into inspected_devices (device_id, inspection_id, value, consumption)
-values (:device_id, :inspection_id, :value, :consumption);
+insert into inspected_devices (device_id, inspection_id, value, consumption, declared_consumption, is_replaced, is_rollover)
+values (:device_id, :inspection_id, :value, :consumption, :declared_consumption, :is_replaced, :is_rollover);
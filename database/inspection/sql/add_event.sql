@@ -0,0 +1,2 @@
+insert into inspection_events (type, payload, inspection_id)
+values ($1, $2, $3);
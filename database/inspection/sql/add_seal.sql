@@ -1,2 +1,2 @@
-insert into inspected_seals (seal_id, inspection_id, is_broken)
-values (:seal_id, :inspection_id, :is_broken);
+insert into inspected_seals (seal_id, device_id, inspection_id, is_broken)
+values (:seal_id, :device_id, :inspection_id, :is_broken);
@@ -0,0 +1,2 @@
+insert into inspection_signatures (inspection_id, signer, image)
+values (:inspection_id, :signer, :image);
@@ -0,0 +1,2 @@
+insert into inspection_status_history (inspection_id, from_status, to_status, user_id, comment)
+values (:inspection_id, :from_status, :to_status, :user_id, :comment);
@@ -0,0 +1,20 @@
+with inspection as (
+    select id
+    from inspections
+    where id = $1
+      and (act_number is null or (act_kind, act_year, act_branch) is distinct from ($2, $3, $4))
+    for update
+), counter as (
+    insert into act_number_counters (act_kind, year, branch, last_number)
+    select $2, $3, $4, 1
+    from inspection
+    on conflict (act_kind, year, branch) do update set last_number = act_number_counters.last_number + 1
+    returning last_number
+)
+update inspections
+set act_number = counter.last_number,
+    act_kind   = $2,
+    act_year   = $3,
+    act_branch = $4
+from counter
+where inspections.id = $1;
@@ -0,0 +1,4 @@
+update idempotency_keys
+set file_id  = $2,
+    response = $3
+where key = $1;
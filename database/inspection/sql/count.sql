@@ -0,0 +1,3 @@
+select count(*)
+from inspections
+where %s;
@@ -0,0 +1,6 @@
+delete
+from act_uploads u
+using attachments a
+where u.inspection_id = $1
+  and a.inspection_id = u.inspection_id
+  and a.file_id = u.file_id;
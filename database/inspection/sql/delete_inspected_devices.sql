@@ -0,0 +1,3 @@
+delete
+from inspected_devices
+where inspection_id = $1;
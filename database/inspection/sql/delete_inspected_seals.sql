@@ -0,0 +1,3 @@
+delete
+from inspected_seals
+where inspection_id = $1;
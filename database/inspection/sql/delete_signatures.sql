@@ -0,0 +1,3 @@
+delete
+from inspection_signatures
+where inspection_id = $1;
@@ -1,6 +1,5 @@
 update inspections
-set status                    = 2,
-    type                      = :type,
+set type                      = :type,
     resolution                = :resolution,
     limit_reason              = :limit_reason,
     method                    = :method,
@@ -36,5 +35,9 @@ returning id,
     unauthorized_explanation,
     inspect_at,
     energy_action_at,
+    act_number,
+    act_kind,
+    act_year,
+    act_branch,
     created_at,
     updated_at;
@@ -0,0 +1,3 @@
+select act_number, act_kind, act_year, act_branch
+from inspections
+where id = $1;
@@ -0,0 +1,3 @@
+select content
+from act_templates
+where id = $1;
@@ -0,0 +1,3 @@
+select id, type, version, file_name, created_by, created_at
+from act_templates
+order by type, version desc;
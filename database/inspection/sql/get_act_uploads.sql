@@ -0,0 +1,5 @@
+select file_id, inspection_id, format, sha256, drawn_at, reason, created_at
+from act_uploads
+where inspection_id = $1
+  and abandoned_at is null
+order by created_at, file_id;
@@ -0,0 +1,4 @@
+select id, inspection_id, type, file_id, act_template_id, superseded_at, reason, signature, sha256, created_at
+from attachments
+where sha256 = $1
+order by id;
@@ -17,11 +17,13 @@ select id,
        unauthorized_explanation,
        inspect_at,
        energy_action_at,
+       act_number,
+       act_kind,
+       act_year,
+       act_branch,
        created_at,
        updated_at
 from inspections
-order by
-    case when $1 = 'asc' then inspect_at end asc nulls last,
-    case when $1 = 'desc' then inspect_at end desc nulls last,
-    id
-limit $2 offset $3;
+where %s
+order by %s
+limit :limit offset :offset;
@@ -0,0 +1,3 @@
+select id, inspection_id, type, file_id, act_template_id, superseded_at, reason, signature, sha256, created_at
+from attachments
+where file_id = $1;
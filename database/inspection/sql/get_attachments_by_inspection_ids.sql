@@ -1,4 +1,4 @@
-select id, inspection_id, type, file_id, created_at
+select id, inspection_id, type, file_id, act_template_id, superseded_at, reason, signature, sha256, created_at
 from attachments
 where inspection_id in (?)
 order by id;
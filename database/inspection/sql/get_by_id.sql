@@ -17,6 +17,10 @@ select id,
        unauthorized_explanation,
        inspect_at,
        energy_action_at,
+       act_number,
+       act_kind,
+       act_year,
+       act_branch,
        created_at,
        updated_at
 from inspections
@@ -0,0 +1,4 @@
+select id, device_id, inspection_id, value, consumption, declared_consumption, is_replaced, is_rollover, created_at
+from inspected_devices
+where inspection_id in (?)
+order by id;
@@ -0,0 +1,3 @@
+select key, inspection_id, file_id, response, created_at
+from idempotency_keys
+where key = $1;
@@ -0,0 +1,5 @@
+select id, type, version, file_name, created_by, created_at
+from act_templates
+where type = $1
+order by version desc
+limit 1;
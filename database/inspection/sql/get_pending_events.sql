@@ -0,0 +1,11 @@
+select e.id, e.type, e.inspection_id, e.payload, e.attempts, e.created_at
+from inspection_events e
+where e.sent_at is null
+  and e.next_attempt_at <= now()
+  and not exists (select 1
+                  from inspection_events earlier
+                  where earlier.inspection_id = e.inspection_id
+                    and earlier.sent_at is null
+                    and earlier.id < e.id)
+order by e.id
+limit $1 for update skip locked;
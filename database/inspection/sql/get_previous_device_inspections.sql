@@ -1,4 +1,4 @@
-select id, device_id, inspection_id, value, consumption, created_at
+select id, device_id, inspection_id, value, consumption, declared_consumption, is_replaced, is_rollover, created_at
 from inspected_devices
 where device_id = $1
   and inspection_id != $2
@@ -0,0 +1,4 @@
+select id, seal_id, device_id, inspection_id, is_broken, created_at
+from inspected_seals
+where inspection_id in (?)
+order by id;
@@ -0,0 +1,4 @@
+select id, inspection_id, signer, image, created_at
+from inspection_signatures
+where inspection_id = $1
+order by id;
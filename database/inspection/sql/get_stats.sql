@@ -0,0 +1,10 @@
+with filtered as (select *
+                  from inspections
+                  where %s)
+select %s
+       count(*)                                                      as count,
+       count(*) filter (where is_violation_detected)                 as violation_count,
+       count(inspect_at)                                             as finished_count,
+       coalesce(avg(extract(epoch from inspect_at - created_at)), 0) as average_duration_seconds
+from filtered
+%s;
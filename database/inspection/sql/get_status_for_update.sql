@@ -0,0 +1,4 @@
+select status
+from inspections
+where id = $1
+    for update;
@@ -0,0 +1,4 @@
+select id, inspection_id, from_status, to_status, user_id, comment, created_at
+from inspection_status_history
+where inspection_id = $1
+order by id;
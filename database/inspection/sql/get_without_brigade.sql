@@ -0,0 +1,6 @@
+select id, task_id
+from inspections
+where brigade_id is null
+  and id > $1
+order by id
+limit $2;
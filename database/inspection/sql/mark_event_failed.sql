@@ -0,0 +1,5 @@
+update inspection_events
+set attempts        = attempts + 1,
+    last_error      = $2,
+    next_attempt_at = now() + least(power(2, attempts), 300) * interval '1 second'
+where id = $1;
@@ -0,0 +1,5 @@
+update inspection_events
+set sent_at    = now(),
+    attempts   = attempts + 1,
+    last_error = null
+where id = $1;
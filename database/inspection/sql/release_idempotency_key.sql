@@ -0,0 +1,4 @@
+delete
+from idempotency_keys
+where key = $1
+  and response is null;
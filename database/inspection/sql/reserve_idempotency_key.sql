@@ -0,0 +1,6 @@
+insert into idempotency_keys (key, inspection_id)
+values ($1, $2)
+on conflict (key) do update set created_at = now()
+where idempotency_keys.response is null
+  and idempotency_keys.inspection_id = excluded.inspection_id
+  and idempotency_keys.created_at < now() - interval '10 minutes';
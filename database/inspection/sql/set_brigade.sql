@@ -0,0 +1,4 @@
+update inspections
+set brigade_id = $2
+where id = $1
+  and brigade_id is null;
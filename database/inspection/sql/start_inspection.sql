@@ -1,5 +1,5 @@
-insert into inspections (task_id, status)
-values ($1, 1)
+insert into inspections (task_id, brigade_id, status)
+values ($1, $2, 1)
 returning id,
     task_id,
     status,
@@ -19,5 +19,9 @@ returning id,
     unauthorized_explanation,
     inspect_at,
     energy_action_at,
+    act_number,
+    act_kind,
+    act_year,
+    act_branch,
     created_at,
     updated_at;
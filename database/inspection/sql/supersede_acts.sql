@@ -0,0 +1,5 @@
+update attachments
+set superseded_at = now()
+where inspection_id = $1
+  and type in ($2, $3)
+  and superseded_at is null;
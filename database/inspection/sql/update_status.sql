@@ -0,0 +1,3 @@
+update inspections
+set status = $2
+where id = $1;
@@ -0,0 +1,44 @@
+package inspection
+
+import (
+	"fmt"
+	"inspection-service/service/inspection"
+	"strings"
+)
+
+var statsGroupColumns = map[inspection.StatsGroupKey]string{
+	inspection.StatsGroupByType:       "type",
+	inspection.StatsGroupByResolution: "resolution",
+	inspection.StatsGroupByStatus:     "status",
+	inspection.StatsGroupByBrigade:    "brigade_id",
+}
+
+var statsPeriods = map[inspection.StatsPeriod]string{
+	inspection.StatsPeriodDay:   "day",
+	inspection.StatsPeriodWeek:  "week",
+	inspection.StatsPeriodMonth: "month",
+}
+
+// statsClauses returns the group columns of the select list and the group by clause of a validated request.
+// Both are built from fixed strings only.
+func statsClauses(request inspection.StatsRequest) (string, string) {
+	var columns, positions []string
+	for _, key := range request.GroupBy {
+		columns = append(columns, statsGroupColumns[key]+",")
+	}
+
+	if request.Period != "" {
+		columns = append(columns, fmt.Sprintf("date_trunc('%s', created_at, 'Europe/Moscow') as period,", statsPeriods[request.Period]))
+	}
+
+	if len(columns) == 0 {
+		return "", ""
+	}
+
+	for i := range columns {
+		positions = append(positions, fmt.Sprint(i+1))
+	}
+	ordinals := strings.Join(positions, ", ")
+
+	return strings.Join(columns, " "), fmt.Sprintf("group by %s order by %s", ordinals, ordinals)
+}
@@ -0,0 +1,28 @@
+package inspection
+
+import (
+	"inspection-service/service/inspection"
+	"testing"
+)
+
+func TestStatsClausesGroupByKeysAndPeriod(t *testing.T) {
+	columns, groupBy := statsClauses(inspection.StatsRequest{
+		GroupBy: []inspection.StatsGroupKey{inspection.StatsGroupByBrigade, inspection.StatsGroupByType},
+		Period:  inspection.StatsPeriodWeek,
+	})
+
+	wantColumns := "brigade_id, type, date_trunc('week', created_at, 'Europe/Moscow') as period,"
+	if columns != wantColumns {
+		t.Fatalf("columns = %q, want %q", columns, wantColumns)
+	}
+	if groupBy != "group by 1, 2, 3 order by 1, 2, 3" {
+		t.Fatalf("groupBy = %q", groupBy)
+	}
+}
+
+func TestStatsClausesWithoutGroupsGiveSingleRow(t *testing.T) {
+	columns, groupBy := statsClauses(inspection.StatsRequest{})
+	if columns != "" || groupBy != "" {
+		t.Fatalf("columns = %q, groupBy = %q, want both empty", columns, groupBy)
+	}
+}
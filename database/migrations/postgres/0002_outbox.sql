@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists inspection_events
+(
+    id              int primary key generated always as identity,
+    type            int         not null,
+    payload         jsonb       not null,
+    attempts        int         not null default 0,
+    last_error      text,
+    next_attempt_at timestamptz not null default now(), -- Время следующей попытки отправки в Kafka
+    sent_at         timestamptz,                        -- Если NULL, то событие еще не отправлено
+    created_at      timestamptz not null default now()
+);
+
+create index if not exists idx_inspection_events_pending on inspection_events (next_attempt_at, id) where sent_at is null;
+
+-- +goose Down
+drop table if exists inspection_events;
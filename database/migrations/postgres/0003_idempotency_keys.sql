@@ -0,0 +1,14 @@
+-- +goose Up
+create table if not exists idempotency_keys
+(
+    key           text primary key,
+    inspection_id int         not null references inspections (id) on delete cascade,
+    file_id       int         not null,
+    response      jsonb       not null, -- Ответ, который повторно отдается на запросы с тем же ключом
+    created_at    timestamptz not null default now()
+);
+
+create index if not exists idx_idempotency_keys_inspection on idempotency_keys (inspection_id);
+
+-- +goose Down
+drop table if exists idempotency_keys;
@@ -0,0 +1,28 @@
+-- +goose Up
+insert into inspection_statuses (name)
+values ('Submitted'),
+       ('Approved'),
+       ('Rejected'),
+       ('Cancelled');
+
+create table if not exists inspection_status_history
+(
+    id            int primary key generated always as identity,
+    inspection_id int         not null references inspections (id) on delete cascade,
+    from_status   int references inspection_statuses (id) on delete restrict, -- Если NULL, то инспекция только создана
+    to_status     int         not null references inspection_statuses (id) on delete restrict,
+    user_id       int,                                                         -- Если NULL, то статус изменен системой
+    comment       text,
+    created_at    timestamptz not null default now()
+);
+
+create index if not exists idx_status_history_inspection on inspection_status_history (inspection_id);
+
+-- +goose Down
+drop table if exists inspection_status_history;
+update inspections
+set status = 2
+where status > 2;
+delete
+from inspection_statuses
+where id > 2;
@@ -0,0 +1,11 @@
+-- +goose Up
+alter table inspected_devices
+    add column if not exists declared_consumption numeric(15, 2),                -- Расход, указанный инспектором
+    add column if not exists is_replaced          boolean not null default false, -- Прибор учета заменен после предыдущего показания
+    add column if not exists is_rollover          boolean not null default false; -- Счетчик прибора учета перешел через ноль
+
+-- +goose Down
+alter table inspected_devices
+    drop column if exists declared_consumption,
+    drop column if exists is_replaced,
+    drop column if exists is_rollover;
@@ -0,0 +1,15 @@
+-- +goose Up
+alter table inspected_seals
+    add column if not exists device_id int; -- Прибор учета, на котором установлена пломба
+
+-- Пломбы старых инспекций с единственным прибором учета относятся к нему, для остальных прибор неизвестен
+update inspected_seals s
+set device_id = d.device_id
+from inspected_devices d
+where d.inspection_id = s.inspection_id
+  and s.device_id is null
+  and (select count(*) from inspected_devices c where c.inspection_id = s.inspection_id) = 1;
+
+-- +goose Down
+alter table inspected_seals
+    drop column if exists device_id;
@@ -0,0 +1,17 @@
+-- +goose Up
+create index if not exists idx_inspections_type on inspections (type);
+create index if not exists idx_inspections_resolution on inspections (resolution);
+create index if not exists idx_inspections_energy_action_at on inspections (energy_action_at);
+create index if not exists idx_inspections_created_at on inspections (created_at);
+create index if not exists idx_inspections_violation_detected on inspections (inspect_at) where is_violation_detected;
+create index if not exists idx_inspections_unauthorized_consumers on inspections (inspect_at) where is_unauthorized_consumers;
+create index if not exists idx_devices_device on inspected_devices (device_id, inspection_id);
+
+-- +goose Down
+drop index if exists idx_devices_device;
+drop index if exists idx_inspections_unauthorized_consumers;
+drop index if exists idx_inspections_violation_detected;
+drop index if exists idx_inspections_created_at;
+drop index if exists idx_inspections_energy_action_at;
+drop index if exists idx_inspections_resolution;
+drop index if exists idx_inspections_type;
@@ -0,0 +1,10 @@
+-- +goose Up
+alter table inspections
+    add column if not exists brigade_id int; -- Бригада, назначенная на задачу при начале проверки. Если NULL, то бригада неизвестна
+
+create index if not exists idx_inspections_brigade on inspections (brigade_id);
+
+-- +goose Down
+drop index if exists idx_inspections_brigade;
+alter table inspections
+    drop column if exists brigade_id;
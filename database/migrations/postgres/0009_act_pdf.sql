@@ -0,0 +1,11 @@
+-- +goose Up
+insert into attachment_types (name)
+values ('ActPDF'); -- Акт в формате PDF, хранится вместе с DOCX
+
+-- +goose Down
+delete
+from attachments
+where type = (select id from attachment_types where name = 'ActPDF');
+delete
+from attachment_types
+where name = 'ActPDF';
@@ -0,0 +1,20 @@
+-- +goose Up
+create table if not exists act_templates
+(
+    id         int primary key generated always as identity,
+    type       int         not null references inspection_types (id) on delete restrict,
+    version    int         not null, -- Версия шаблона в пределах типа проверки, используется последняя
+    file_name  text        not null,
+    content    bytea       not null, -- Шаблон акта в формате DOCX
+    created_by int,                  -- Пользователь, загрузивший шаблон. Если NULL, то шаблон взят из конфигурации при запуске
+    created_at timestamptz not null default now(),
+    unique (type, version)
+);
+
+alter table attachments
+    add column if not exists act_template_id int references act_templates (id) on delete restrict; -- Шаблон, по которому сформирован акт в формате DOCX
+
+-- +goose Down
+alter table attachments
+    drop column if exists act_template_id;
+drop table if exists act_templates;
@@ -0,0 +1,9 @@
+-- +goose Up
+alter table attachments
+    add column if not exists superseded_at timestamptz, -- Время, когда акт заменен новой версией. Если NULL, то акт действующий
+    add column if not exists reason        text;        -- Причина повторного формирования акта
+
+-- +goose Down
+alter table attachments
+    drop column if exists reason,
+    drop column if exists superseded_at;
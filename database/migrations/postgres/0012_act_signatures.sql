@@ -0,0 +1,18 @@
+-- +goose Up
+create table if not exists inspection_signatures
+(
+    id            int primary key generated always as identity,
+    inspection_id int         not null references inspections (id) on delete cascade,
+    signer        text        not null, -- consumer, inspector1 или inspector2
+    image         bytea       not null, -- Рукописная подпись в формате PNG
+    created_at    timestamptz not null default now(),
+    unique (inspection_id, signer)
+);
+
+alter table attachments
+    add column if not exists signature bytea; -- Подпись Ed25519 файла акта ключом сервиса. Если NULL, то ключ не был настроен
+
+-- +goose Down
+alter table attachments
+    drop column if exists signature;
+drop table if exists inspection_signatures;
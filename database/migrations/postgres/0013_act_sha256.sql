@@ -0,0 +1,10 @@
+-- +goose Up
+alter table attachments
+    add column if not exists sha256 text; -- SHA-256 файла акта в шестнадцатеричном виде, по нему проверяется подлинность предъявленного акта
+
+create index if not exists attachments_sha256_idx on attachments (sha256) where sha256 is not null;
+
+-- +goose Down
+drop index if exists attachments_sha256_idx;
+alter table attachments
+    drop column if exists sha256;
@@ -0,0 +1,27 @@
+-- +goose Up
+create table if not exists act_number_counters
+(
+    act_kind    int  not null, -- Вид акта: 1 - о введении ограничения и возобновления, 2 - контроля
+    year        int  not null, -- Год составления акта по московскому времени
+    branch      text not null, -- Код филиала. Пустой, если акты нумеруются по всему сервису
+    last_number int  not null, -- Последний выданный номер
+    primary key (act_kind, year, branch)
+);
+
+alter table inspections
+    add column if not exists act_number int,  -- Порядковый номер акта в серии. Если NULL, то номером акта был id проверки
+    add column if not exists act_kind   int,  -- Вид акта серии номера
+    add column if not exists act_year   int,  -- Год серии номера
+    add column if not exists act_branch text; -- Код филиала серии номера
+
+create unique index if not exists inspections_act_number_idx
+    on inspections (act_kind, act_year, act_branch, act_number) where act_number is not null;
+
+-- +goose Down
+drop index if exists inspections_act_number_idx;
+alter table inspections
+    drop column if exists act_number,
+    drop column if exists act_kind,
+    drop column if exists act_year,
+    drop column if exists act_branch;
+drop table if exists act_number_counters;
@@ -0,0 +1,16 @@
+-- +goose Up
+alter table inspection_events
+    add column if not exists inspection_id int; -- Проверка события. События одной проверки отправляются по порядку
+
+-- Проверка берется из payload. События без ID проверки в payload остаются без нее и отправляются вне очереди проверок
+update inspection_events
+set inspection_id = (payload -> 'Inspection' ->> 'ID')::int
+where inspection_id is null
+  and payload -> 'Inspection' ->> 'ID' ~ '^[0-9]+$';
+
+create index if not exists idx_inspection_events_inspection_pending on inspection_events (inspection_id, id) where sent_at is null;
+
+-- +goose Down
+drop index if exists idx_inspection_events_inspection_pending;
+alter table inspection_events
+    drop column if exists inspection_id;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Ключ резервируется до загрузки акта, ответ сохраняется после завершения проверки
+alter table idempotency_keys
+    alter column file_id drop not null,
+    alter column response drop not null;
+
+-- +goose Down
+delete from idempotency_keys
+where response is null;
+
+alter table idempotency_keys
+    alter column file_id set not null,
+    alter column response set not null;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Расход без предыдущего показания не вычисляется
+alter table inspected_devices
+    alter column consumption drop not null;
+
+-- Первым показаниям прибора учета ранее записывался указанный инспектором расход
+update inspected_devices d
+set consumption = null
+where d.declared_consumption is not null
+  and not d.is_replaced
+  and not exists (select 1
+                  from inspected_devices p
+                  where p.device_id = d.device_id
+                    and p.inspection_id != d.inspection_id
+                    and p.created_at < d.created_at);
+
+-- +goose Down
+update inspected_devices
+set consumption = coalesce(declared_consumption, 0)
+where consumption is null;
+
+alter table inspected_devices
+    alter column consumption set not null;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Файлы актов, загруженные в файловый сервис и еще не прикрепленные к проверке. Повторная попытка завершения проверки
+-- или переформирования акта прикрепляет файл с тем же содержимым вместо новой загрузки
+create table if not exists act_uploads
+(
+    file_id       int primary key,
+    inspection_id int         not null references inspections (id) on delete cascade,
+    format        text        not null, -- Формат файла акта: docx или pdf
+    sha256        text        not null, -- SHA-256 файла акта в шестнадцатеричном виде
+    drawn_at      timestamptz not null, -- Время составления акта
+    reason        text,                 -- Причина повторного формирования акта. Если NULL, то акт загружен при завершении проверки
+    abandoned_at  timestamptz,          -- Время, когда успешная попытка не прикрепила файл. Такой файл не используется и подлежит удалению из файлового сервиса
+    created_at    timestamptz not null default now()
+);
+
+create index if not exists act_uploads_inspection_idx on act_uploads (inspection_id) where abandoned_at is null;
+
+-- +goose Down
+drop table if exists act_uploads;
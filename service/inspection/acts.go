@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"inspection-service/cluster/brigade"
 	"inspection-service/cluster/subscriber"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,9 +14,65 @@ import (
 	"github.com/sunshineOfficial/golib/gotime"
 )
 
-func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig brigade.Brigade, contract subscriber.Contract) (*bytes.Buffer, error) {
-	now := gotime.MoscowNow()
+// actKind selects the act template.
+type actKind int
 
+const (
+	actKindUniversal actKind = iota + 1
+	actKindControl
+)
+
+// act is the content of an act independent of its file format. Fields fill the placeholders of the template,
+// rows fill the placeholders of the device table, one map per inspected device. Signatures follow the template.
+type act struct {
+	kind       actKind
+	fields     docx.PlaceholderMap
+	rows       []docx.PlaceholderMap
+	signatures []actSignature
+}
+
+// newAct fills the act of the inspection type with the number drawn up at now and returns it with its file name
+// without extension.
+func newAct(request FinishInspectionRequest, number string, brig brigade.Brigade, contract subscriber.Contract,
+	previous map[int][]InspectedDevice, now time.Time) (act, string, error) {
+	var (
+		a   act
+		err error
+	)
+
+	actType := "о введении ограничения и возобновления"
+	switch request.Type {
+	case TypeLimitation, TypeResumption:
+		a, err = newUniversalAct(request, number, brig, contract, now)
+	case TypeVerification, TypeUnauthorizedConnection:
+		actType = "контроля"
+		a, err = newControlAct(request, number, brig, contract, previous, now)
+	default:
+		return act{}, "", fmt.Errorf("%w: invalid inspection type: %d", ErrValidation, request.Type)
+	}
+
+	if err != nil {
+		return act{}, "", fmt.Errorf("generate act: %w", err)
+	}
+
+	if a.signatures, err = newActSignatures(request.Signatures, a.fields); err != nil {
+		return act{}, "", err
+	}
+
+	name := fmt.Sprintf(
+		"Акт %s №%s от %s (%s)",
+		actType,
+		number,
+		now.In(gotime.Moscow).Format(gotime.DateOnlyNet),
+		contract.Object.Address,
+	)
+
+	return a, name, nil
+}
+
+// newUniversalAct fills the act of limitation and resumption with the number drawn up at now.
+func newUniversalAct(request FinishInspectionRequest, number string, brig brigade.Brigade, contract subscriber.Contract,
+	now time.Time) (act, error) {
 	isLimitation := "☒"
 	isResumption := "☐"
 	if request.Resolution == ResolutionResumed {
@@ -50,7 +107,7 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 	case ResolutionResumed:
 		isEnergyResumed = "☒"
 	default:
-		return nil, fmt.Errorf("invalid resolution: %d", request.Resolution)
+		return act{}, fmt.Errorf("invalid resolution: %d", request.Resolution)
 	}
 
 	energyDate := request.EnergyActionAt.In(gotime.Moscow)
@@ -62,29 +119,24 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 		isByInspector = "☐"
 	}
 
-	device := contract.Object.Devices[0]
-	inspectedDevice := request.InspectedDevices[0]
-
-	isInside := "☐"
-	isOutside := "☐"
-	switch device.PlaceType {
-	case subscriber.DevicePlaceFlat:
-		isInside = "☒"
-	case subscriber.DevicePlaceStairLanding:
-		isOutside = "☒"
-	case subscriber.DevicePlaceOther:
-	default:
-		return nil, fmt.Errorf("invalid device place: %d", device.PlaceType)
+	devices, err := newActDevices(request, contract.Object)
+	if err != nil {
+		return act{}, err
 	}
 
-	seals := make([]string, 0, len(inspectedDevice.InspectedSeals))
-	for _, seal := range inspectedDevice.InspectedSeals {
-		isBroken := "на месте"
-		if seal.IsBroken {
-			isBroken = "сорвана"
-		}
+	place, err := newActPlace(devices)
+	if err != nil {
+		return act{}, err
+	}
 
-		seals = append(seals, fmt.Sprintf("№%d - %s", seal.SealID, isBroken))
+	rows := make([]docx.PlaceholderMap, 0, len(devices))
+	for _, d := range devices {
+		rows = append(rows, docx.PlaceholderMap{
+			"device_type":   d.device.Type,
+			"device_number": d.device.Number,
+			"device_value":  d.inspected.Value,
+			"seals":         d.seals(),
+		})
 	}
 
 	isConsumerLimited := "☐"
@@ -97,8 +149,9 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 		isConsumerLimited = "☒"
 	case ReasonTypeInspectorLimited:
 		isInspectorLimited = "☒"
+	case ReasonTypeResumed:
 	default:
-		return nil, fmt.Errorf("invalid reason type: %d", request.ReasonType)
+		return act{}, fmt.Errorf("invalid reason type: %d", request.ReasonType)
 	}
 
 	notIntroduced := ""
@@ -107,14 +160,14 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 	}
 
 	if len(brig.Inspectors) != 2 {
-		return nil, fmt.Errorf("invalid inspectors len: %d", len(brig.Inspectors))
+		return act{}, fmt.Errorf("invalid inspectors len: %d", len(brig.Inspectors))
 	}
 
 	firstInspector := brig.Inspectors[0]
 	secondInspector := brig.Inspectors[1]
 
 	placeholderMap := docx.PlaceholderMap{
-		"act_number":               request.ID,
+		"act_number":               number,
 		"is_limitation":            isLimitation,
 		"is_resumption":            isResumption,
 		"act_day":                  now.Format("02"),
@@ -142,13 +195,9 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 		"is_by_consumer":           isByConsumer,
 		"is_by_inspector":          isByInspector,
 		"method":                   request.Method,
-		"is_inside":                isInside,
-		"is_outside":               isOutside,
-		"other_place":              device.PlaceDescription,
-		"device_type":              device.Type,
-		"device_number":            device.Number,
-		"device_value":             inspectedDevice.Value,
-		"seals":                    strings.Join(seals, ", "),
+		"is_inside":                place.isInside,
+		"is_outside":               place.isOutside,
+		"other_place":              place.other,
 		"is_consumer_limited":      isConsumerLimited,
 		"is_inspector_limited":     isInspectorLimited,
 		"is_not_introduced":        isNotIntroduced,
@@ -157,17 +206,13 @@ func (s *Service) generateUniversalAct(request FinishInspectionRequest, brig bri
 		"inspector2_initials":      shortFIO(secondInspector.Surname, secondInspector.Name, secondInspector.Patronymic),
 	}
 
-	buf, err := writeDocXTemplate(s.templates.Universal, placeholderMap)
-	if err != nil {
-		return nil, fmt.Errorf("writeDocXTemplate: %w", err)
-	}
-
-	return buf, nil
+	return act{kind: actKindUniversal, fields: placeholderMap, rows: rows}, nil
 }
 
-func (s *Service) generateControlAct(request FinishInspectionRequest, brig brigade.Brigade, contract subscriber.Contract, devices []InspectedDevice) (*bytes.Buffer, error) {
-	now := gotime.MoscowNow()
-
+// newControlAct fills the control act with the number drawn up at now. previous holds earlier readings of each inspected
+// device, newest first.
+func newControlAct(request FinishInspectionRequest, number string, brig brigade.Brigade, contract subscriber.Contract,
+	previous map[int][]InspectedDevice, now time.Time) (act, error) {
 	isVerification := "☒"
 	isUnauthorizedConnection := "☐"
 	if request.Type == TypeUnauthorizedConnection {
@@ -199,7 +244,7 @@ func (s *Service) generateControlAct(request FinishInspectionRequest, brig briga
 	case ResolutionStopped:
 		isEnergyStopped = "☒"
 	default:
-		return nil, fmt.Errorf("invalid resolution: %d", request.Resolution)
+		return act{}, fmt.Errorf("invalid resolution: %d", request.Resolution)
 	}
 
 	energyDate := request.EnergyActionAt.In(gotime.Moscow)
@@ -247,42 +292,50 @@ func (s *Service) generateControlAct(request FinishInspectionRequest, brig briga
 		unauthorizedDescription = *request.UnauthorizedDescription
 	}
 
-	device := contract.Object.Devices[0]
-	inspectedDevice := request.InspectedDevices[0]
-
-	isInside := "☐"
-	isOutside := "☐"
-	switch device.PlaceType {
-	case subscriber.DevicePlaceFlat:
-		isInside = "☒"
-	case subscriber.DevicePlaceStairLanding:
-		isOutside = "☒"
-	case subscriber.DevicePlaceOther:
-	default:
-		return nil, fmt.Errorf("invalid device place: %d", device.PlaceType)
+	devices, err := newActDevices(request, contract.Object)
+	if err != nil {
+		return act{}, err
 	}
 
-	oldDevice := InspectedDevice{
-		Value:     decimal.Zero,
-		CreatedAt: now,
-	}
-	if len(devices) != 0 {
-		oldDevice = devices[0]
-		oldDevice.CreatedAt = oldDevice.CreatedAt.In(gotime.Moscow)
+	place, err := newActPlace(devices)
+	if err != nil {
+		return act{}, err
+	}
+
+	// The table header holds a single date of previous readings, so it is taken from the first device that has one
+	// and left blank when no device has. Readings of other devices taken on another day carry their own date.
+	var oldValueDate time.Time
+	oldValueDay, oldValueMonth, oldValueYear := "", "", ""
+	for _, d := range devices {
+		if oldDevice, ok := previousReading(previous, d.device.ID); ok {
+			oldValueDate = oldDevice.CreatedAt
+			oldValueDay, oldValueMonth, oldValueYear = oldValueDate.Format("02"), oldValueDate.Format("01"), oldValueDate.Format("2006")
+			break
+		}
 	}
 
-	seals := make([]string, 0, len(inspectedDevice.InspectedSeals))
-	for _, seal := range inspectedDevice.InspectedSeals {
-		isBroken := "на месте"
-		if seal.IsBroken {
-			isBroken = "сорвана"
+	rows := make([]docx.PlaceholderMap, 0, len(devices))
+	for _, d := range devices {
+		oldValue := noPreviousReading
+		if oldDevice, ok := previousReading(previous, d.device.ID); ok {
+			oldValue = oldDevice.Value.String()
+			if oldDevice.CreatedAt.Format(time.DateOnly) != oldValueDate.Format(time.DateOnly) {
+				oldValue = fmt.Sprintf("%s (%s)", oldValue, oldDevice.CreatedAt.Format("02.01.2006"))
+			}
 		}
 
-		seals = append(seals, fmt.Sprintf("№%d - %s", seal.SealID, isBroken))
+		rows = append(rows, docx.PlaceholderMap{
+			"device_type":        d.device.Type,
+			"device_number":      d.device.Number,
+			"device_value":       d.inspected.Value,
+			"old_device_value":   oldValue,
+			"device_consumption": optionalDecimal(d.inspected.ComputedConsumption),
+			"seals":              d.seals(),
+		})
 	}
 
 	if len(brig.Inspectors) != 2 {
-		return nil, fmt.Errorf("invalid inspectors len: %d", len(brig.Inspectors))
+		return act{}, fmt.Errorf("invalid inspectors len: %d", len(brig.Inspectors))
 	}
 
 	firstInspector := brig.Inspectors[0]
@@ -294,7 +347,7 @@ func (s *Service) generateControlAct(request FinishInspectionRequest, brig briga
 	}
 
 	placeholderMap := docx.PlaceholderMap{
-		"act_number":                    request.ID,
+		"act_number":                    number,
 		"is_verification":               isVerification,
 		"is_unauthorized_connection":    isUnauthorizedConnection,
 		"act_day":                       now.Format("02"),
@@ -335,29 +388,148 @@ func (s *Service) generateControlAct(request FinishInspectionRequest, brig briga
 		"is_not_unauthorized_consumers": isNotUnauthorizedConsumers,
 		"is_unauthorized_consumers":     isUnauthorizedConsumers,
 		"unauthorized_description":      unauthorizedDescription,
-		"is_inside":                     isInside,
-		"is_outside":                    isOutside,
-		"other_place":                   device.PlaceDescription,
-		"device_type":                   device.Type,
-		"device_number":                 device.Number,
-		"device_value":                  inspectedDevice.Value,
-		"old_value_day":                 oldDevice.CreatedAt.Format("02"),
-		"old_value_month":               oldDevice.CreatedAt.Format("01"),
-		"old_value_year":                oldDevice.CreatedAt.Year(),
-		"old_device_value":              oldDevice.Value,
-		"device_consumption":            inspectedDevice.Consumption,
-		"seals":                         strings.Join(seals, ", "),
+		"is_inside":                     place.isInside,
+		"is_outside":                    place.isOutside,
+		"other_place":                   place.other,
+		"old_value_day":                 oldValueDay,
+		"old_value_month":               oldValueMonth,
+		"old_value_year":                oldValueYear,
 		"unauthorized_explanation":      unauthorizedExplanation,
 		"inspector1_initials":           shortFIO(firstInspector.Surname, firstInspector.Name, firstInspector.Patronymic),
 		"inspector2_initials":           shortFIO(secondInspector.Surname, secondInspector.Name, secondInspector.Patronymic),
 	}
 
-	buf, err := writeDocXTemplate(s.templates.Control, placeholderMap)
-	if err != nil {
-		return nil, fmt.Errorf("writeDocXTemplate: %w", err)
+	return act{kind: actKindControl, fields: placeholderMap, rows: rows}, nil
+}
+
+// actDevice is an inspected device together with its data from the subscriber service.
+type actDevice struct {
+	device    subscriber.Device
+	inspected InspectedDeviceRequest
+}
+
+func newActDevices(request FinishInspectionRequest, object subscriber.Object) ([]actDevice, error) {
+	if len(request.InspectedDevices) == 0 {
+		return nil, fmt.Errorf("no inspected devices")
+	}
+
+	objectDevices := make(map[int]subscriber.Device, len(object.Devices))
+	for _, d := range object.Devices {
+		objectDevices[d.ID] = d
+	}
+
+	devices := make([]actDevice, 0, len(request.InspectedDevices))
+	for _, inspected := range request.InspectedDevices {
+		device, ok := objectDevices[inspected.DeviceID]
+		if !ok {
+			return nil, fmt.Errorf("device %d not found in object %d", inspected.DeviceID, object.ID)
+		}
+
+		for _, seal := range inspected.InspectedSeals {
+			if !hasSeal(device, seal.SealID) {
+				return nil, fmt.Errorf("seal %d not found in device %d", seal.SealID, device.ID)
+			}
+		}
+
+		devices = append(devices, actDevice{device: device, inspected: inspected})
+	}
+
+	return devices, nil
+}
+
+// seals lists the inspected seals by the number printed on them and the place where they are installed.
+func (d actDevice) seals() string {
+	deviceSeals := make(map[int]subscriber.Seal, len(d.device.Seals))
+	for _, seal := range d.device.Seals {
+		deviceSeals[seal.ID] = seal
+	}
+
+	seals := make([]string, 0, len(d.inspected.InspectedSeals))
+	for _, inspected := range d.inspected.InspectedSeals {
+		seals = append(seals, sealLabel(deviceSeals[inspected.SealID], inspected.IsBroken))
+	}
+
+	return strings.Join(seals, ", ")
+}
+
+// sealLabel describes an inspected seal by the number printed on it and the place where it is installed.
+func sealLabel(seal subscriber.Seal, isBroken bool) string {
+	state := "на месте"
+	if isBroken {
+		state = "сорвана"
+	}
+
+	if len(seal.Place) == 0 {
+		return fmt.Sprintf("№%s - %s", seal.Number, state)
 	}
 
-	return buf, nil
+	return fmt.Sprintf("№%s (%s) - %s", seal.Number, seal.Place, state)
+}
+
+type actPlace struct {
+	isInside  string
+	isOutside string
+	other     string
+}
+
+// newActPlace marks every place where the devices are installed. Descriptions are prefixed with device numbers
+// when there are several devices, so that the reader can tell which device is where.
+func newActPlace(devices []actDevice) (actPlace, error) {
+	place := actPlace{
+		isInside:  "☐",
+		isOutside: "☐",
+	}
+
+	descriptions := make([]string, 0, len(devices))
+	for _, d := range devices {
+		switch d.device.PlaceType {
+		case subscriber.DevicePlaceFlat:
+			place.isInside = "☒"
+		case subscriber.DevicePlaceStairLanding:
+			place.isOutside = "☒"
+		case subscriber.DevicePlaceOther:
+		default:
+			return actPlace{}, fmt.Errorf("invalid device place: %d", d.device.PlaceType)
+		}
+
+		if len(d.device.PlaceDescription) == 0 {
+			continue
+		}
+
+		if len(devices) == 1 {
+			descriptions = append(descriptions, d.device.PlaceDescription)
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("№%s - %s", d.device.Number, d.device.PlaceDescription))
+		}
+	}
+
+	place.other = strings.Join(descriptions, ", ")
+
+	return place, nil
+}
+
+// noPreviousReading stands in the act for the old reading of a device that has never been inspected.
+const noPreviousReading = "нет предыдущих показаний"
+
+// previousReading returns the latest earlier reading of the device. It reports false when the device has none.
+func previousReading(previous map[int][]InspectedDevice, deviceID int) (InspectedDevice, bool) {
+	readings := previous[deviceID]
+	if len(readings) == 0 {
+		return InspectedDevice{}, false
+	}
+
+	reading := readings[0]
+	reading.CreatedAt = reading.CreatedAt.In(gotime.Moscow)
+
+	return reading, true
+}
+
+func optionalDecimal(d *decimal.Decimal) string {
+	if d == nil {
+		return ""
+	}
+
+	return d.String()
 }
 
 func russianMonth(month time.Month) string {
@@ -411,8 +583,80 @@ func shortFIO(surname, name, patronymic string) string {
 	return result
 }
 
-func writeDocXTemplate(path string, placeholderMap docx.PlaceholderMap) (*bytes.Buffer, error) {
-	doc, err := docx.Open(path)
+// label is the Russian name of the status as it is written in documents.
+func (s Status) label() string {
+	switch s {
+	case StatusInWork:
+		return "В работе"
+	case StatusDone:
+		return "Выполнена"
+	case StatusSubmitted:
+		return "На проверке"
+	case StatusApproved:
+		return "Принята"
+	case StatusRejected:
+		return "Возвращена"
+	case StatusCancelled:
+		return "Отменена"
+	default:
+		return ""
+	}
+}
+
+// label is the Russian name of the inspection type as it is written in documents.
+func (t Type) label() string {
+	switch t {
+	case TypeLimitation:
+		return "Ограничение"
+	case TypeResumption:
+		return "Возобновление"
+	case TypeVerification:
+		return "Проверка"
+	case TypeUnauthorizedConnection:
+		return "Самовольное подключение"
+	default:
+		return ""
+	}
+}
+
+// label is the Russian name of the resolution as it is written in documents.
+func (r Resolution) label() string {
+	switch r {
+	case ResolutionLimited:
+		return "Введено ограничение"
+	case ResolutionStopped:
+		return "Приостановлено"
+	case ResolutionResumed:
+		return "Возобновлено"
+	default:
+		return ""
+	}
+}
+
+// label is the Russian name of the party who took the energy action as it is written in documents.
+func (m MethodBy) label() string {
+	switch m {
+	case MethodByConsumer:
+		return "Потребителем"
+	case MethodByInspector:
+		return "Исполнителем"
+	default:
+		return ""
+	}
+}
+
+// writeDocXTemplate fills the template with placeholderMap. The table row that holds the placeholders of rows
+// is repeated once for every element of rows.
+func writeDocXTemplate(template []byte, placeholderMap docx.PlaceholderMap, rows []docx.PlaceholderMap) (*bytes.Buffer, error) {
+	var err error
+	if len(rows) != 0 {
+		template, err = repeatTableRow(template, placeholderMap, rows)
+		if err != nil {
+			return nil, fmt.Errorf("repeat table row: %w", err)
+		}
+	}
+
+	doc, err := docx.OpenBytes(template)
 	if err != nil {
 		return nil, fmt.Errorf("open template: %w", err)
 	}
@@ -430,3 +674,77 @@ func writeDocXTemplate(path string, placeholderMap docx.PlaceholderMap) (*bytes.
 
 	return &buf, nil
 }
+
+// repeatTableRow copies the table row with the row placeholders once per row. Placeholders of the i-th copy
+// get the _i suffix, and their values are added to placeholderMap.
+func repeatTableRow(template []byte, placeholderMap docx.PlaceholderMap, rows []docx.PlaceholderMap) ([]byte, error) {
+	doc, err := docx.OpenBytes(template)
+	if err != nil {
+		return nil, fmt.Errorf("open template: %w", err)
+	}
+
+	defer doc.Close()
+
+	keys := make([]string, 0, len(rows[0]))
+	for key := range rows[0] {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	document := string(doc.GetFile(docx.DocumentXml))
+
+	rowStart, rowEnd, err := findTableRow(document, docx.AddPlaceholderDelimiter(keys[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	row := document[rowStart:rowEnd]
+
+	var repeated strings.Builder
+	for i, values := range rows {
+		copied := row
+		for _, key := range keys {
+			placeholder := docx.AddPlaceholderDelimiter(key)
+			if !strings.Contains(row, placeholder) {
+				return nil, fmt.Errorf("placeholder %s is not in the row or is split between runs", placeholder)
+			}
+
+			indexed := fmt.Sprintf("%s_%d", key, i)
+			copied = strings.ReplaceAll(copied, placeholder, docx.AddPlaceholderDelimiter(indexed))
+			placeholderMap[indexed] = values[key]
+		}
+
+		repeated.WriteString(copied)
+	}
+
+	if err = doc.SetFile(docx.DocumentXml, []byte(document[:rowStart]+repeated.String()+document[rowEnd:])); err != nil {
+		return nil, fmt.Errorf("set document: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err = doc.Write(&buf); err != nil {
+		return nil, fmt.Errorf("write template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// findTableRow returns the bounds of the w:tr element that contains placeholder.
+func findTableRow(document, placeholder string) (int, int, error) {
+	pos := strings.Index(document, placeholder)
+	if pos < 0 {
+		return 0, 0, fmt.Errorf("placeholder %s not found", placeholder)
+	}
+
+	start := max(strings.LastIndex(document[:pos], "<w:tr>"), strings.LastIndex(document[:pos], "<w:tr "))
+	if start < 0 {
+		return 0, 0, fmt.Errorf("placeholder %s is not in a table row", placeholder)
+	}
+
+	end := strings.Index(document[pos:], "</w:tr>")
+	if end < 0 {
+		return 0, 0, fmt.Errorf("table row with placeholder %s is not closed", placeholder)
+	}
+
+	return start, pos + end + len("</w:tr>"), nil
+}
@@ -0,0 +1,135 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	clusterbrigade "inspection-service/cluster/brigade"
+	clustersubscriber "inspection-service/cluster/subscriber"
+
+	"github.com/shopspring/decimal"
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+// newTestControlAct returns a control act with two devices and the text expected in every format.
+func newTestControlAct(t *testing.T) (act, []string) {
+	t.Helper()
+
+	contract := clustersubscriber.Contract{
+		Object: clustersubscriber.Object{
+			ID: 5,
+			Devices: []clustersubscriber.Device{
+				{
+					ID:        11,
+					Type:      "Меркурий",
+					Number:    "D-11",
+					PlaceType: clustersubscriber.DevicePlaceFlat,
+					Seals:     []clustersubscriber.Seal{{ID: 21, DeviceID: 11, Number: "S-0021", Place: "клеммная крышка"}},
+				},
+				{ID: 12, Type: "Энергомера", Number: "D-12", PlaceType: clustersubscriber.DevicePlaceOther, PlaceDescription: "подвал"},
+			},
+		},
+	}
+	brig := clusterbrigade.Brigade{
+		Inspectors: []clusterbrigade.Inspector{{Surname: "Петров", Name: "Петр"}, {Surname: "Сидоров", Name: "Сидор"}},
+	}
+	consumption := decimal.RequireFromString("40")
+	request := FinishInspectionRequest{
+		ID:             42,
+		Type:           TypeVerification,
+		Resolution:     ResolutionLimited,
+		EnergyActionAt: time.Date(2026, time.May, 9, 12, 0, 0, 0, time.UTC),
+		InspectedDevices: []InspectedDeviceRequest{
+			{
+				DeviceID:       11,
+				Value:          decimal.RequireFromString("120.5"),
+				InspectedSeals: []InspectedSealRequest{{SealID: 21, IsBroken: true}},
+			},
+			{DeviceID: 12, Value: decimal.RequireFromString("340"), ComputedConsumption: &consumption},
+		},
+	}
+	previous := map[int][]InspectedDevice{
+		12: {{DeviceID: 12, Value: decimal.RequireFromString("300"), CreatedAt: time.Date(2026, time.April, 1, 9, 0, 0, 0, time.UTC)}},
+	}
+
+	a, err := newControlAct(request, "7", brig, contract, previous, gotime.MoscowNow())
+	if err != nil {
+		t.Fatalf("newControlAct returned error: %v", err)
+	}
+
+	return a, []string{"Меркурий №D-11", "120.5", noPreviousReading, "Энергомера №D-12", "340", "300", "40", "№D-12 - подвал", "№S-0021 (клеммная крышка) - сорвана"}
+}
+
+func TestDocxRendererRendersEveryDevice(t *testing.T) {
+	a, wants := newTestControlAct(t)
+
+	template, err := os.ReadFile("templates/control_act.docx")
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	buf, err := docxRenderer{template: template}.render(a)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	text := documentText(t, buf.Bytes())
+	for _, want := range wants {
+		if !strings.Contains(text, want) {
+			t.Fatalf("act does not contain %q", want)
+		}
+	}
+	if strings.Contains(text, "№21") {
+		t.Fatalf("act contains internal seal id: %s", text)
+	}
+	if strings.Contains(text, "{") {
+		t.Fatalf("act contains unreplaced placeholders: %s", text)
+	}
+}
+
+func TestNewActDevicesRejectsForeignSeal(t *testing.T) {
+	object := clustersubscriber.Object{
+		ID: 5,
+		Devices: []clustersubscriber.Device{
+			{ID: 11, Seals: []clustersubscriber.Seal{{ID: 21, DeviceID: 11}}},
+			{ID: 12, Seals: []clustersubscriber.Seal{{ID: 22, DeviceID: 12}}},
+		},
+	}
+	request := FinishInspectionRequest{
+		InspectedDevices: []InspectedDeviceRequest{
+			{DeviceID: 11, InspectedSeals: []InspectedSealRequest{{SealID: 22}}},
+		},
+	}
+
+	if _, err := newActDevices(request, object); err == nil {
+		t.Fatal("newActDevices returned nil error, want foreign seal error")
+	}
+}
+
+func documentText(t *testing.T, docx []byte) string {
+	t.Helper()
+
+	archive, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		t.Fatalf("zip.NewReader returned error: %v", err)
+	}
+
+	rc, err := archive.Open("word/document.xml")
+	if err != nil {
+		t.Fatalf("open document.xml: %v", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read document.xml: %v", err)
+	}
+
+	return regexp.MustCompile(`<[^>]+>`).ReplaceAllString(string(data), "")
+}
@@ -0,0 +1,45 @@
+package inspection
+
+import (
+	"fmt"
+)
+
+// computeConsumption sets ComputedConsumption of every inspected device from its previous reading. A device without
+// one gets none.
+// previous holds earlier readings of each device, newest first.
+//
+// A reading lower than the previous one is accepted only with an explicit flag: IsReplaced means a new meter
+// counting from zero, IsRollover means the meter has passed its maximum and started over. The capacity of a meter
+// is not known to the service, so consumption across a rollover is taken as declared; it has to cover at least
+// the current reading, and the stored reading keeps IsRollover for review.
+func (r *FinishInspectionRequest) computeConsumption(previous map[int][]InspectedDevice) error {
+	var errs fieldErrors
+
+	for i := range r.InspectedDevices {
+		d := &r.InspectedDevices[i]
+		field := fmt.Sprintf("InspectedDevices[%d]", i)
+
+		readings := previous[d.DeviceID]
+		switch {
+		case d.IsReplaced:
+			d.ComputedConsumption = &d.Value
+		case len(readings) == 0:
+			// Nothing to compare with, the first reading of a device has no consumption.
+			d.ComputedConsumption = nil
+		case d.Value.GreaterThanOrEqual(readings[0].Value):
+			consumption := d.Value.Sub(readings[0].Value)
+			d.ComputedConsumption = &consumption
+		case d.IsRollover:
+			if d.Consumption.LessThan(d.Value) {
+				errs.add(field+".Consumption", "consumption across a rollover must be at least the reading %s", d.Value)
+				continue
+			}
+
+			d.ComputedConsumption = &d.Consumption
+		default:
+			errs.add(field+".Value", "reading %s is lower than the previous reading %s; set IsReplaced or IsRollover", d.Value, readings[0].Value)
+		}
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,104 @@
+package inspection
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestComputeConsumption(t *testing.T) {
+	previous := map[int][]InspectedDevice{
+		11: {{DeviceID: 11, Value: decimal.RequireFromString("99950.5")}},
+	}
+
+	tests := []struct {
+		name   string
+		device InspectedDeviceRequest
+		want   string
+	}{
+		{
+			name:   "regular reading",
+			device: InspectedDeviceRequest{DeviceID: 11, Value: decimal.RequireFromString("99990"), Consumption: decimal.RequireFromString("1")},
+			want:   "39.5",
+		},
+		{
+			name:   "rollover",
+			device: InspectedDeviceRequest{DeviceID: 11, Value: decimal.RequireFromString("20"), Consumption: decimal.RequireFromString("69.5"), IsRollover: true},
+			want:   "69.5",
+		},
+		{
+			name:   "replaced meter",
+			device: InspectedDeviceRequest{DeviceID: 11, Value: decimal.RequireFromString("3.2"), IsReplaced: true},
+			want:   "3.2",
+		},
+		{
+			name:   "first reading",
+			device: InspectedDeviceRequest{DeviceID: 12, Value: decimal.RequireFromString("500"), Consumption: decimal.RequireFromString("12")},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := FinishInspectionRequest{InspectedDevices: []InspectedDeviceRequest{tt.device}}
+			if err := request.computeConsumption(previous); err != nil {
+				t.Fatalf("computeConsumption returned error: %v", err)
+			}
+
+			got := request.InspectedDevices[0].ComputedConsumption
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("ComputedConsumption = %s, want nil", got)
+				}
+				return
+			}
+			if got == nil || !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("ComputedConsumption = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeConsumptionRejectsDecreasedReading(t *testing.T) {
+	request := FinishInspectionRequest{
+		InspectedDevices: []InspectedDeviceRequest{{DeviceID: 11, Value: decimal.RequireFromString("100")}},
+	}
+	previous := map[int][]InspectedDevice{
+		11: {{DeviceID: 11, Value: decimal.RequireFromString("150")}},
+	}
+
+	err := request.computeConsumption(previous)
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("computeConsumption error = %v, want ValidationError", err)
+	}
+	if validationErr.Fields[0].Field != "InspectedDevices[0].Value" {
+		t.Fatalf("field = %q, want %q", validationErr.Fields[0].Field, "InspectedDevices[0].Value")
+	}
+}
+
+func TestComputeConsumptionRejectsRolloverConsumptionBelowReading(t *testing.T) {
+	request := FinishInspectionRequest{
+		InspectedDevices: []InspectedDeviceRequest{{
+			DeviceID:    11,
+			Value:       decimal.RequireFromString("20"),
+			Consumption: decimal.RequireFromString("5"),
+			IsRollover:  true,
+		}},
+	}
+	previous := map[int][]InspectedDevice{
+		11: {{DeviceID: 11, Value: decimal.RequireFromString("99950.5")}},
+	}
+
+	err := request.computeConsumption(previous)
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("computeConsumption error = %v, want ValidationError", err)
+	}
+	if validationErr.Fields[0].Field != "InspectedDevices[0].Consumption" {
+		t.Fatalf("field = %q, want %q", validationErr.Fields[0].Field, "InspectedDevices[0].Consumption")
+	}
+}
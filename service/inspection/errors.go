@@ -1,7 +1,178 @@
 package inspection
 
-import "errors"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
 
+// Error kinds. Every domain error wraps one of them, so the transport layer can choose a response status
+// without knowing concrete errors.
 var (
-	ErrBlurredPhoto = errors.New("photo is blurred")
+	ErrNotFound            = errors.New("not found")
+	ErrValidation          = errors.New("validation failed")
+	ErrConflict            = errors.New("conflict")
+	ErrUpstreamUnavailable = errors.New("upstream service unavailable")
+	ErrForbidden           = errors.New("forbidden")
 )
+
+var (
+	ErrBlurredPhoto           = errors.New("photo is blurred")
+	ErrNotEditable            = newCodedError(ErrConflict, "inspection_not_editable", "inspection cannot be changed in its current status")
+	ErrIdempotencyKeyMismatch = newCodedError(ErrConflict, "idempotency_key_reused", "idempotency key is already used for another inspection")
+	ErrIdempotencyKeyTooLong  = newCodedError(ErrValidation, "idempotency_key_too_long", "idempotency key is too long")
+	ErrIdempotencyKeyInUse    = newCodedError(ErrConflict, "idempotency_key_in_use", "request with this idempotency key is still in progress")
+	ErrRejectCommentRequired  = newCodedError(ErrValidation, "comment_required", "comment is required to reject an inspection")
+	ErrActNotRegenerable      = newCodedError(ErrConflict, "act_not_regenerable", "act can be regenerated only for a submitted inspection")
+	ErrReviewerRequired       = newCodedError(ErrForbidden, "reviewer_required", "only reviewers can review inspections")
+	ErrSelfReview             = newCodedError(ErrForbidden, "self_review", "inspection cannot be reviewed by the user who submitted it")
+	ErrActSigningDisabled     = newCodedError(ErrNotFound, "act_signing_disabled", "acts are not signed by the service")
+	ErrAdminRequired          = newCodedError(ErrForbidden, "admin_required", "only admins can manage act templates")
+)
+
+// errorKindCodes are the codes of errors of each kind that have no code of their own.
+var errorKindCodes = []struct {
+	kind error
+	code string
+}{
+	{kind: ErrNotFound, code: "not_found"},
+	{kind: ErrValidation, code: "validation_failed"},
+	{kind: ErrConflict, code: "conflict"},
+	{kind: ErrUpstreamUnavailable, code: "upstream_unavailable"},
+	{kind: ErrForbidden, code: "forbidden"},
+}
+
+// ErrorCode returns the code of a domain error: its own code, or the code of its kind. Errors of no kind
+// get internal_error.
+func ErrorCode(err error) string {
+	var coded CodedError
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+
+	for _, k := range errorKindCodes {
+		if errors.Is(err, k.kind) {
+			return k.code
+		}
+	}
+
+	return "internal_error"
+}
+
+// CodedError is a domain error with a stable machine-readable code.
+type CodedError interface {
+	error
+	Code() string
+}
+
+type codedError struct {
+	kind    error
+	code    string
+	message string
+}
+
+func newCodedError(kind error, code, message string) *codedError {
+	return &codedError{
+		kind:    kind,
+		code:    code,
+		message: message,
+	}
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+func (e *codedError) Unwrap() error {
+	return e.kind
+}
+
+func (e *codedError) Code() string {
+	return e.code
+}
+
+type NotFoundError struct {
+	Entity string
+	ID     int
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Entity, e.ID)
+}
+
+func (e NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+func (e NotFoundError) Code() string {
+	return e.Entity + "_not_found"
+}
+
+// notFound replaces sql.ErrNoRows with NotFoundError and returns other errors unchanged.
+func notFound(err error, entity string, id int) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFoundError{Entity: entity, ID: id}
+	}
+
+	return err
+}
+
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e TransitionError) Error() string {
+	return fmt.Sprintf("illegal status transition from %s to %s", e.From, e.To)
+}
+
+func (e TransitionError) Unwrap() error {
+	return ErrConflict
+}
+
+func (e TransitionError) Code() string {
+	return "illegal_status_transition"
+}
+
+// BlurredPhotoError is returned when the analyzer rejects a photo. The scores let the inspector see how far the photo is from passing.
+type BlurredPhotoError struct {
+	BlurScore    string
+	QualityScore string
+}
+
+func (e BlurredPhotoError) Error() string {
+	return fmt.Sprintf("photo is blurred (blur score %s, quality score %s)", e.BlurScore, e.QualityScore)
+}
+
+func (e BlurredPhotoError) Is(target error) bool {
+	return target == ErrBlurredPhoto
+}
+
+func (e BlurredPhotoError) Unwrap() error {
+	return ErrValidation
+}
+
+func (e BlurredPhotoError) Code() string {
+	return "blurred_photo"
+}
+
+type UpstreamError struct {
+	Service string
+	Err     error
+}
+
+func (e UpstreamError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Service, e.Err)
+}
+
+func (e UpstreamError) Unwrap() []error {
+	return []error{ErrUpstreamUnavailable, e.Err}
+}
+
+func (e UpstreamError) Code() string {
+	return "upstream_unavailable"
+}
+
+func upstream(service string, err error) error {
+	return UpstreamError{Service: service, Err: err}
+}
@@ -0,0 +1,46 @@
+package inspection
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+		code string
+	}{
+		{name: "not found", err: NotFoundError{Entity: "inspection", ID: 1}, kind: ErrNotFound, code: "inspection_not_found"},
+		{name: "transition", err: TransitionError{From: StatusDone, To: StatusInWork}, kind: ErrConflict, code: "illegal_status_transition"},
+		{name: "not editable", err: ErrNotEditable, kind: ErrConflict, code: "inspection_not_editable"},
+		{name: "blurred photo", err: BlurredPhotoError{BlurScore: "0.1", QualityScore: "0.2"}, kind: ErrValidation, code: "blurred_photo"},
+		{name: "upstream", err: upstream("task-service", errors.New("connection refused")), kind: ErrUpstreamUnavailable, code: "upstream_unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", tt.err)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("errors.Is(%v, %v) = false", err, tt.kind)
+			}
+
+			var coded CodedError
+			if !errors.As(err, &coded) {
+				t.Fatalf("errors.As(%v, CodedError) = false", err)
+			}
+			if coded.Code() != tt.code {
+				t.Fatalf("code = %q, want %q", coded.Code(), tt.code)
+			}
+		})
+	}
+}
+
+func TestBlurredPhotoErrorIsErrBlurredPhoto(t *testing.T) {
+	err := fmt.Errorf("attach photo: %w", BlurredPhotoError{BlurScore: "0.1", QualityScore: "0.2"})
+	if !errors.Is(err, ErrBlurredPhoto) {
+		t.Fatalf("errors.Is(%v, ErrBlurredPhoto) = false", err)
+	}
+}
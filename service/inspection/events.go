@@ -2,7 +2,9 @@ package inspection
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"inspection-service/cluster/task"
 
@@ -32,9 +34,9 @@ func (s *Service) SubscriberOnTaskEvent(mainCtx context.Context, log golog.Logge
 		case task.EventTypeAdd:
 			err = s.handleAddedTask(ctx, event.Task)
 		case task.EventTypeStart:
-			err = s.handleStartedTask(ctx, log, event.Task)
+			err = s.handleStartedTask(ctx, event.Task, event.UserID)
 		case task.EventTypeFinish:
-			err = s.handleFinishedTask(ctx, event.Task)
+			err = s.handleFinishedTask(ctx, event.Task, event.UserID)
 		default:
 			err = fmt.Errorf("unknown event type: %v", event.Type)
 		}
@@ -50,21 +52,59 @@ func (s *Service) handleAddedTask(ctx context.Context, t task.Task) error {
 	return nil
 }
 
-func (s *Service) handleStartedTask(ctx context.Context, log golog.Logger, t task.Task) error {
+func (s *Service) handleStartedTask(ctx context.Context, t task.Task, userID int) error {
 	if t.Status != task.StatusInWork {
 		return fmt.Errorf("invalid task status: %v", t.Status)
 	}
 
-	ins, err := s.repository.StartInspection(ctx, t.ID)
-	if err != nil {
-		return fmt.Errorf("start inspection: %v", err)
-	}
+	return s.repository.WithTx(ctx, func(tx Repository) error {
+		ins, err := tx.StartInspection(ctx, t.ID, t.BrigadeID)
+		if err != nil {
+			return fmt.Errorf("start inspection: %w", err)
+		}
 
-	go s.publisher.Publish(goctx.Wrap(ctx), log, EventTypeStart, ins)
+		err = tx.AddStatusChange(ctx, StatusChange{
+			InspectionID: ins.ID,
+			To:           ins.Status,
+			UserID:       userID,
+		})
+		if err != nil {
+			return fmt.Errorf("add status change: %w", err)
+		}
 
-	return nil
+		if err = tx.AddEvent(ctx, newEvent(goctx.Wrap(ctx), EventTypeStart, ins)); err != nil {
+			return fmt.Errorf("add start event: %w", err)
+		}
+
+		return nil
+	})
 }
 
-func (s *Service) handleFinishedTask(ctx context.Context, t task.Task) error {
-	return nil
+// handleFinishedTask closes the inspection of a finished task: an approved inspection becomes done,
+// and an inspection that is not submitted, never or since it was rejected, is cancelled. A submitted inspection
+// waits for its review and becomes done when it is approved.
+func (s *Service) handleFinishedTask(ctx context.Context, t task.Task, userID int) error {
+	return s.repository.WithTx(ctx, func(tx Repository) error {
+		ins, err := tx.GetByTaskID(ctx, t.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("get inspection by task id: %w", err)
+		}
+
+		status, err := tx.GetStatusForUpdate(ctx, ins.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", err)
+		}
+
+		switch status {
+		case StatusApproved:
+			return transition(ctx, tx, ins.ID, status, StatusDone, userID, nil)
+		case StatusInWork, StatusRejected:
+			return transition(ctx, tx, ins.ID, status, StatusCancelled, userID, nil)
+		default:
+			return nil
+		}
+	})
 }
@@ -0,0 +1,373 @@
+package inspection
+
+import (
+	"encoding/csv"
+	"fmt"
+	"inspection-service/cluster/file"
+	"inspection-service/cluster/subscriber"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/gotime"
+	"github.com/sunshineOfficial/golib/pagination"
+)
+
+// exportBatchSize is the number of inspections read from the database at a time during export.
+const exportBatchSize = 500
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatXLSX ExportFormat = "xlsx"
+)
+
+func (f ExportFormat) Validate() error {
+	switch f {
+	case ExportFormatCSV, ExportFormatXLSX:
+		return nil
+	default:
+		return fmt.Errorf("format must be %q or %q", ExportFormatCSV, ExportFormatXLSX)
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+func (f ExportFormat) FileName() string {
+	return "inspections." + string(f)
+}
+
+type ExportRequest struct {
+	Filter Filter
+	Format ExportFormat
+}
+
+func (r ExportRequest) Validate() error {
+	if err := r.Filter.Validate(); err != nil {
+		return err
+	}
+
+	if err := r.Format.Validate(); err != nil {
+		var errs fieldErrors
+		errs.add("format", "%s", err)
+		return errs.err()
+	}
+
+	return nil
+}
+
+// tableWriter writes an export table row by row. Close finishes the file but leaves the underlying writer open.
+type tableWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+type exportColumn struct {
+	label   string
+	numeric bool
+}
+
+var exportColumns = []exportColumn{
+	{label: "№ проверки", numeric: true},
+	{label: "№ задачи", numeric: true},
+	{label: "Статус"},
+	{label: "Тип проверки"},
+	{label: "Результат"},
+	{label: "Дата проверки"},
+	{label: "Дата и время действия над подачей электроэнергии"},
+	{label: "Кем введено"},
+	{label: "Способ"},
+	{label: "Нарушение выявлено"},
+	{label: "Самовольное подключение"},
+	{label: "Прибор учета"},
+	{label: "Показание", numeric: true},
+	{label: "Расход, кВтч", numeric: true},
+	{label: "Пломбы"},
+	{label: "Акт"},
+}
+
+// Export writes inspections matching the filter to w as a table with one row per inspected device.
+// Inspections are read in batches, so the whole result is never held in memory.
+// Nothing is written to w when the request is invalid.
+func (s *Service) Export(ctx goctx.Context, request ExportRequest, w io.Writer, headers file.ForwardedHeaders) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	page := Page{Limit: exportBatchSize, SortBy: SortByID, Direction: SortAsc}
+	list, err := s.repository.GetPage(ctx, request.Filter, page)
+	if err != nil {
+		return fmt.Errorf("get inspections: %w", err)
+	}
+
+	table, err := newTableWriter(request.Format, w)
+	if err != nil {
+		return fmt.Errorf("new table writer: %w", err)
+	}
+
+	header := make([]string, 0, len(exportColumns))
+	for _, c := range exportColumns {
+		header = append(header, c.label)
+	}
+
+	if err = table.WriteRow(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	devices := make(map[int]subscriber.Device)
+	for {
+		if err = s.fillActFileURLs(ctx, list.Items, headers); err != nil {
+			return fmt.Errorf("fill act file urls: %w", err)
+		}
+
+		if err = s.collectExportDevices(ctx, list.Items, devices); err != nil {
+			return fmt.Errorf("collect devices: %w", err)
+		}
+
+		for _, ins := range list.Items {
+			for _, row := range exportRows(ins, devices) {
+				if err = table.WriteRow(row); err != nil {
+					return fmt.Errorf("write inspection %d: %w", ins.ID, err)
+				}
+			}
+		}
+
+		if list.NextCursor == "" {
+			break
+		}
+
+		page.Cursor = list.NextCursor
+		list, err = s.repository.GetPage(ctx, request.Filter, page)
+		if err != nil {
+			return fmt.Errorf("get inspections: %w", err)
+		}
+	}
+
+	if err = table.Close(); err != nil {
+		return fmt.Errorf("close table: %w", err)
+	}
+
+	return nil
+}
+
+// fillActFileURLs resolves the URLs of current acts only, since other attachments are not exported.
+func (s *Service) fillActFileURLs(ctx goctx.Context, inspections []Inspection, headers file.ForwardedHeaders) error {
+	fileIDs := make([]int, 0, len(inspections))
+	for _, ins := range inspections {
+		if act := currentAct(ins); act != nil {
+			fileIDs = append(fileIDs, act.FileID)
+		}
+	}
+
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	files, err := s.fileService.GetByIDs(ctx, fileIDs, pagination.Pagination{}, headers)
+	if err != nil {
+		return fmt.Errorf("get files by ids: %w", upstream("file-service", err))
+	}
+
+	urlsByID := make(map[int]string, len(files))
+	for _, f := range files {
+		urlsByID[f.ID] = f.URL
+	}
+
+	for _, ins := range inspections {
+		act := currentAct(ins)
+		if act == nil {
+			continue
+		}
+
+		fileURL, ok := urlsByID[act.FileID]
+		if !ok {
+			return fmt.Errorf("file %d not found", act.FileID)
+		}
+
+		act.FileURL = fileURL
+	}
+
+	return nil
+}
+
+// collectExportDevices adds the subscriber data of inspected devices missing from devices. Devices are requested
+// by object, so one request covers all devices of the object.
+func (s *Service) collectExportDevices(ctx goctx.Context, inspections []Inspection, devices map[int]subscriber.Device) error {
+	for _, ins := range inspections {
+		for _, d := range ins.InspectedDevices {
+			if _, ok := devices[d.DeviceID]; ok {
+				continue
+			}
+
+			object, err := s.subscriberService.GetObjectByDeviceID(ctx, d.DeviceID)
+			if err != nil {
+				return fmt.Errorf("get object by device %d: %w", d.DeviceID, upstream("subscriber-service", err))
+			}
+
+			for _, device := range object.Devices {
+				devices[device.ID] = device
+			}
+
+			if _, ok := devices[d.DeviceID]; !ok {
+				return fmt.Errorf("device %d not found in object %d", d.DeviceID, object.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+func newTableWriter(format ExportFormat, w io.Writer) (tableWriter, error) {
+	if format == ExportFormatXLSX {
+		return newXLSXWriter(w, exportColumns)
+	}
+
+	return newCSVWriter(w)
+}
+
+// exportRows returns a row for each inspected device of the inspection, or a single row without device
+// columns when nothing was inspected yet. Devices and seals are named by their numbers from devices.
+func exportRows(ins Inspection, devices map[int]subscriber.Device) [][]string {
+	common := []string{
+		strconv.Itoa(ins.ID),
+		strconv.Itoa(ins.TaskID),
+		ins.Status.label(),
+		optionalLabel(ins.Type),
+		optionalLabel(ins.Resolution),
+		exportTime(ins.InspectAt),
+		exportTime(ins.EnergyActionAt),
+		optionalLabel(ins.MethodBy),
+		optionalString(ins.Method),
+		exportBool(ins.IsViolationDetected),
+		exportBool(ins.IsUnauthorizedConsumers),
+	}
+	act := actURL(ins)
+
+	if len(ins.InspectedDevices) == 0 {
+		return [][]string{append(common, "", "", "", "", act)}
+	}
+
+	rows := make([][]string, 0, len(ins.InspectedDevices))
+	for _, d := range ins.InspectedDevices {
+		device := devices[d.DeviceID]
+		row := append([]string{}, common...)
+		row = append(row,
+			device.Number,
+			d.Value.String(),
+			optionalDecimal(d.Consumption),
+			exportSeals(device, d.InspectedSeals),
+			act,
+		)
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// currentAct returns the current act of the inspection. Earlier acts are superseded by resubmissions
+// and regenerations.
+func currentAct(ins Inspection) *Attachment {
+	var act *Attachment
+	for i, attachment := range ins.Attachments {
+		if attachment.Type == AttachmentTypeAct && attachment.SupersededAt == nil {
+			act = &ins.Attachments[i]
+		}
+	}
+
+	return act
+}
+
+func actURL(ins Inspection) string {
+	if act := currentAct(ins); act != nil {
+		return act.FileURL
+	}
+
+	return ""
+}
+
+func exportSeals(device subscriber.Device, seals []InspectedSeal) string {
+	deviceSeals := make(map[int]subscriber.Seal, len(device.Seals))
+	for _, seal := range device.Seals {
+		deviceSeals[seal.ID] = seal
+	}
+
+	result := make([]string, 0, len(seals))
+	for _, s := range seals {
+		result = append(result, sealLabel(deviceSeals[s.SealID], s.IsBroken))
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.In(gotime.Moscow).Format("02.01.2006 15:04")
+}
+
+func exportBool(b *bool) string {
+	switch {
+	case b == nil:
+		return ""
+	case *b:
+		return "Да"
+	default:
+		return "Нет"
+	}
+}
+
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+type labeled interface {
+	label() string
+}
+
+func optionalLabel[T labeled](v *T) string {
+	if v == nil {
+		return ""
+	}
+
+	return (*v).label()
+}
+
+// utf8BOM lets spreadsheet applications detect the encoding of Russian labels.
+const utf8BOM = "\ufeff"
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(cells []string) error {
+	return c.w.Write(cells)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,212 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	clusterfile "inspection-service/cluster/file"
+	clustersubscriber "inspection-service/cluster/subscriber"
+
+	"github.com/shopspring/decimal"
+	"github.com/sunshineOfficial/golib/goctx"
+)
+
+func newExportTestInspection() Inspection {
+	violation := true
+	consumption := decimal.RequireFromString("120")
+	inspectType := TypeLimitation
+	resolution := ResolutionLimited
+	inspectAt := time.Date(2026, time.September, 1, 7, 30, 0, 0, time.UTC)
+
+	return Inspection{
+		ID:                  42,
+		TaskID:              7,
+		Status:              StatusApproved,
+		Type:                &inspectType,
+		Resolution:          &resolution,
+		InspectAt:           &inspectAt,
+		IsViolationDetected: &violation,
+		InspectedDevices: []InspectedDevice{
+			{
+				DeviceID:    11,
+				Value:       decimal.RequireFromString("1250.5"),
+				Consumption: &consumption,
+				InspectedSeals: []InspectedSeal{
+					{SealID: 21, IsBroken: true},
+					{SealID: 22},
+				},
+			},
+			{DeviceID: 12, Value: decimal.RequireFromString("10")},
+		},
+		Attachments: []Attachment{
+			{ID: 1, FileID: 70, Type: AttachmentTypeAct},
+			{ID: 2, FileID: 71, Type: AttachmentTypeDevicePhoto},
+		},
+	}
+}
+
+func newExportTestSubscriberService() subscriberServiceMock {
+	return subscriberServiceMock{contract: clustersubscriber.Contract{Object: clustersubscriber.Object{
+		ID: 5,
+		Devices: []clustersubscriber.Device{
+			{
+				ID:     11,
+				Number: "0112233",
+				Seals: []clustersubscriber.Seal{
+					{ID: 21, Number: "A-100", Place: "клеммная крышка"},
+					{ID: 22, Number: "A-101"},
+				},
+			},
+			{ID: 12, Number: "0445566"},
+		},
+	}}}
+}
+
+func TestExportWritesCSVRowPerDevice(t *testing.T) {
+	fileService := &fileServiceMock{
+		filesByID: map[int]clusterfile.File{70: {ID: 70, URL: "https://example.test/act.docx"}},
+	}
+	service := &Service{
+		repository:        &repositoryMock{inspections: []Inspection{newExportTestInspection()}},
+		fileService:       fileService,
+		subscriberService: newExportTestSubscriberService(),
+	}
+
+	var buf bytes.Buffer
+	err := service.Export(goctx.Wrap(context.Background()), ExportRequest{Format: ExportFormatCSV}, &buf, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), utf8BOM) {
+		t.Fatal("csv does not start with BOM")
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("len(rows) = %d, want header and 2 devices", len(rows))
+	}
+	if rows[0][0] != "№ проверки" {
+		t.Fatalf("header = %v", rows[0])
+	}
+
+	want := []string{"42", "7", "Принята", "Ограничение", "Введено ограничение", "01.09.2026 10:30", "", "", "", "Да", "",
+		"0112233", "1250.5", "120", "№A-100 (клеммная крышка) - сорвана, №A-101 - на месте", "https://example.test/act.docx"}
+	if strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Fatalf("rows[1] = %q, want %q", rows[1], want)
+	}
+	if rows[2][11] != "0445566" {
+		t.Fatalf("rows[2] device = %q, want 0445566", rows[2][11])
+	}
+	if rows[2][13] != "" {
+		t.Fatalf("rows[2] consumption = %q, want empty for a device without a previous reading", rows[2][13])
+	}
+	if len(fileService.gotIDs) != 1 || fileService.gotIDs[0] != 70 {
+		t.Fatalf("requested files = %v, want only the act", fileService.gotIDs)
+	}
+}
+
+func TestExportReadsInspectionsInBatches(t *testing.T) {
+	inspections := make([]Inspection, exportBatchSize+1)
+	for i := range inspections {
+		inspections[i] = Inspection{ID: i + 1, Status: StatusInWork}
+	}
+
+	repository := &repositoryMock{inspections: inspections}
+	service := &Service{repository: repository, fileService: &fileServiceMock{}}
+
+	var buf bytes.Buffer
+	err := service.Export(goctx.Wrap(context.Background()), ExportRequest{Format: ExportFormatCSV}, &buf, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	if repository.pageCalls != 2 {
+		t.Fatalf("pageCalls = %d, want 2", repository.pageCalls)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(inspections)+1 {
+		t.Fatalf("lines = %d, want %d", lines, len(inspections)+1)
+	}
+}
+
+func TestExportWritesXLSXWorkbook(t *testing.T) {
+	fileService := &fileServiceMock{
+		filesByID: map[int]clusterfile.File{70: {ID: 70, URL: "https://example.test/act.docx"}},
+	}
+	service := &Service{
+		repository:        &repositoryMock{inspections: []Inspection{newExportTestInspection()}},
+		fileService:       fileService,
+		subscriberService: newExportTestSubscriberService(),
+	}
+
+	var buf bytes.Buffer
+	err := service.Export(goctx.Wrap(context.Background()), ExportRequest{Format: ExportFormatXLSX}, &buf, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+
+	var sheet []byte
+	for _, f := range archive.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+
+		r, oErr := f.Open()
+		if oErr != nil {
+			t.Fatalf("open sheet: %v", oErr)
+		}
+		sheet, _ = io.ReadAll(r)
+		r.Close()
+	}
+
+	for _, part := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">№ проверки</t></is></c>`,
+		`<c r="A2"><v>42</v></c>`,
+		`<c r="M2"><v>1250.5</v></c>`,
+		`<c r="L2" t="inlineStr"><is><t xml:space="preserve">0112233</t></is></c>`,
+		`<c r="O2" t="inlineStr"><is><t xml:space="preserve">№A-100 (клеммная крышка) - сорвана, №A-101 - на месте</t></is></c>`,
+		`<row r="3">`,
+	} {
+		if !bytes.Contains(sheet, []byte(part)) {
+			t.Fatalf("sheet does not contain %s:\n%s", part, sheet)
+		}
+	}
+}
+
+func TestExportRejectsUnknownFormatWithoutWriting(t *testing.T) {
+	repository := &repositoryMock{}
+	service := &Service{repository: repository}
+
+	var buf bytes.Buffer
+	err := service.Export(goctx.Wrap(context.Background()), ExportRequest{Format: "pdf"}, &buf, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("Export error = %v, want ErrValidation", err)
+	}
+	if buf.Len() != 0 || repository.pageCalls != 0 {
+		t.Fatalf("written %d bytes, pageCalls = %d, want nothing", buf.Len(), repository.pageCalls)
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Fatalf("xlsxColumn(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package inspection
+
+import "time"
+
+// Filter narrows inspection lists. Empty fields are not applied. Time ranges include From and exclude To.
+type Filter struct {
+	Statuses                []Status
+	Types                   []Type
+	Resolutions             []Resolution
+	TaskIDs                 []int
+	DeviceID                *int
+	InspectAtFrom           *time.Time
+	InspectAtTo             *time.Time
+	EnergyActionAtFrom      *time.Time
+	EnergyActionAtTo        *time.Time
+	CreatedAtFrom           *time.Time
+	CreatedAtTo             *time.Time
+	IsRestrictionChecked    *bool
+	IsViolationDetected     *bool
+	IsExpenseAvailable      *bool
+	IsUnauthorizedConsumers *bool
+}
+
+func (f Filter) Validate() error {
+	var errs fieldErrors
+
+	for _, status := range f.Statuses {
+		if status < StatusInWork || status > StatusCancelled {
+			errs.add("status", "unknown status %d", status)
+		}
+	}
+	for _, t := range f.Types {
+		if t < TypeLimitation || t > TypeUnauthorizedConnection {
+			errs.add("type", "unknown inspection type %d", t)
+		}
+	}
+	for _, resolution := range f.Resolutions {
+		if resolution < ResolutionLimited || resolution > ResolutionResumed {
+			errs.add("resolution", "unknown resolution %d", resolution)
+		}
+	}
+
+	validateRange(&errs, "inspectAt", f.InspectAtFrom, f.InspectAtTo)
+	validateRange(&errs, "energyActionAt", f.EnergyActionAtFrom, f.EnergyActionAtTo)
+	validateRange(&errs, "createdAt", f.CreatedAtFrom, f.CreatedAtTo)
+
+	return errs.err()
+}
+
+func validateRange(errs *fieldErrors, field string, from, to *time.Time) {
+	if from != nil && to != nil && !from.Before(*to) {
+		errs.add(field+"To", "must be after %sFrom", field)
+	}
+}
@@ -14,14 +14,46 @@ import (
 )
 
 type Repository interface {
-	GetAll(ctx context.Context, page pagination.Pagination, sort SortDirection) ([]Inspection, error)
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+	GetAll(ctx context.Context, filter Filter, page Page) (InspectionList, error)
+	GetPage(ctx context.Context, filter Filter, page Page) (InspectionList, error)
 	GetByTaskID(ctx context.Context, taskID int) (Inspection, error)
-	AddAttachment(ctx context.Context, inspectionID, fileID int, attachmentType AttachmentType) (Attachment, error)
+	GetByTaskIDs(ctx context.Context, taskIDs []int, page Page) (InspectionList, error)
+	GetStats(ctx context.Context, request StatsRequest) ([]StatsGroup, error)
+	AddAttachment(ctx context.Context, attachment Attachment) (Attachment, error)
+	SupersedeActs(ctx context.Context, inspectionID int) error
+	AddActUpload(ctx context.Context, upload ActUpload) error
+	GetActUploads(ctx context.Context, inspectionID int) ([]ActUpload, error)
+	SettleActUploads(ctx context.Context, inspectionID int) error
+	AssignActNumber(ctx context.Context, inspectionID int, series ActNumber) (ActNumber, error)
+	GetActsBySHA256(ctx context.Context, sha256 string) ([]Attachment, error)
+	GetAttachmentByFileID(ctx context.Context, fileID int) (Attachment, error)
 	GetByID(ctx context.Context, id int) (Inspection, error)
 	GetPreviousDeviceInspections(ctx context.Context, inspectionID, deviceID int) ([]InspectedDevice, error)
 	AddInspectedDevices(ctx context.Context, inspectionID int, requests []InspectedDeviceRequest) error
-	StartInspection(ctx context.Context, taskID int) (Inspection, error)
+	DeleteInspectedDevices(ctx context.Context, inspectionID int) error
+	ReplaceSignatures(ctx context.Context, inspectionID int, signatures []Signature) error
+	GetSignatures(ctx context.Context, inspectionID int) ([]Signature, error)
+	StartInspection(ctx context.Context, taskID int, brigadeID *int) (Inspection, error)
+	GetWithoutBrigade(ctx context.Context, afterID, limit int) ([]Inspection, error)
+	SetBrigade(ctx context.Context, inspectionID, brigadeID int) error
 	FinishInspection(ctx context.Context, request FinishInspectionRequest) (Inspection, error)
+	AddEvent(ctx context.Context, event Event) error
+	GetPendingEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkEventSent(ctx context.Context, id int) error
+	MarkEventFailed(ctx context.Context, id int, reason string) error
+	GetStatusForUpdate(ctx context.Context, id int) (Status, error)
+	GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error)
+	ReserveIdempotencyKey(ctx context.Context, key string, inspectionID int) (bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	UpdateStatus(ctx context.Context, id int, status Status) error
+	AddStatusChange(ctx context.Context, change StatusChange) error
+	GetStatusHistory(ctx context.Context, inspectionID int) ([]StatusChange, error)
+	GetLatestActTemplate(ctx context.Context, inspectionType Type) (ActTemplate, error)
+	GetActTemplateContent(ctx context.Context, id int) ([]byte, error)
+	GetActTemplates(ctx context.Context) ([]ActTemplate, error)
+	AddActTemplate(ctx context.Context, template ActTemplate) (ActTemplate, error)
 }
 
 type AnalyzerService interface {
@@ -37,6 +69,7 @@ type SubscriberService interface {
 type FileService interface {
 	Upload(ctx goctx.Context, fileName string, file io.Reader, headers file.ForwardedHeaders) (file.File, error)
 	GetByIDs(ctx goctx.Context, ids []int, page pagination.Pagination, headers file.ForwardedHeaders) ([]file.File, error)
+	Download(ctx goctx.Context, fileURL string) (io.ReadCloser, error)
 }
 
 type TaskService interface {
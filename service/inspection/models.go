@@ -1,6 +1,7 @@
 package inspection
 
 import (
+	"encoding/json"
 	"fmt"
 	"inspection-service/cluster/file"
 	"mime/multipart"
@@ -15,6 +16,10 @@ const (
 	StatusUnknown Status = iota
 	StatusInWork
 	StatusDone
+	StatusSubmitted
+	StatusApproved
+	StatusRejected
+	StatusCancelled
 )
 
 type Type int
@@ -54,6 +59,9 @@ const (
 	ReasonTypeResumed
 )
 
+// Inspection is an inspection of an object with its results. UnassignedSeals lists seals of inspections finished
+// before seals were bound to devices whose device is not known, because the inspection had several devices.
+// Their device is found through the object when the act is regenerated.
 type Inspection struct {
 	ID                      int               `json:"ID"`
 	TaskID                  int               `json:"TaskID"`
@@ -74,12 +82,24 @@ type Inspection struct {
 	UnauthorizedExplanation *string           `json:"UnauthorizedExplanation,omitempty"`
 	InspectAt               *time.Time        `json:"InspectAt,omitempty"`
 	EnergyActionAt          *time.Time        `json:"EnergyActionAt,omitempty"`
+	ActNumber               *ActNumber        `json:"ActNumber,omitempty"`
 	InspectedDevices        []InspectedDevice `json:"InspectedDevices,omitempty"`
+	UnassignedSeals         []InspectedSeal   `json:"UnassignedSeals,omitempty"`
 	Attachments             []Attachment      `json:"Attachments"`
 	CreatedAt               time.Time         `json:"CreatedAt"`
 	UpdatedAt               time.Time         `json:"UpdatedAt"`
 }
 
+type StatusChange struct {
+	ID           int       `json:"ID"`
+	InspectionID int       `json:"InspectionID"`
+	From         *Status   `json:"From,omitempty"`
+	To           Status    `json:"To"`
+	UserID       int       `json:"UserID,omitempty"`
+	Comment      *string   `json:"Comment,omitempty"`
+	CreatedAt    time.Time `json:"CreatedAt"`
+}
+
 type SortDirection string
 
 const (
@@ -103,37 +123,74 @@ const (
 	AttachmentTypeDevicePhoto
 	AttachmentTypeSealPhoto
 	AttachmentTypeAct
+	AttachmentTypeActPDF
 )
 
+// IsAct reports whether the attachment stores an act file.
+func (t AttachmentType) IsAct() bool {
+	return t == AttachmentTypeAct || t == AttachmentTypeActPDF
+}
+
+// Attachment is a file of an inspection. SupersededAt is set on act files replaced by a regenerated act,
+// Reason on the regenerated ones. Signature is the Ed25519 signature of an act file by the service key,
+// SHA256 is the hex digest of an act file.
 type Attachment struct {
-	ID           int            `json:"ID"`
-	InspectionID int            `json:"InspectionID"`
-	Type         AttachmentType `json:"Type"`
-	FileID       int            `json:"FileID"`
-	FileURL      string         `json:"FileURL"`
-	CreatedAt    time.Time      `json:"CreatedAt"`
+	ID            int            `json:"ID"`
+	InspectionID  int            `json:"InspectionID"`
+	Type          AttachmentType `json:"Type"`
+	FileID        int            `json:"FileID"`
+	FileURL       string         `json:"FileURL"`
+	ActTemplateID *int           `json:"ActTemplateID,omitempty"`
+	SupersededAt  *time.Time     `json:"SupersededAt,omitempty"`
+	Reason        *string        `json:"Reason,omitempty"`
+	Signature     []byte         `json:"Signature,omitempty"`
+	SHA256        string         `json:"SHA256,omitempty"`
+	CreatedAt     time.Time      `json:"CreatedAt"`
 }
 
+// InspectedDevice is a reading of an inspected device. Consumption is computed from the previous reading of the device
+// and is null when the device had none.
 type InspectedDevice struct {
-	ID           int             `json:"ID"`
-	DeviceID     int             `json:"DeviceID"`
-	InspectionID int             `json:"InspectionID"`
-	Value        decimal.Decimal `json:"Value"`
-	Consumption  decimal.Decimal `json:"Consumption"`
-	CreatedAt    time.Time       `json:"CreatedAt"`
+	ID                  int              `json:"ID"`
+	DeviceID            int              `json:"DeviceID"`
+	InspectionID        int              `json:"InspectionID"`
+	Value               decimal.Decimal  `json:"Value"`
+	Consumption         *decimal.Decimal `json:"Consumption"`
+	DeclaredConsumption *decimal.Decimal `json:"DeclaredConsumption,omitempty"`
+	HasDiscrepancy      bool             `json:"HasDiscrepancy"`
+	IsReplaced          bool             `json:"IsReplaced"`
+	IsRollover          bool             `json:"IsRollover"`
+	InspectedSeals      []InspectedSeal  `json:"InspectedSeals"`
+	CreatedAt           time.Time        `json:"CreatedAt"`
 }
 
-type AttachPhotoRequest struct {
+type InspectedSeal struct {
+	ID           int       `json:"ID"`
+	SealID       int       `json:"SealID"`
+	DeviceID     int       `json:"DeviceID"`
+	InspectionID int       `json:"InspectionID"`
+	IsBroken     bool      `json:"IsBroken"`
+	CreatedAt    time.Time `json:"CreatedAt"`
+}
+
+// AttachPhotosRequest is a batch of photos of an inspection. Photos are attached independently of each other.
+type AttachPhotosRequest struct {
 	InspectionID int
-	Type         AttachmentType
-	DeviceID     int
-	SealID       int
-	FileHeader   *multipart.FileHeader
+	Photos       []Photo
 	FileHeaders  file.ForwardedHeaders
 }
 
+// Photo is a photo of a device or a seal. DeviceID is set for device photos, SealID for seal photos.
+type Photo struct {
+	Type       AttachmentType
+	DeviceID   int
+	SealID     int
+	FileHeader *multipart.FileHeader
+}
+
 type FinishInspectionRequest struct {
 	ID                      int                      `json:"ID"`
+	IdempotencyKey          string                   `json:"-"`
 	Type                    Type                     `json:"Type"`
 	Resolution              Resolution               `json:"Resolution"`
 	LimitReason             *string                  `json:"LimitReason"`
@@ -150,13 +207,55 @@ type FinishInspectionRequest struct {
 	UnauthorizedExplanation *string                  `json:"UnauthorizedExplanation"`
 	EnergyActionAt          time.Time                `json:"EnergyActionAt"`
 	InspectedDevices        []InspectedDeviceRequest `json:"InspectedDevices"`
+	ActFormats              []ActFormat              `json:"ActFormats"`
+	Signatures              []Signature              `json:"Signatures"`
+}
+
+// IdempotencyKey stores the response of a finished inspection, so that retries with the same key get it back.
+// The key is reserved before the finish starts; a reserved key has no response yet.
+type IdempotencyKey struct {
+	Key          string
+	InspectionID int
+	FileID       int
+	Response     json.RawMessage
+	CreatedAt    time.Time
+}
+
+// ActUpload is an act file uploaded for an inspection and not attached to it yet. A retry of the finish or regeneration
+// that uploaded it attaches the file instead of uploading an act with the same content again. Reason is set when
+// the act is regenerated.
+type ActUpload struct {
+	FileID       int
+	InspectionID int
+	Format       ActFormat
+	SHA256       string
+	DrawnAt      time.Time
+	Reason       *string
+	CreatedAt    time.Time
+}
+
+// RegenerateActRequest regenerates the act of a finished inspection from its stored data.
+type RegenerateActRequest struct {
+	ID         int         `json:"-"`
+	Reason     string      `json:"Reason"`
+	ActFormats []ActFormat `json:"ActFormats"`
+}
+
+type ReviewRequest struct {
+	ID      int     `json:"-"`
+	Comment *string `json:"Comment"`
 }
 
 type InspectedDeviceRequest struct {
-	DeviceID       int                    `json:"DeviceID"`
-	Value          decimal.Decimal        `json:"Value"`
-	Consumption    decimal.Decimal        `json:"Consumption"`
-	InspectedSeals []InspectedSealRequest `json:"InspectedSeals"`
+	DeviceID int             `json:"DeviceID"`
+	Value    decimal.Decimal `json:"Value"`
+	// Consumption is declared by the inspector. It is stored for comparison only, the act and the inspection
+	// use ComputedConsumption, which the service calculates from the previous reading and leaves nil without one.
+	Consumption         decimal.Decimal        `json:"Consumption"`
+	ComputedConsumption *decimal.Decimal       `json:"-"`
+	IsReplaced          bool                   `json:"IsReplaced"`
+	IsRollover          bool                   `json:"IsRollover"`
+	InspectedSeals      []InspectedSealRequest `json:"InspectedSeals"`
 }
 
 type InspectedSealRequest struct {
@@ -9,12 +9,13 @@ import (
 )
 
 func TestInspectedDeviceJSONUsesPascalCaseFields(t *testing.T) {
+	consumption := decimal.RequireFromString("122.96")
 	device := InspectedDevice{
 		ID:           900002,
 		DeviceID:     920081,
 		InspectionID: 900002,
 		Value:        decimal.RequireFromString("4605.96"),
-		Consumption:  decimal.RequireFromString("122.96"),
+		Consumption:  &consumption,
 		CreatedAt:    time.Date(2025, time.January, 1, 7, 27, 0, 0, time.UTC),
 	}
 
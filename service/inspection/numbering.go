@@ -0,0 +1,117 @@
+package inspection
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+// noActNumber is written in place of the number of an act that has none yet.
+const noActNumber = "б/н"
+
+// ActNumber is the legal number of the act of an inspection. Numbers are sequential within a series: the kind
+// of the act, the year it is drawn up in and the branch of the service.
+type ActNumber struct {
+	Kind   int    `json:"-"`
+	Year   int    `json:"Year"`
+	Branch string `json:"Branch,omitempty"`
+	Number int    `json:"Number"`
+}
+
+// String returns the number as it is written in the act: prefixed with the branch code when there is one.
+func (n ActNumber) String() string {
+	if len(n.Branch) == 0 {
+		return strconv.Itoa(n.Number)
+	}
+
+	return n.Branch + "-" + strconv.Itoa(n.Number)
+}
+
+func (n ActNumber) sameSeries(other ActNumber) bool {
+	return n.Kind == other.Kind && n.Year == other.Year && n.Branch == other.Branch
+}
+
+// actNumberSeries returns the series of acts of the inspection type drawn up at the time, without a number.
+func (s *Service) actNumberSeries(t Type, at time.Time) (ActNumber, error) {
+	kind, err := actKindOf(t)
+	if err != nil {
+		return ActNumber{}, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	return ActNumber{Kind: int(kind), Year: at.In(gotime.Moscow).Year(), Branch: s.numbering.Branch}, nil
+}
+
+// reserveActNumber numbers the act of the inspection before it is rendered, in a transaction of its own, so the counter
+// of the series is locked only while the number is taken. The number stays with the inspection when the finish fails
+// and its retry takes it again. It is lost to the series when the inspection is never submitted with it, for example
+// when it is cancelled or submitted with a type of another series, which leaves a gap.
+func (s *Service) reserveActNumber(ctx goctx.Context, ins Inspection, t Type, at time.Time) (string, error) {
+	var number string
+	err := s.repository.WithTx(ctx, func(tx Repository) error {
+		status, err := tx.GetStatusForUpdate(ctx, ins.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", err)
+		}
+
+		// An inspection submitted meanwhile is not numbered again.
+		if !status.CanTransitionTo(StatusSubmitted) {
+			return TransitionError{From: status, To: StatusSubmitted}
+		}
+
+		number, err = s.assignActNumber(ctx, tx, ins, t, at)
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return number, nil
+}
+
+// assignActNumber takes the next number of the series unless the inspection already has a number from it.
+// The repository assigns numbers only in a transaction.
+func (s *Service) assignActNumber(ctx goctx.Context, tx Repository, ins Inspection, t Type, at time.Time) (string, error) {
+	series, err := s.actNumberSeries(t, at)
+	if err != nil {
+		return "", err
+	}
+
+	if ins.ActNumber != nil && ins.ActNumber.sameSeries(series) {
+		return ins.ActNumber.String(), nil
+	}
+
+	number, err := tx.AssignActNumber(ctx, ins.ID, series)
+	if err != nil {
+		return "", fmt.Errorf("assign act number: %w", err)
+	}
+
+	return number.String(), nil
+}
+
+// previewActNumber returns the number the inspection already has in the series without assigning one.
+func (s *Service) previewActNumber(ins Inspection, t Type, at time.Time) (string, error) {
+	series, err := s.actNumberSeries(t, at)
+	if err != nil {
+		return "", err
+	}
+
+	if ins.ActNumber != nil && ins.ActNumber.sameSeries(series) {
+		return ins.ActNumber.String(), nil
+	}
+
+	return noActNumber, nil
+}
+
+// issuedActNumber returns the number of the act of a submitted inspection. Acts issued before numbering was
+// introduced were numbered with the ID of the inspection.
+func issuedActNumber(ins Inspection) string {
+	if ins.ActNumber == nil {
+		return strconv.Itoa(ins.ID)
+	}
+
+	return ins.ActNumber.String()
+}
@@ -0,0 +1,190 @@
+package inspection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	clusterfile "inspection-service/cluster/file"
+	"inspection-service/config"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+func TestAssignActNumberNumbersEachSeriesFromOne(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{1: {ID: 1}, 2: {ID: 2}, 3: {ID: 3}, 4: {ID: 4}, 5: {ID: 5}},
+	}
+	service := &Service{repository: repository}
+	ctx := goctx.Wrap(context.Background())
+
+	at := time.Date(2026, time.December, 31, 12, 0, 0, 0, gotime.Moscow)
+	nextYear := time.Date(2026, time.December, 31, 21, 30, 0, 0, time.UTC) // 1 January in Moscow
+
+	for _, tc := range []struct {
+		id   int
+		t    Type
+		at   time.Time
+		want string
+	}{
+		{id: 1, t: TypeLimitation, at: at, want: "1"},
+		{id: 2, t: TypeResumption, at: at, want: "2"},
+		{id: 3, t: TypeVerification, at: at, want: "1"},
+		{id: 4, t: TypeLimitation, at: nextYear, want: "1"},
+		{id: 1, t: TypeLimitation, at: at, want: "1"},
+	} {
+		got, err := service.assignActNumber(ctx, repository, repository.inspectionsByID[tc.id], tc.t, tc.at)
+		if err != nil {
+			t.Fatalf("assignActNumber(%d) returned error: %v", tc.id, err)
+		}
+		if got != tc.want {
+			t.Fatalf("assignActNumber(%d, type %d, %s) = %q, want %q", tc.id, tc.t, tc.at, got, tc.want)
+		}
+	}
+
+	service.numbering = config.ActNumbering{Branch: "ЮЗ"}
+	got, err := service.assignActNumber(ctx, repository, repository.inspectionsByID[5], TypeLimitation, at)
+	if err != nil {
+		t.Fatalf("assignActNumber returned error: %v", err)
+	}
+	if got != "ЮЗ-1" {
+		t.Fatalf("assignActNumber with branch = %q, want ЮЗ-1", got)
+	}
+}
+
+func TestFinishInspectionRetryReusesActNumber(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+	ctx := goctx.Wrap(context.Background())
+
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err == nil {
+		t.Fatal("FinishInspection returned no error")
+	}
+
+	// The mock does not roll back the status change of the failed attempt.
+	ins := repository.inspectionsByID[42]
+	ins.Status = StatusInWork
+	repository.inspectionsByID[42] = ins
+	repository.finishErr = nil
+
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if len(repository.actNumberCounters) != 1 {
+		t.Fatalf("actNumberCounters = %v, want a single series", repository.actNumberCounters)
+	}
+	for _, counter := range repository.actNumberCounters {
+		if counter != 1 {
+			t.Fatalf("retry took number %d, want number 1 kept from the failed attempt", counter)
+		}
+	}
+
+	for _, name := range fileService.uploadedNames {
+		if !strings.Contains(name, "№1 от") {
+			t.Fatalf("act file name = %q, want number 1", name)
+		}
+	}
+}
+
+func TestFinishInspectionWithAnotherTypeLeavesGapInSeries(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+	ctx := goctx.Wrap(context.Background())
+
+	request := newFinishTestRequest()
+	request.Type = TypeVerification
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); !errors.Is(err, repository.finishErr) {
+		t.Fatalf("FinishInspection error = %v, want %v", err, repository.finishErr)
+	}
+	if len(fileService.uploadedNames) != 1 || !strings.Contains(fileService.uploadedNames[0], "контроля №1") {
+		t.Fatalf("uploaded = %v, want the numbered control act of the failed attempt", fileService.uploadedNames)
+	}
+
+	ins := repository.inspectionsByID[42]
+	if ins.ActNumber == nil || ins.ActNumber.Kind != int(actKindControl) || ins.ActNumber.Number != 1 {
+		t.Fatalf("act number = %v, want number 1 of the control series reserved by the failed attempt", ins.ActNumber)
+	}
+
+	ins.Status = StatusInWork
+	repository.inspectionsByID[42] = ins
+	repository.finishErr = nil
+
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if repository.numberedOutsideTx {
+		t.Fatal("act number was assigned outside a transaction")
+	}
+
+	for series, counter := range repository.actNumberCounters {
+		if series.Kind == int(actKindControl) && counter != 1 {
+			t.Fatalf("control series counter = %d, want 1 left as a gap", counter)
+		}
+	}
+	if got := repository.inspectionsByID[42].ActNumber; got == nil || got.Kind != int(actKindUniversal) || got.Number != 1 {
+		t.Fatalf("act number = %v, want number 1 of the universal series", got)
+	}
+}
+
+// txCheckingFileService records whether acts are uploaded inside a transaction of the repository.
+type txCheckingFileService struct {
+	*fileServiceMock
+	repository   *repositoryMock
+	uploadedInTx bool
+}
+
+func (m *txCheckingFileService) Upload(ctx goctx.Context, fileName string, file io.Reader, headers clusterfile.ForwardedHeaders) (clusterfile.File, error) {
+	m.uploadedInTx = m.uploadedInTx || m.repository.inTx
+	return m.fileServiceMock.Upload(ctx, fileName, file, headers)
+}
+
+func TestFinishInspectionUploadsActOutsideTransaction(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &txCheckingFileService{fileServiceMock: &fileServiceMock{}, repository: repository}
+	service := newFinishTestService(t, repository, fileService.fileServiceMock)
+	service.fileService = fileService
+
+	request := newFinishTestRequest()
+	request.ActFormats = []ActFormat{ActFormatDOCX, ActFormatPDF}
+	if _, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if fileService.uploadedInTx {
+		t.Fatal("act was uploaded inside a transaction")
+	}
+	if got := repository.inspectionsByID[42].ActNumber; got == nil || got.Number != 1 {
+		t.Fatalf("act number = %v, want 1", got)
+	}
+	if len(fileService.uploadedNames) != 2 {
+		t.Fatalf("uploaded = %v, want docx and pdf", fileService.uploadedNames)
+	}
+}
+
+func TestIssuedActNumberFallsBackToInspectionID(t *testing.T) {
+	if got := issuedActNumber(Inspection{ID: 42}); got != "42" {
+		t.Fatalf("issuedActNumber = %q, want the inspection ID", got)
+	}
+
+	ins := Inspection{ID: 42, ActNumber: &ActNumber{Year: 2026, Number: 3}}
+	if got := issuedActNumber(ins); got != "3" {
+		t.Fatalf("issuedActNumber = %q, want 3", got)
+	}
+}
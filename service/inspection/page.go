@@ -0,0 +1,151 @@
+package inspection
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SortKey string
+
+const (
+	SortByID        SortKey = "id"
+	SortByCreatedAt SortKey = "created_at"
+	SortByUpdatedAt SortKey = "updated_at"
+	SortByInspectAt SortKey = "inspect_at"
+)
+
+func (k SortKey) Validate() error {
+	switch k {
+	case "", SortByID, SortByCreatedAt, SortByUpdatedAt, SortByInspectAt:
+		return nil
+	default:
+		return fmt.Errorf("sort key must be empty, %q, %q, %q, or %q", SortByID, SortByCreatedAt, SortByUpdatedAt, SortByInspectAt)
+	}
+}
+
+// value returns the sort value of the inspection. ID is compared separately, so it has no value.
+func (k SortKey) value(ins Inspection) *time.Time {
+	switch k {
+	case SortByCreatedAt:
+		return &ins.CreatedAt
+	case SortByUpdatedAt:
+		return &ins.UpdatedAt
+	case SortByInspectAt:
+		return ins.InspectAt
+	default:
+		return nil
+	}
+}
+
+// Page selects a part of an inspection list. Cursor is NextCursor of the previous page and is empty for the first one.
+// Offset skips inspections instead, for clients that page by offset; it cannot be combined with Cursor.
+// Inspections with equal sort values are ordered by ID in the same direction.
+type Page struct {
+	Cursor    string
+	Offset    int
+	Limit     int
+	SortBy    SortKey
+	Direction SortDirection
+}
+
+// Normalize fills the defaults. Without a sort key the list is sorted by InspectAt when a direction is given,
+// as it was before sort keys were introduced, and by ID otherwise.
+func (p Page) Normalize() Page {
+	if p.SortBy == "" {
+		p.SortBy = SortByID
+		if p.Direction != "" {
+			p.SortBy = SortByInspectAt
+		}
+	}
+
+	if p.Direction == "" {
+		p.Direction = SortAsc
+	}
+
+	return p
+}
+
+func (p Page) Validate() error {
+	var errs fieldErrors
+
+	if p.Limit < 0 {
+		errs.add("limit", "must not be negative")
+	}
+	if p.Offset < 0 {
+		errs.add("offset", "must not be negative")
+	}
+	if p.Offset > 0 && p.Cursor != "" {
+		errs.add("offset", "cannot be combined with cursor")
+	}
+	if err := p.SortBy.Validate(); err != nil {
+		errs.add("sortBy", "%s", err)
+	}
+	if err := p.Direction.Validate(); err != nil {
+		errs.add("sort", "%s", err)
+	}
+
+	if _, err := p.After(); err != nil {
+		errs.add("cursor", "%s", err)
+	}
+
+	return errs.err()
+}
+
+var errMalformedCursor = errors.New("malformed cursor")
+
+// After decodes the cursor of a normalized page. It returns nil for the first page.
+func (p Page) After() (*Cursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, errMalformedCursor
+	}
+
+	if c.SortBy != p.SortBy || c.Direction != p.Direction {
+		return nil, errors.New("cursor was issued for another sort order")
+	}
+	if c.Value == nil && (c.SortBy == SortByCreatedAt || c.SortBy == SortByUpdatedAt) {
+		return nil, errMalformedCursor
+	}
+
+	return &c, nil
+}
+
+// Next returns the cursor of the page that follows last.
+func (p Page) Next(last Inspection) string {
+	c := Cursor{
+		SortBy:    p.SortBy,
+		Direction: p.Direction,
+		Value:     p.SortBy.value(last),
+		ID:        last.ID,
+	}
+
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Cursor points at the last inspection of a page. Clients get it as an opaque token.
+type Cursor struct {
+	SortBy    SortKey       `json:"s"`
+	Direction SortDirection `json:"d"`
+	Value     *time.Time    `json:"v,omitempty"`
+	ID        int           `json:"i"`
+}
+
+type InspectionList struct {
+	Items      []Inspection `json:"Items"`
+	Total      int          `json:"Total"`
+	NextCursor string       `json:"NextCursor,omitempty"`
+}
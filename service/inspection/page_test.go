@@ -0,0 +1,69 @@
+package inspection
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageNormalizeKeepsInspectAtOrderForSortDirection(t *testing.T) {
+	got := Page{Direction: SortDesc}.Normalize()
+	if got.SortBy != SortByInspectAt {
+		t.Fatalf("SortBy = %q, want %q", got.SortBy, SortByInspectAt)
+	}
+
+	got = Page{}.Normalize()
+	if got.SortBy != SortByID || got.Direction != SortAsc {
+		t.Fatalf("page = %+v, want id asc", got)
+	}
+}
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, time.September, 1, 10, 0, 0, 0, time.UTC)
+	page := Page{SortBy: SortByCreatedAt, Direction: SortDesc}
+
+	next := Page{Cursor: page.Next(Inspection{ID: 42, CreatedAt: createdAt}), SortBy: SortByCreatedAt, Direction: SortDesc}
+	after, err := next.After()
+	if err != nil {
+		t.Fatalf("After returned error: %v", err)
+	}
+
+	if after.ID != 42 || after.Value == nil || !after.Value.Equal(createdAt) {
+		t.Fatalf("after = %+v, want id 42 created at %s", after, createdAt)
+	}
+}
+
+func TestPageValidateRejectsCursorOfAnotherSort(t *testing.T) {
+	cursor := Page{SortBy: SortByID, Direction: SortAsc}.Next(Inspection{ID: 42})
+
+	err := Page{Cursor: cursor, SortBy: SortByID, Direction: SortDesc}.Validate()
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "cursor" {
+		t.Fatalf("fields = %+v, want cursor", validationErr.Fields)
+	}
+
+	err = Page{Cursor: "not a cursor", SortBy: SortByID, Direction: SortAsc}.Validate()
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("Validate error = %v, want ErrValidation", err)
+	}
+}
+
+func TestPageValidateRejectsOffsetWithCursor(t *testing.T) {
+	cursor := Page{SortBy: SortByID, Direction: SortAsc}.Next(Inspection{ID: 42})
+
+	if err := (Page{Offset: 20, SortBy: SortByID, Direction: SortAsc}).Validate(); err != nil {
+		t.Fatalf("Validate returned error for offset page: %v", err)
+	}
+
+	err := Page{Cursor: cursor, Offset: 20, SortBy: SortByID, Direction: SortAsc}.Validate()
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "offset" {
+		t.Fatalf("fields = %+v, want offset", validationErr.Fields)
+	}
+}
@@ -0,0 +1,794 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/lukasjarosch/go-docx"
+)
+
+// A4 page in points.
+const (
+	pdfPageWidth    = 595.28
+	pdfPageHeight   = 841.89
+	pdfMargin       = 42.5
+	pdfContentWidth = pdfPageWidth - 2*pdfMargin
+	pdfLeading      = 1.3
+	pdfCellPadding  = 3.0
+)
+
+type pdfAlign int
+
+const (
+	pdfAlignLeft pdfAlign = iota
+	pdfAlignCenter
+)
+
+// pdfBlock is a paragraph of an act, or a table when rows are set.
+type pdfBlock struct {
+	text  string
+	size  float64
+	align pdfAlign
+	bold  bool
+	space float64
+	rows  []pdfRow
+}
+
+// pdfRow is a row of a table. Widths of its cells are shares of the page content width.
+type pdfRow struct {
+	cells  []string
+	widths []float64
+	bold   bool
+}
+
+// pdfDefaultSize is the font size of text that has no size in the template.
+const pdfDefaultSize = 10
+
+// pdfRenderer lays out acts as PDF with the font embedded. The text is taken from the docx template filled
+// the same way as the docx act, so both formats of an act come from one template version. The PDF keeps
+// the paragraphs, tables, alignment, bold text and font sizes of the template, but not its fonts and indents.
+type pdfRenderer struct {
+	font     *trueTypeFont
+	template []byte
+}
+
+func (r pdfRenderer) render(a act) (*bytes.Buffer, error) {
+	if r.font == nil {
+		return nil, errors.New("pdf font is not loaded")
+	}
+
+	filled, err := writeDocXTemplate(r.template, a.placeholders(), a.rows)
+	if err != nil {
+		return nil, fmt.Errorf("writeDocXTemplate: %w", err)
+	}
+
+	blocks, err := docxBlocks(filled.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("read docx act: %w", err)
+	}
+
+	doc := newPDFDocument(r.font)
+	if err = doc.addSignatures(a.signatures); err != nil {
+		return nil, fmt.Errorf("add signatures: %w", err)
+	}
+
+	for _, block := range blocks {
+		doc.space(block.space)
+
+		if len(block.rows) == 0 {
+			doc.paragraph(block.text, block.size, block.align, block.bold)
+			continue
+		}
+
+		doc.table(block.rows, block.size)
+	}
+
+	doc.remainingSignatures()
+
+	var buf bytes.Buffer
+	if err = doc.write(&buf); err != nil {
+		return nil, fmt.Errorf("write pdf: %w", err)
+	}
+
+	return &buf, nil
+}
+
+// docxElement is an element of a docx part with its attributes and children in document order.
+type docxElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr    `xml:",any,attr"`
+	Children []docxElement `xml:",any"`
+	Text     string        `xml:",chardata"`
+}
+
+// attr returns the value of the attribute with the local name, ignoring its namespace.
+func (e docxElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// child returns the first child with the local name.
+func (e docxElement) child(name string) (docxElement, bool) {
+	for _, c := range e.Children {
+		if c.XMLName.Local == name {
+			return c, true
+		}
+	}
+
+	return docxElement{}, false
+}
+
+// docxBlocks reads the paragraphs and tables of the body of a docx document.
+func docxBlocks(document []byte) ([]pdfBlock, error) {
+	archive, err := zip.NewReader(bytes.NewReader(document), int64(len(document)))
+	if err != nil {
+		return nil, fmt.Errorf("open docx: %w", err)
+	}
+
+	part, err := archive.Open(docx.DocumentXml)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", docx.DocumentXml, err)
+	}
+
+	defer part.Close()
+
+	var root docxElement
+	if err = xml.NewDecoder(part).Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", docx.DocumentXml, err)
+	}
+
+	body, ok := root.child("body")
+	if !ok {
+		return nil, errors.New("document has no body")
+	}
+
+	var blocks []pdfBlock
+	for _, e := range body.Children {
+		switch e.XMLName.Local {
+		case "p":
+			blocks = append(blocks, docxParagraph(e))
+		case "tbl":
+			blocks = append(blocks, docxTable(e))
+		}
+	}
+
+	return blocks, nil
+}
+
+func docxParagraph(p docxElement) pdfBlock {
+	block := pdfBlock{text: docxText(p), size: pdfDefaultSize, bold: docxBold(p)}
+
+	properties, _ := p.child("pPr")
+	if jc, ok := properties.child("jc"); ok && jc.attr("val") == "center" {
+		block.align = pdfAlignCenter
+	}
+
+	if spacing, ok := properties.child("spacing"); ok {
+		// Spacing is in twentieths of a point.
+		block.space = float64(docxNumber(spacing.attr("before"))) / 20
+	}
+
+	if size := docxSize(p); size != 0 {
+		block.size = size
+	}
+
+	return block
+}
+
+func docxTable(tbl docxElement) pdfBlock {
+	block := pdfBlock{size: pdfDefaultSize}
+
+	var grid []float64
+	if g, ok := tbl.child("tblGrid"); ok {
+		total := 0
+		for _, col := range g.Children {
+			total += docxNumber(col.attr("w"))
+		}
+
+		for _, col := range g.Children {
+			if total != 0 {
+				grid = append(grid, float64(docxNumber(col.attr("w")))/float64(total))
+			}
+		}
+	}
+
+	for _, tr := range tbl.Children {
+		if tr.XMLName.Local != "tr" {
+			continue
+		}
+
+		row := pdfRow{bold: true}
+		column := 0
+		for _, tc := range tr.Children {
+			if tc.XMLName.Local != "tc" {
+				continue
+			}
+
+			span := 1
+			if properties, ok := tc.child("tcPr"); ok {
+				if s, ok := properties.child("gridSpan"); ok {
+					span = max(docxNumber(s.attr("val")), 1)
+				}
+			}
+
+			width := 0.0
+			for i := column; i < column+span && i < len(grid); i++ {
+				width += grid[i]
+			}
+			column += span
+
+			paragraphs := make([]string, 0, len(tc.Children))
+			for _, p := range tc.Children {
+				if p.XMLName.Local == "p" {
+					paragraphs = append(paragraphs, docxText(p))
+				}
+			}
+
+			row.cells = append(row.cells, strings.Join(paragraphs, "\n"))
+			row.widths = append(row.widths, width)
+			row.bold = row.bold && docxBold(tc)
+
+			if size := docxSize(tc); size != 0 {
+				block.size = size
+			}
+		}
+
+		// Tables without a grid get equal columns.
+		if len(grid) == 0 {
+			for i := range row.widths {
+				row.widths[i] = 1 / float64(len(row.widths))
+			}
+		}
+
+		block.rows = append(block.rows, row)
+	}
+
+	return block
+}
+
+// docxText returns the text of the runs under e. Tabs become spaces, breaks start new lines.
+func docxText(e docxElement) string {
+	var b strings.Builder
+
+	var walk func(e docxElement)
+	walk = func(e docxElement) {
+		switch e.XMLName.Local {
+		case "t":
+			b.WriteString(e.Text)
+		case "tab":
+			b.WriteString(" ")
+		case "br", "cr":
+			b.WriteString("\n")
+		case "pPr", "rPr", "Fallback":
+			// Properties have no text, fallbacks repeat the text of their alternative content.
+		default:
+			for _, c := range e.Children {
+				walk(c)
+			}
+		}
+	}
+
+	walk(e)
+
+	return b.String()
+}
+
+// docxBold reports whether every run with text under e is bold.
+func docxBold(e docxElement) bool {
+	runs := docxRuns(e)
+	if len(runs) == 0 {
+		return false
+	}
+
+	for _, r := range runs {
+		properties, _ := r.child("rPr")
+		b, ok := properties.child("b")
+		if !ok || b.attr("val") == "0" || b.attr("val") == "false" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// docxSize returns the font size in points of the first run with text under e, or zero when it has none.
+func docxSize(e docxElement) float64 {
+	for _, r := range docxRuns(e) {
+		properties, _ := r.child("rPr")
+		if sz, ok := properties.child("sz"); ok {
+			// Sizes are in half-points.
+			return float64(docxNumber(sz.attr("val"))) / 2
+		}
+	}
+
+	return 0
+}
+
+// docxRuns returns the runs with text under e.
+func docxRuns(e docxElement) []docxElement {
+	var runs []docxElement
+	for _, c := range e.Children {
+		if c.XMLName.Local == "r" {
+			if strings.TrimSpace(docxText(c)) != "" {
+				runs = append(runs, c)
+			}
+			continue
+		}
+
+		runs = append(runs, docxRuns(c)...)
+	}
+
+	return runs
+}
+
+func docxNumber(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// pdfDocument lays out text top to bottom, starting new pages as needed. All text is set in a single font,
+// bold text is drawn with an outline. Signature markers in the text are drawn as the images of the signatures.
+type pdfDocument struct {
+	font       *trueTypeFont
+	pages      []*bytes.Buffer
+	page       *bytes.Buffer
+	y          float64
+	used       map[uint16]rune
+	images     []pdfImage
+	signatures []*pdfSignature
+}
+
+// pdfSignature is a signature drawn in place of its marker in the text as the image number image.
+type pdfSignature struct {
+	actSignature
+	image int
+	drawn bool
+}
+
+// pdfImage is an RGB image with an alpha channel, drawn on pages as /Im<n>, where n is its index plus one.
+type pdfImage struct {
+	width, height int
+	rgb, alpha    []byte
+}
+
+// Signatures in PDF, in points.
+const (
+	pdfSignatureHeight   = 42.5 // 1.5 cm
+	pdfSignatureMaxWidth = 170  // 6 cm
+)
+
+func newPDFDocument(font *trueTypeFont) *pdfDocument {
+	d := &pdfDocument{
+		font: font,
+		used: make(map[uint16]rune),
+	}
+
+	d.newPage()
+
+	return d
+}
+
+func (d *pdfDocument) newPage() {
+	d.page = new(bytes.Buffer)
+	d.page.WriteString("0.5 w\n")
+	d.pages = append(d.pages, d.page)
+	d.y = pdfMargin
+}
+
+// fit starts a new page when height does not fit on the current one.
+func (d *pdfDocument) fit(height float64) {
+	if d.y+height > pdfPageHeight-pdfMargin && d.y > pdfMargin {
+		d.newPage()
+	}
+}
+
+func (d *pdfDocument) space(height float64) {
+	if d.y > pdfMargin {
+		d.y += height
+	}
+}
+
+// text draws s with the baseline at y points from the top of the page.
+func (d *pdfDocument) text(x, y float64, s string, size float64, bold bool) {
+	if bold {
+		d.page.WriteString("q 2 Tr 0.3 w ")
+	}
+
+	fmt.Fprintf(d.page, "BT /F1 %.2f Tf %.2f %.2f Td <", size, x, pdfPageHeight-y)
+	for _, r := range s {
+		glyph := d.font.glyph(r)
+		d.used[glyph] = r
+		fmt.Fprintf(d.page, "%04X", glyph)
+	}
+	d.page.WriteString("> Tj ET")
+
+	if bold {
+		d.page.WriteString(" Q")
+	}
+	d.page.WriteString("\n")
+}
+
+func (d *pdfDocument) paragraph(s string, size float64, align pdfAlign, bold bool) {
+	for _, line := range d.wrap(s, size, pdfContentWidth) {
+		height := d.lineHeight(line, size, pdfContentWidth)
+		d.fit(height)
+
+		x := pdfMargin
+		if align == pdfAlignCenter {
+			x += (pdfContentWidth - d.width(line, size, pdfContentWidth)) / 2
+		}
+
+		d.line(x, d.y, height, line, size, bold, pdfContentWidth)
+		d.y += height
+	}
+}
+
+// table draws rows with cell borders.
+func (d *pdfDocument) table(rows []pdfRow, size float64) {
+	for _, row := range rows {
+		cells := make([][]string, len(row.cells))
+		rowHeight := 0.0
+		for j, cell := range row.cells {
+			width := row.widths[j]*pdfContentWidth - 2*pdfCellPadding
+			cells[j] = d.wrap(cell, size, width)
+
+			height := 0.0
+			for _, line := range cells[j] {
+				height += d.lineHeight(line, size, width)
+			}
+			rowHeight = max(rowHeight, height)
+		}
+
+		rowHeight += 2 * pdfCellPadding
+		d.fit(rowHeight)
+
+		x := pdfMargin
+		for j := range row.cells {
+			width := row.widths[j] * pdfContentWidth
+			fmt.Fprintf(d.page, "%.2f %.2f %.2f %.2f re S\n", x, pdfPageHeight-d.y-rowHeight, width, rowHeight)
+
+			y := d.y + pdfCellPadding
+			for _, line := range cells[j] {
+				height := d.lineHeight(line, size, width-2*pdfCellPadding)
+				d.line(x+pdfCellPadding, y, height, line, size, row.bold, width-2*pdfCellPadding)
+				y += height
+			}
+
+			x += width
+		}
+
+		d.y += rowHeight
+	}
+}
+
+// addSignatures adds the images of the signatures, so that they are drawn in place of their markers.
+func (d *pdfDocument) addSignatures(signatures []actSignature) error {
+	for _, signature := range signatures {
+		rgb, alpha, err := signature.rgba()
+		if err != nil {
+			return fmt.Errorf("decode signature: %w", err)
+		}
+
+		d.images = append(d.images, pdfImage{width: signature.width, height: signature.height, rgb: rgb, alpha: alpha})
+		d.signatures = append(d.signatures, &pdfSignature{actSignature: signature, image: len(d.images)})
+	}
+
+	return nil
+}
+
+// remainingSignatures draws the signatures whose markers are not in the text under their labels after the rest
+// of the act.
+func (d *pdfDocument) remainingSignatures() {
+	for _, signature := range d.signatures {
+		if signature.drawn {
+			continue
+		}
+
+		width, height := signature.size(pdfSignatureHeight, pdfSignatureMaxWidth)
+		d.space(6)
+		d.fit(10*pdfLeading + height)
+		d.paragraph(signature.label, 10, pdfAlignLeft, false)
+
+		d.y += height
+		fmt.Fprintf(d.page, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, pdfMargin, pdfPageHeight-d.y, signature.image)
+		signature.drawn = true
+	}
+}
+
+// nextSignature returns the first signature whose marker is in s and the position of the marker.
+func (d *pdfDocument) nextSignature(s string) (*pdfSignature, int) {
+	var (
+		next *pdfSignature
+		pos  = -1
+	)
+
+	for _, signature := range d.signatures {
+		if i := strings.Index(s, signature.marker()); i >= 0 && (pos < 0 || i < pos) {
+			next, pos = signature, i
+		}
+	}
+
+	return next, pos
+}
+
+// width returns the width of the line set in the font of the given size, with signatures narrowed to box.
+func (d *pdfDocument) width(s string, size, box float64) float64 {
+	width := 0.0
+	for _, signature := range d.signatures {
+		if n := strings.Count(s, signature.marker()); n > 0 {
+			s = strings.ReplaceAll(s, signature.marker(), "")
+			w, _ := signature.size(pdfSignatureHeight, min(pdfSignatureMaxWidth, box))
+			width += float64(n) * w
+		}
+	}
+
+	return width + d.font.width(s, size)
+}
+
+// lineHeight returns the height of the line, which is raised to fit signatures narrowed to box.
+func (d *pdfDocument) lineHeight(s string, size, box float64) float64 {
+	height := size * pdfLeading
+	for _, signature := range d.signatures {
+		if strings.Contains(s, signature.marker()) {
+			_, h := signature.size(pdfSignatureHeight, min(pdfSignatureMaxWidth, box))
+			height = max(height, h+size*(pdfLeading-1))
+		}
+	}
+
+	return height
+}
+
+// line draws a line of the given height with its top y points from the top of the page. Signatures are drawn
+// in place of their markers and stand on the baseline.
+func (d *pdfDocument) line(x, y, height float64, s string, size float64, bold bool, box float64) {
+	baseline := y + height - size*(pdfLeading-1) - size*0.2
+
+	for s != "" {
+		signature, i := d.nextSignature(s)
+		if i < 0 {
+			d.text(x, baseline, s, size, bold)
+			return
+		}
+
+		if i > 0 {
+			d.text(x, baseline, s[:i], size, bold)
+			x += d.font.width(s[:i], size)
+		}
+
+		width, h := signature.size(pdfSignatureHeight, min(pdfSignatureMaxWidth, box))
+		fmt.Fprintf(d.page, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, h, x, pdfPageHeight-baseline, signature.image)
+		signature.drawn = true
+
+		x += width
+		s = s[i+len(signature.marker()):]
+	}
+}
+
+// wrap splits s into lines not wider than width. Words longer than a line are split between letters,
+// except signature markers, which are narrowed to the line.
+func (d *pdfDocument) wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+
+			if d.width(candidate, size, width) <= width {
+				line = candidate
+				continue
+			}
+
+			if line != "" {
+				lines = append(lines, line)
+			}
+
+			line = ""
+			if signature, _ := d.nextSignature(word); signature != nil && word == signature.marker() {
+				line = word
+				continue
+			}
+
+			for _, r := range word {
+				if line != "" && d.font.width(line+string(r), size) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// write serializes the document. Only the outlines of the used glyphs are embedded, with their widths and Unicode
+// mapping, so an act carries tens of kilobytes of the font instead of the whole font file.
+func (d *pdfDocument) write(out io.Writer) error {
+	var w pdfWriter
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	const (
+		catalogID = iota + 1
+		pagesID
+		fontID
+		cidFontID
+		descriptorID
+		fontFileID
+		toUnicodeID
+		firstPageID
+	)
+
+	kids := make([]string, 0, len(d.pages))
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPageID+2*i))
+	}
+
+	// Images follow the pages, each with its alpha channel as a soft mask.
+	firstImageID := firstPageID + 2*len(d.pages)
+
+	var xObjects strings.Builder
+	for i := range d.images {
+		fmt.Fprintf(&xObjects, "/Im%d %d 0 R ", i+1, firstImageID+2*i)
+	}
+
+	w.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	w.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	w.object(fontID, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /ActFont /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		cidFontID, toUnicodeID))
+
+	glyphs := slices.Sorted(maps.Keys(d.used))
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, d.font.advance(g))
+	}
+
+	w.object(cidFontID, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ActFont "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>", descriptorID, widths.String()))
+
+	f := d.font
+	w.object(descriptorID, fmt.Sprintf("<< /Type /FontDescriptor /FontName /ActFont /Flags 32 /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.ascent), fontFileID))
+
+	fontFile, err := f.subset(glyphs)
+	if err != nil {
+		return fmt.Errorf("subset font: %w", err)
+	}
+
+	if err = w.stream(fontFileID, fmt.Sprintf("/Length1 %d", len(fontFile)), fontFile); err != nil {
+		return err
+	}
+
+	if err = w.stream(toUnicodeID, "", toUnicodeCMap(glyphs, d.used)); err != nil {
+		return err
+	}
+
+	for i, page := range d.pages {
+		pageID := firstPageID + 2*i
+		w.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 %d 0 R >> /XObject << %s>> >> /Contents %d 0 R >>",
+			pagesID, pdfPageWidth, pdfPageHeight, fontID, xObjects.String(), pageID+1))
+
+		if err := w.stream(pageID+1, "", page.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	for i, img := range d.images {
+		imageID := firstImageID + 2*i
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8", img.width, img.height)
+
+		if err := w.stream(imageID, dict+fmt.Sprintf(" /ColorSpace /DeviceRGB /SMask %d 0 R", imageID+1), img.rgb); err != nil {
+			return err
+		}
+
+		if err := w.stream(imageID+1, dict+" /ColorSpace /DeviceGray", img.alpha); err != nil {
+			return err
+		}
+	}
+
+	w.finish(catalogID)
+
+	_, err = out.Write(w.buf.Bytes())
+
+	return err
+}
+
+func toUnicodeCMap(glyphs []uint16, used map[uint16]rune) []byte {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// A bfchar section holds at most 100 entries.
+	for chunk := range slices.Chunk(glyphs, 100) {
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, unit := range utf16.Encode([]rune{used[g]}) {
+				fmt.Fprintf(&b, "%04X", unit)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	return []byte(b.String())
+}
+
+// pdfWriter writes numbered objects and remembers their offsets for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *pdfWriter) begin(id int) {
+	for len(w.offsets) < id {
+		w.offsets = append(w.offsets, 0)
+	}
+
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", id)
+}
+
+func (w *pdfWriter) object(id int, body string) {
+	w.begin(id)
+	w.buf.WriteString(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// stream writes data compressed with Flate. dict holds extra entries of the stream dictionary.
+func (w *pdfWriter) stream(id int, dict string, data []byte) error {
+	var compressed bytes.Buffer
+	z := zlib.NewWriter(&compressed)
+	if _, err := z.Write(data); err != nil {
+		return fmt.Errorf("compress object %d: %w", id, err)
+	}
+	if err := z.Close(); err != nil {
+		return fmt.Errorf("compress object %d: %w", id, err)
+	}
+
+	w.begin(id)
+	fmt.Fprintf(&w.buf, "<< /Length %d /Filter /FlateDecode %s >>\nstream\n", compressed.Len(), dict)
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+
+	return nil
+}
+
+func (w *pdfWriter) finish(rootID int) {
+	xref := w.buf.Len()
+
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, rootID, xref)
+}
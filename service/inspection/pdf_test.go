@@ -0,0 +1,330 @@
+package inspection
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"inspection-service/config"
+)
+
+// writeTestFont writes a TrueType font with the tables the PDF renderer reads. Every glyph is half an em wide.
+// It returns the path of the font and the characters of its glyphs.
+func writeTestFont(t testing.TB) (string, map[uint16]rune) {
+	t.Helper()
+
+	ranges := [][2]rune{{0x20, 0x7E}, {0xA0, 0xFF}, {0x400, 0x45F}, {0x2013, 0x2014}, {0x2116, 0x2116}, {0x2610, 0x2612}}
+	chars := map[uint16]rune{}
+
+	var ends, starts, deltas []uint16
+	glyph := uint16(1)
+	for _, r := range ranges {
+		ends = append(ends, uint16(r[1]))
+		starts = append(starts, uint16(r[0]))
+		deltas = append(deltas, glyph-uint16(r[0]))
+
+		for c := r[0]; c <= r[1]; c++ {
+			chars[glyph] = c
+			glyph++
+		}
+	}
+	ends = append(ends, 0xFFFF)
+	starts = append(starts, 0xFFFF)
+	deltas = append(deltas, 1)
+
+	u16 := func(b *bytes.Buffer, values ...uint16) {
+		for _, v := range values {
+			_ = binary.Write(b, binary.BigEndian, v)
+		}
+	}
+
+	var subtable bytes.Buffer
+	segments := uint16(len(ends))
+	u16(&subtable, 4, 16+8*segments, 0, 2*segments, 0, 0, 0)
+	u16(&subtable, ends...)
+	u16(&subtable, 0)
+	u16(&subtable, starts...)
+	u16(&subtable, deltas...)
+	u16(&subtable, make([]uint16, segments)...)
+
+	var cmap bytes.Buffer
+	u16(&cmap, 0, 1, 3, 1, 0, 12)
+	cmap.Write(subtable.Bytes())
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+	binary.BigEndian.PutUint16(head[40:], 500)
+	binary.BigEndian.PutUint16(head[42:], 800)
+
+	var hhea bytes.Buffer
+	u16(&hhea, 1, 0, 800, uint16(0x10000-200))
+	hhea.Write(make([]byte, 26))
+	u16(&hhea, glyph)
+
+	var hmtx bytes.Buffer
+	for range glyph {
+		u16(&hmtx, 500, 0)
+	}
+
+	maxp := make([]byte, 6)
+	binary.BigEndian.PutUint16(maxp[4:], glyph)
+
+	// Every glyph is a simple glyph without contours, which is enough to tell glyphs apart in a subset.
+	var loca, glyf bytes.Buffer
+	for g := range glyph {
+		u16(&loca, uint16(glyf.Len()/2))
+		u16(&glyf, 0, g, 0, 0, 0, 0)
+	}
+	u16(&loca, uint16(glyf.Len()/2))
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{
+		{"cmap", cmap.Bytes()}, {"glyf", glyf.Bytes()}, {"head", head}, {"hhea", hhea.Bytes()}, {"hmtx", hmtx.Bytes()},
+		{"loca", loca.Bytes()}, {"maxp", maxp},
+	}
+
+	var font bytes.Buffer
+	_ = binary.Write(&font, binary.BigEndian, uint32(0x00010000))
+	u16(&font, uint16(len(tables)), 0, 0, 0)
+
+	offset := 12 + 16*len(tables)
+	for _, table := range tables {
+		font.WriteString(table.tag)
+		_ = binary.Write(&font, binary.BigEndian, []uint32{0, uint32(offset), uint32(len(table.data))})
+		offset += len(table.data)
+	}
+	for _, table := range tables {
+		font.Write(table.data)
+	}
+
+	path := filepath.Join(t.TempDir(), "font.ttf")
+	if err := os.WriteFile(path, font.Bytes(), 0o600); err != nil {
+		t.Fatalf("write font: %v", err)
+	}
+
+	return path, chars
+}
+
+// loadTestFont returns the font written by writeTestFont with the characters of its glyphs.
+func loadTestFont(t *testing.T) (*trueTypeFont, map[uint16]rune) {
+	t.Helper()
+
+	path, chars := writeTestFont(t)
+
+	font, err := loadTrueType(path)
+	if err != nil {
+		t.Fatalf("loadTrueType returned error: %v", err)
+	}
+
+	return font, chars
+}
+
+// readTestTemplate returns the bundled docx template of the act kind.
+func readTestTemplate(t *testing.T, kind actKind) []byte {
+	t.Helper()
+
+	path := "templates/universal_act.docx"
+	if kind == actKindControl {
+		path = "templates/control_act.docx"
+	}
+
+	template, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	return template
+}
+
+func TestPDFRendererRendersEveryDevice(t *testing.T) {
+	font, chars := loadTestFont(t)
+	a, wants := newTestControlAct(t)
+
+	buf, err := pdfRenderer{font: font, template: readTestTemplate(t, a.kind)}.render(a)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("pdf has no header or trailer")
+	}
+
+	checkPDFCrossReferences(t, data)
+
+	streams := pdfStreams(t, data)
+
+	text := pdfText(t, streams, chars)
+	for _, want := range append(wants, "АКТ№7", "ОСУЩЕСТВЛЕНИЯПРОВЕРКИ") {
+		if !strings.Contains(text, strings.ReplaceAll(want, " ", "")) {
+			t.Fatalf("act does not contain %q: %s", want, text)
+		}
+	}
+	if strings.Contains(text, "{") {
+		t.Fatalf("act contains unreplaced placeholders: %s", text)
+	}
+
+	var cmap string
+	for _, stream := range streams {
+		if bytes.Contains(stream, []byte("beginbfchar")) {
+			cmap = string(stream)
+		}
+	}
+
+	// Glyphs of the test font go in the order of its ranges, so Cyrillic А follows ASCII and Latin-1.
+	glyph := 1 + (0x7E - 0x20 + 1) + (0xFF - 0xA0 + 1) + (0x410 - 0x400)
+	if !strings.Contains(cmap, fmt.Sprintf("<%04X> <0410>", glyph)) {
+		t.Fatalf("ToUnicode does not map glyph %d to А: %s", glyph, cmap)
+	}
+}
+
+func TestPDFRendererFollowsTemplateVersion(t *testing.T) {
+	font, chars := loadTestFont(t)
+	a, _ := newTestControlAct(t)
+
+	template := rewriteTestTemplate(t, "templates/control_act.docx", "ОСУЩЕСТВЛЕНИЯ ПРОВЕРКИ", "ПРОВЕРКИ (ред. 2)")
+
+	buf, err := pdfRenderer{font: font, template: template}.render(a)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	if text := pdfText(t, pdfStreams(t, buf.Bytes()), chars); !strings.Contains(text, "ПРОВЕРКИ(ред.2)") {
+		t.Fatalf("act does not follow the template: %s", text)
+	}
+}
+
+func TestTrueTypeSubsetKeepsUsedAndComponentGlyphs(t *testing.T) {
+	font, _ := loadTestFont(t)
+
+	// Glyph 10 becomes a composite of glyph 3 with byte offsets.
+	font.glyphData[10] = []byte{0xFF, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0}
+
+	data, err := font.subset([]uint16{10, 20})
+	if err != nil {
+		t.Fatalf("subset returned error: %v", err)
+	}
+
+	tables, err := trueTypeTables(data)
+	if err != nil {
+		t.Fatalf("trueTypeTables returned error: %v", err)
+	}
+	if _, ok := tables["cmap"]; ok {
+		t.Fatal("subset keeps the cmap table")
+	}
+
+	glyphs, err := parseGlyphs(tables["maxp"], tables["loca"], tables["glyf"], 1)
+	if err != nil {
+		t.Fatalf("parseGlyphs returned error: %v", err)
+	}
+
+	var kept []int
+	for i, g := range glyphs {
+		if len(g) == 0 {
+			continue
+		}
+
+		kept = append(kept, i)
+		if !bytes.Equal(g[:len(font.glyphData[i])], font.glyphData[i]) {
+			t.Fatalf("glyph %d = %x, want %x", i, g, font.glyphData[i])
+		}
+	}
+
+	if fmt.Sprint(kept) != "[0 3 10 20]" {
+		t.Fatalf("kept glyphs = %v, want [0 3 10 20]", kept)
+	}
+}
+
+func TestInitActTemplatesRequiresFont(t *testing.T) {
+	service := &Service{
+		repository:   &repositoryMock{},
+		templates:    config.Templates{Font: filepath.Join(t.TempDir(), "missing.ttf")},
+		actTemplates: newActTemplateCache(),
+	}
+
+	if err := service.InitActTemplates(context.Background()); err == nil {
+		t.Fatal("InitActTemplates returned nil error, want missing font error")
+	}
+}
+
+// pdfText returns the text drawn by the streams without spaces, since lines are wrapped at spaces.
+func pdfText(t *testing.T, streams [][]byte, chars map[uint16]rune) string {
+	t.Helper()
+
+	var text strings.Builder
+	for _, stream := range streams {
+		for _, m := range regexp.MustCompile(`<([0-9A-F]*)> Tj`).FindAllSubmatch(stream, -1) {
+			glyphs, err := hex.DecodeString(string(m[1]))
+			if err != nil {
+				t.Fatalf("decode glyphs %s: %v", m[1], err)
+			}
+
+			for i := 0; i < len(glyphs); i += 2 {
+				text.WriteRune(chars[binary.BigEndian.Uint16(glyphs[i:])])
+			}
+		}
+	}
+
+	return strings.ReplaceAll(text.String(), " ", "")
+}
+
+func checkPDFCrossReferences(t *testing.T, data []byte) {
+	t.Helper()
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("pdf has no startxref")
+	}
+
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref", xref)
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(data[xref:], -1)
+	if len(entries) == 0 {
+		t.Fatal("xref has no objects")
+	}
+
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj\n"; !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Fatalf("xref entry %d points to %q, want %q", i+1, data[offset:offset+len(want)], want)
+		}
+	}
+}
+
+func pdfStreams(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+
+	var streams [][]byte
+	for _, m := range regexp.MustCompile(`(?s)/Length (\d+)[^>]*>>\nstream\n`).FindAllSubmatchIndex(data, -1) {
+		length, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+
+		r, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatalf("zlib.NewReader returned error: %v", err)
+		}
+
+		stream, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+
+		streams = append(streams, stream)
+	}
+
+	return streams
+}
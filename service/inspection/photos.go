@@ -0,0 +1,111 @@
+package inspection
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+)
+
+const (
+	// MaxBatchPhotos limits the photos of a batch.
+	MaxBatchPhotos = 30
+	// photoWorkers limits the photos of a batch processed at once, so that a batch does not flood the analyzer.
+	photoWorkers = 4
+)
+
+// PhotoResult is the outcome of a photo of a batch: the attachment, or the error the photo was rejected with.
+// Index is the position of the photo in the request.
+type PhotoResult struct {
+	Index      int         `json:"Index"`
+	FileName   string      `json:"FileName"`
+	Attachment *Attachment `json:"Attachment,omitempty"`
+	Error      *PhotoError `json:"Error,omitempty"`
+}
+
+// PhotoError is the error a photo was rejected with. Code is the code of the error as in error responses,
+// the scores are set for blurred photos.
+type PhotoError struct {
+	Code         string `json:"Code"`
+	Message      string `json:"Message"`
+	BlurScore    string `json:"BlurScore,omitempty"`
+	QualityScore string `json:"QualityScore,omitempty"`
+}
+
+func newPhotoError(err error) *PhotoError {
+	photoErr := &PhotoError{Code: ErrorCode(err), Message: err.Error()}
+
+	var blurred BlurredPhotoError
+	if errors.As(err, &blurred) {
+		photoErr.BlurScore = blurred.BlurScore
+		photoErr.QualityScore = blurred.QualityScore
+	}
+
+	return photoErr
+}
+
+// AttachPhotos attaches a batch of photos to an editable inspection. Photos are checked by the analyzer and
+// uploaded concurrently by a few workers, and each of them is attached or rejected on its own: a blurred photo
+// or an unavailable service fails only its photo. Results follow the order of the photos.
+func (s *Service) AttachPhotos(ctx goctx.Context, log golog.Logger, request AttachPhotosRequest) ([]PhotoResult, error) {
+	if len(request.Photos) == 0 || len(request.Photos) > MaxBatchPhotos {
+		var errs fieldErrors
+		errs.add("Photo", "from 1 to %d photos are required, got %d", MaxBatchPhotos, len(request.Photos))
+		return nil, errs.err()
+	}
+
+	ins, err := s.repository.GetByID(ctx, request.InspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", request.InspectionID))
+	}
+
+	if !ins.Status.IsEditable() {
+		return nil, ErrNotEditable
+	}
+
+	results := make([]PhotoResult, len(request.Photos))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(photoWorkers, len(request.Photos)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				results[i] = s.photoResult(ctx, log, request, i)
+			}
+		}()
+	}
+
+	for i := range request.Photos {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results, nil
+}
+
+// photoResult attaches the i-th photo of the batch.
+func (s *Service) photoResult(ctx goctx.Context, log golog.Logger, request AttachPhotosRequest, i int) PhotoResult {
+	photo := request.Photos[i]
+	result := PhotoResult{Index: i, FileName: photo.FileHeader.Filename}
+
+	attachment, err := s.attachPhoto(ctx, log, request.InspectionID, photo, request.FileHeaders)
+	if err != nil {
+		if !errors.Is(err, ErrValidation) && !errors.Is(err, ErrNotFound) {
+			log.Errorf("failed to attach photo %d %q: %v", i, photo.FileHeader.Filename, err)
+		}
+
+		result.Error = newPhotoError(err)
+		return result
+	}
+
+	result.Attachment = &attachment
+
+	return result
+}
@@ -0,0 +1,266 @@
+package inspection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	clusteranalyzer "inspection-service/cluster/analyzer"
+	clusterfile "inspection-service/cluster/file"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+)
+
+type analyzerServiceMock struct {
+	blurred   map[string]bool
+	delay     time.Duration
+	processed func()
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (m *analyzerServiceMock) ProcessImage(_ goctx.Context, fileName string, image io.Reader) (clusteranalyzer.ProcessImageResponse, error) {
+	m.mu.Lock()
+	m.active++
+	m.maxActive = max(m.maxActive, m.active)
+	m.mu.Unlock()
+
+	if _, err := io.Copy(io.Discard, image); err != nil {
+		return clusteranalyzer.ProcessImageResponse{}, err
+	}
+
+	time.Sleep(m.delay)
+
+	m.mu.Lock()
+	m.active--
+	m.mu.Unlock()
+
+	if m.processed != nil {
+		m.processed()
+	}
+
+	if m.blurred[fileName] {
+		return clusteranalyzer.ProcessImageResponse{IsBlurred: true, BlurScore: "12.5", QualityScore: "0.3"}, nil
+	}
+
+	return clusteranalyzer.ProcessImageResponse{}, nil
+}
+
+func TestAttachPhotosReportsEveryPhoto(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &fileServiceMock{}
+	analyzer := &analyzerServiceMock{blurred: map[string]bool{"blurred.jpg": true}, delay: 10 * time.Millisecond}
+
+	service := newFinishTestService(t, repository, fileService)
+	service.analyzerService = analyzer
+
+	photos := []Photo{
+		{Type: AttachmentTypeDevicePhoto, DeviceID: 11, FileHeader: newTestFileHeader(t, "device.jpg", []byte("device"))},
+		{Type: AttachmentTypeDevicePhoto, DeviceID: 11, FileHeader: newTestFileHeader(t, "blurred.jpg", []byte("blurred"))},
+		{Type: AttachmentTypeSealPhoto, SealID: 99, FileHeader: newTestFileHeader(t, "unknown.jpg", []byte("unknown"))},
+		{Type: AttachmentTypeSealPhoto, SealID: 21, FileHeader: newTestFileHeader(t, "seal.jpg", []byte("seal"))},
+	}
+	for i := range 4 {
+		photos = append(photos, Photo{Type: AttachmentTypeDevicePhoto, DeviceID: 11, FileHeader: newTestFileHeader(t, "more.jpg", []byte{byte(i)})})
+	}
+
+	results, err := service.AttachPhotos(goctx.Wrap(context.Background()), golog.NewLogger("test"), AttachPhotosRequest{
+		InspectionID: 42,
+		Photos:       photos,
+		FileHeaders:  clusterfile.ForwardedHeaders{},
+	})
+	if err != nil {
+		t.Fatalf("AttachPhotos returned error: %v", err)
+	}
+
+	if len(results) != len(photos) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(photos))
+	}
+	for i, result := range results {
+		if result.Index != i || result.FileName != photos[i].FileHeader.Filename {
+			t.Fatalf("results[%d] = %+v, want the result of photo %d", i, result, i)
+		}
+
+		failed := i == 1 || i == 2
+		if failed != (result.Error != nil) || failed == (result.Attachment != nil) {
+			t.Fatalf("results[%d] = %+v, want failed %t", i, result, failed)
+		}
+	}
+
+	if e := results[1].Error; e.Code != "blurred_photo" || e.BlurScore != "12.5" {
+		t.Fatalf("blurred photo error = %+v, want blurred_photo with scores", e)
+	}
+	if e := results[2].Error; e.Code != "seal_not_found" {
+		t.Fatalf("unknown seal error = %+v, want seal_not_found", e)
+	}
+	if results[3].Attachment.Type != AttachmentTypeSealPhoto || len(repository.attachments) != 6 {
+		t.Fatalf("attachments = %+v, want 6 with a seal photo", repository.attachments)
+	}
+
+	if analyzer.maxActive < 2 || analyzer.maxActive > photoWorkers {
+		t.Fatalf("analyzer checked %d photos at once, want from 2 to %d", analyzer.maxActive, photoWorkers)
+	}
+}
+
+func TestAttachPhotosRechecksStatusOfEveryPhoto(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	// The inspection is submitted while its photo is checked by the analyzer.
+	service.analyzerService = &analyzerServiceMock{processed: func() {
+		ins := repository.inspectionsByID[42]
+		ins.Status = StatusSubmitted
+		repository.inspectionsByID[42] = ins
+	}}
+
+	results, err := service.AttachPhotos(goctx.Wrap(context.Background()), golog.NewLogger("test"), AttachPhotosRequest{
+		InspectionID: 42,
+		Photos:       []Photo{{Type: AttachmentTypeDevicePhoto, DeviceID: 11, FileHeader: newTestFileHeader(t, "device.jpg", []byte("device"))}},
+	})
+	if err != nil {
+		t.Fatalf("AttachPhotos returned error: %v", err)
+	}
+
+	if e := results[0].Error; e == nil || e.Code != "inspection_not_editable" {
+		t.Fatalf("results[0] = %+v, want inspection_not_editable", results[0])
+	}
+	if len(repository.attachments) != 0 {
+		t.Fatalf("attachments = %+v, want none for a submitted inspection", repository.attachments)
+	}
+}
+
+func TestAttachPhotosRequiresPhotos(t *testing.T) {
+	service := &Service{repository: &repositoryMock{}}
+
+	_, err := service.AttachPhotos(goctx.Wrap(context.Background()), golog.NewLogger("test"), AttachPhotosRequest{InspectionID: 42})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("AttachPhotos error = %v, want ErrValidation", err)
+	}
+}
+
+// newSpooledPhotos returns device photos of the size parsed from a form that keeps at most one photo in memory,
+// so the others are spooled to temporary files as in a real request.
+func newSpooledPhotos(tb testing.TB, count, size int) []Photo {
+	tb.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	content := bytes.Repeat([]byte{0xFF}, size)
+	for i := range count {
+		fw, err := w.CreateFormFile("Photo", fmt.Sprintf("photo%d.jpg", i))
+		if err != nil {
+			tb.Fatalf("CreateFormFile returned error: %v", err)
+		}
+		if _, err = fw.Write(content); err != nil {
+			tb.Fatalf("write form file: %v", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		tb.Fatalf("close form: %v", err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(int64(size))
+	if err != nil {
+		tb.Fatalf("ReadForm returned error: %v", err)
+	}
+	tb.Cleanup(func() { _ = form.RemoveAll() })
+
+	photos := make([]Photo, 0, count)
+	for _, f := range form.File["Photo"] {
+		photos = append(photos, Photo{Type: AttachmentTypeDevicePhoto, DeviceID: 11, FileHeader: f})
+	}
+
+	return photos
+}
+
+// BenchmarkAttachPhotos attaches batches of 4 MB photos from concurrent requests. The baseline reads the photos
+// of a batch into memory, as they were read before photos were streamed from the form to the analyzer and
+// the upload; a streamed batch must allocate a small part of that.
+// newAttachPhotosTestService returns a service that attaches photos without keeping their content.
+func newAttachPhotosTestService(tb testing.TB) *Service {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+
+	service := newFinishTestService(tb, repository, &fileServiceMock{discardContent: true})
+	service.analyzerService = &analyzerServiceMock{}
+
+	return service
+}
+
+func TestAttachPhotosStreamsPhotos(t *testing.T) {
+	const (
+		photoSize = 4 << 20
+		batches   = 4
+	)
+
+	service := newAttachPhotosTestService(t)
+	request := AttachPhotosRequest{InspectionID: 42, Photos: newSpooledPhotos(t, 8, photoSize)}
+	ctx := goctx.Wrap(context.Background())
+	log := golog.NewLogger("test")
+
+	batchSize := uint64(len(request.Photos)) * photoSize
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	for range batches {
+		if _, err := service.AttachPhotos(ctx, log, request); err != nil {
+			t.Fatalf("AttachPhotos returned error: %v", err)
+		}
+	}
+
+	runtime.ReadMemStats(&after)
+
+	// Photos read into memory would allocate at least the size of the batch.
+	if perBatch := (after.TotalAlloc - before.TotalAlloc) / batches; perBatch > batchSize/8 {
+		t.Fatalf("a batch allocated %d bytes, want at most 1/8 of its %d bytes", perBatch, batchSize)
+	}
+}
+
+// BenchmarkAttachPhotos attaches batches of 4 MB photos from concurrent requests.
+func BenchmarkAttachPhotos(b *testing.B) {
+	const photoSize = 4 << 20
+
+	service := newAttachPhotosTestService(b)
+	request := AttachPhotosRequest{InspectionID: 42, Photos: newSpooledPhotos(b, 8, photoSize)}
+	ctx := goctx.Wrap(context.Background())
+	log := golog.NewLogger("test")
+
+	b.SetBytes(int64(len(request.Photos)) * photoSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			results, err := service.AttachPhotos(ctx, log, request)
+			if err != nil {
+				b.Errorf("AttachPhotos returned error: %v", err)
+				return
+			}
+
+			for _, result := range results {
+				if result.Error != nil {
+					b.Errorf("photo %d: %s", result.Index, result.Error.Message)
+					return
+				}
+			}
+		}
+	})
+}
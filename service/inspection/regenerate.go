@@ -0,0 +1,194 @@
+package inspection
+
+import (
+	"fmt"
+	"inspection-service/cluster/file"
+	"inspection-service/cluster/subscriber"
+	"slices"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+// RegenerateAct draws up the act of a submitted inspection again from its stored results, for example after
+// its template is fixed. The act keeps the date of the inspection and the readings it was submitted with.
+// The new files are attached with the reason, the previous act files are kept and marked as superseded.
+func (s *Service) RegenerateAct(ctx goctx.Context, log golog.Logger, request RegenerateActRequest,
+	headers file.ForwardedHeaders) ([]Attachment, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	ins, err := s.repository.GetByID(ctx, request.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", request.ID))
+	}
+
+	// An inspection that is being corrected gets a new act when it is submitted again.
+	if ins.Type == nil || ins.InspectAt == nil || ins.Status.IsEditable() {
+		return nil, ErrActNotRegenerable
+	}
+
+	brig, contract, err := s.actParties(ctx, ins)
+	if err != nil {
+		return nil, err
+	}
+
+	finish := submittedRequest(ins, contract.Object)
+	finish.ActFormats = request.ActFormats
+
+	finish.Signatures, err = s.repository.GetSignatures(ctx, ins.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get signatures: %w", err)
+	}
+
+	previous, err := s.previousReadings(ctx, finish)
+	if err != nil {
+		return nil, err
+	}
+
+	// Readings taken after the inspection must not change its act.
+	for _, d := range ins.InspectedDevices {
+		previous[d.DeviceID] = slices.DeleteFunc(previous[d.DeviceID], func(reading InspectedDevice) bool {
+			return !reading.CreatedAt.Before(d.CreatedAt)
+		})
+	}
+
+	a, actName, err := newAct(finish, issuedActNumber(ins), brig, contract, previous, ins.InspectAt.In(gotime.Moscow))
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := s.actTemplate(ctx, finish.Type)
+	if err != nil {
+		return nil, fmt.Errorf("get act template: %w", err)
+	}
+
+	upload := ActUpload{InspectionID: ins.ID, DrawnAt: *ins.InspectAt, Reason: &request.Reason}
+	acts, err := s.uploadActs(ctx, log, a, template, actName, finish.actFormats(), upload, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+	err = s.repository.WithTx(ctx, func(tx Repository) error {
+		status, err := tx.GetStatusForUpdate(ctx, ins.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", err)
+		}
+
+		// The inspection may have been rejected while the act was rendered.
+		if status.IsEditable() {
+			return ErrActNotRegenerable
+		}
+
+		if err = tx.SupersedeActs(ctx, ins.ID); err != nil {
+			return fmt.Errorf("supersede acts: %w", err)
+		}
+
+		attachments, err = addActAttachments(ctx, tx, ins.ID, acts, &request.Reason)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.SettleActUploads(ctx, ins.ID); err != nil {
+			return fmt.Errorf("settle act uploads: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		// The act files stay recorded as uploads of the inspection for the retry.
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// submittedRequest restores the request the inspection was submitted with. Consumption is taken as stored,
+// so the regenerated act shows the same figures as the original one. Unassigned seals are put under the device
+// the object has them on.
+func submittedRequest(ins Inspection, object subscriber.Object) FinishInspectionRequest {
+	request := FinishInspectionRequest{
+		ID:                      ins.ID,
+		Type:                    valueOf(ins.Type),
+		Resolution:              valueOf(ins.Resolution),
+		LimitReason:             ins.LimitReason,
+		Method:                  valueOf(ins.Method),
+		MethodBy:                valueOf(ins.MethodBy),
+		ReasonType:              valueOf(ins.ReasonType),
+		ReasonDescription:       ins.ReasonDescription,
+		IsRestrictionChecked:    valueOf(ins.IsRestrictionChecked),
+		IsViolationDetected:     valueOf(ins.IsViolationDetected),
+		IsExpenseAvailable:      valueOf(ins.IsExpenseAvailable),
+		ViolationDescription:    ins.ViolationDescription,
+		IsUnauthorizedConsumers: valueOf(ins.IsUnauthorizedConsumers),
+		UnauthorizedDescription: ins.UnauthorizedDescription,
+		UnauthorizedExplanation: ins.UnauthorizedExplanation,
+		EnergyActionAt:          valueOf(ins.EnergyActionAt),
+		InspectedDevices:        make([]InspectedDeviceRequest, 0, len(ins.InspectedDevices)),
+	}
+
+	for _, d := range ins.InspectedDevices {
+		device := InspectedDeviceRequest{
+			DeviceID:            d.DeviceID,
+			Value:               d.Value,
+			Consumption:         valueOf(d.Consumption),
+			ComputedConsumption: d.Consumption,
+			IsReplaced:          d.IsReplaced,
+			IsRollover:          d.IsRollover,
+			InspectedSeals:      make([]InspectedSealRequest, 0, len(d.InspectedSeals)),
+		}
+		if d.DeclaredConsumption != nil {
+			device.Consumption = *d.DeclaredConsumption
+		}
+
+		for _, seal := range d.InspectedSeals {
+			device.InspectedSeals = append(device.InspectedSeals, InspectedSealRequest{SealID: seal.SealID, IsBroken: seal.IsBroken})
+		}
+
+		request.InspectedDevices = append(request.InspectedDevices, device)
+	}
+
+	for _, seal := range ins.UnassignedSeals {
+		deviceID, ok := sealDevice(object, seal.SealID)
+		if !ok {
+			continue
+		}
+
+		i := slices.IndexFunc(request.InspectedDevices, func(d InspectedDeviceRequest) bool {
+			return d.DeviceID == deviceID
+		})
+		if i < 0 {
+			continue
+		}
+
+		request.InspectedDevices[i].InspectedSeals = append(request.InspectedDevices[i].InspectedSeals,
+			InspectedSealRequest{SealID: seal.SealID, IsBroken: seal.IsBroken})
+	}
+
+	return request
+}
+
+// sealDevice returns the device of the object the seal is installed on.
+func sealDevice(object subscriber.Object, sealID int) (int, bool) {
+	for _, device := range object.Devices {
+		for _, seal := range device.Seals {
+			if seal.ID == sealID {
+				return device.ID, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func valueOf[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+
+	return v
+}
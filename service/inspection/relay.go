@@ -0,0 +1,126 @@
+package inspection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sunshineOfficial/golib/gokafka"
+	"github.com/sunshineOfficial/golib/golog"
+)
+
+const (
+	kafkaProduceTimeout = 1 * time.Minute
+	relayPollInterval   = 5 * time.Second
+	relayBatchSize      = 100
+)
+
+// Relay drains the outbox table to Kafka. Events are marked as sent only after a successful produce,
+// so they are delivered at least once. Events of one inspection are delivered in order: an event waits
+// until the earlier events of its inspection are sent, including while a failed one backs off.
+type Relay struct {
+	repository Repository
+	producer   gokafka.Producer
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRelay(repository Repository, producer gokafka.Producer) *Relay {
+	return &Relay{
+		repository: repository,
+		producer:   producer,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context, log golog.Logger) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(relayPollInterval)
+		defer ticker.Stop()
+
+		for {
+			for {
+				sent, err := r.relayBatch(ctx, log)
+				if err != nil {
+					log.Errorf("failed to relay inspection events: %v", err)
+				}
+				// A batch holds one event per inspection, so later events may be waiting behind the sent ones.
+				if err != nil || sent == 0 {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Relay) Stop() {
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	r.wg.Wait()
+}
+
+// relayBatch produces one batch of pending events and returns how many of them were sent. The repository
+// returns only the earliest unsent event of each inspection, so a failed event is never overtaken while it backs off.
+func (r *Relay) relayBatch(ctx context.Context, log golog.Logger) (int, error) {
+	var sent int
+	err := r.repository.WithTx(ctx, func(tx Repository) error {
+		events, err := tx.GetPendingEvents(ctx, relayBatchSize)
+		if err != nil {
+			return fmt.Errorf("get pending events: %w", err)
+		}
+
+		for _, event := range events {
+			if err = r.produce(ctx, event); err != nil {
+				log.Errorf("failed to produce inspection event %d (attempt %d): %v", event.ID, event.Attempts+1, err)
+
+				if err = tx.MarkEventFailed(ctx, event.ID, err.Error()); err != nil {
+					return fmt.Errorf("mark event %d failed: %w", event.ID, err)
+				}
+
+				continue
+			}
+
+			if err = tx.MarkEventSent(ctx, event.ID); err != nil {
+				return fmt.Errorf("mark event %d sent: %w", event.ID, err)
+			}
+
+			sent++
+		}
+
+		return nil
+	})
+
+	return sent, err
+}
+
+func (r *Relay) produce(ctx context.Context, event OutboxEvent) error {
+	message, err := gokafka.NewJSONMessage("", json.RawMessage(event.Payload))
+	if err != nil {
+		return fmt.Errorf("create json message: %w", err)
+	}
+
+	produceCtx, produceCtxCancel := context.WithTimeout(ctx, kafkaProduceTimeout)
+	defer produceCtxCancel()
+
+	if err = r.producer.Produce(produceCtx, message); err != nil {
+		return fmt.Errorf("produce message: %w", err)
+	}
+
+	return nil
+}
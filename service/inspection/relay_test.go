@@ -0,0 +1,79 @@
+package inspection
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sunshineOfficial/golib/gokafka"
+	"github.com/sunshineOfficial/golib/golog"
+)
+
+type producerMock struct {
+	calls     int
+	failCalls map[int]bool
+}
+
+func (m *producerMock) Produce(context.Context, ...gokafka.Message) error {
+	m.calls++
+	if m.failCalls[m.calls] {
+		return errors.New("broker unavailable")
+	}
+
+	return nil
+}
+
+func (m *producerMock) Close(context.Context) error {
+	return nil
+}
+
+func TestRelayBatchMarksProducedEventsSent(t *testing.T) {
+	repository := &repositoryMock{pendingEvents: []OutboxEvent{
+		{ID: 1, InspectionID: 10},
+		{ID: 2, InspectionID: 20},
+	}}
+	producer := &producerMock{}
+
+	sent, err := NewRelay(repository, producer).relayBatch(context.Background(), golog.NewLogger("test"))
+	if err != nil {
+		t.Fatalf("relayBatch returned error: %v", err)
+	}
+
+	if sent != 2 || producer.calls != 2 {
+		t.Fatalf("sent = %d, produce calls = %d, want 2 and 2", sent, producer.calls)
+	}
+	if !reflect.DeepEqual(repository.sentEvents, []int{1, 2}) || len(repository.failedEvents) != 0 {
+		t.Fatalf("sent events = %v, failed events = %v, want [1 2] and none", repository.sentEvents, repository.failedEvents)
+	}
+}
+
+func TestRelayBatchBacksOffFailedEventAndKeepsInspectionOrder(t *testing.T) {
+	repository := &repositoryMock{pendingEvents: []OutboxEvent{
+		{ID: 1, InspectionID: 10},
+		{ID: 2, InspectionID: 20},
+		{ID: 3, InspectionID: 10},
+		{ID: 4, InspectionID: 20},
+	}}
+	producer := &producerMock{failCalls: map[int]bool{1: true}}
+	relay := NewRelay(repository, producer)
+
+	// Each batch holds the earliest unsent event of every inspection: event 3 waits for the failed event 1,
+	// event 4 is sent in the batch after event 2.
+	for _, want := range [][]int{{2}, {2, 4}, {2, 4}} {
+		if _, err := relay.relayBatch(context.Background(), golog.NewLogger("test")); err != nil {
+			t.Fatalf("relayBatch returned error: %v", err)
+		}
+
+		if !reflect.DeepEqual(repository.sentEvents, want) {
+			t.Fatalf("sent events = %v, want %v", repository.sentEvents, want)
+		}
+	}
+
+	if !reflect.DeepEqual(repository.failedEvents, []int{1}) {
+		t.Fatalf("failed events = %v, want [1] to be rescheduled", repository.failedEvents)
+	}
+	if producer.calls != 3 {
+		t.Fatalf("produce calls = %d, want 3", producer.calls)
+	}
+}
@@ -0,0 +1,278 @@
+package inspection
+
+import (
+	"bytes"
+	"fmt"
+	"inspection-service/cluster/file"
+	"maps"
+	"slices"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+	"github.com/sunshineOfficial/golib/pagination"
+)
+
+type ActFormat string
+
+const (
+	ActFormatDOCX ActFormat = "docx"
+	ActFormatPDF  ActFormat = "pdf"
+)
+
+func (f ActFormat) Validate() error {
+	switch f {
+	case ActFormatDOCX, ActFormatPDF:
+		return nil
+	default:
+		return fmt.Errorf("act format must be %q or %q", ActFormatDOCX, ActFormatPDF)
+	}
+}
+
+func (f ActFormat) ContentType() string {
+	if f == ActFormatPDF {
+		return "application/pdf"
+	}
+
+	return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+}
+
+// AttachmentType returns the type of the attachment that stores an act in the format.
+func (f ActFormat) AttachmentType() AttachmentType {
+	if f == ActFormatPDF {
+		return AttachmentTypeActPDF
+	}
+
+	return AttachmentTypeAct
+}
+
+// actRenderer writes an act in a single file format.
+type actRenderer interface {
+	render(a act) (*bytes.Buffer, error)
+}
+
+func (s *Service) actRenderer(format ActFormat, template ActTemplate) actRenderer {
+	if format == ActFormatPDF {
+		return pdfRenderer{font: s.pdfFont, template: template.Content}
+	}
+
+	return docxRenderer{template: template.Content}
+}
+
+// docxRenderer fills the docx template of the act.
+type docxRenderer struct {
+	template []byte
+}
+
+func (r docxRenderer) render(a act) (*bytes.Buffer, error) {
+	buf, err := writeDocXTemplate(r.template, a.placeholders(), a.rows)
+	if err != nil {
+		return nil, fmt.Errorf("writeDocXTemplate: %w", err)
+	}
+
+	if len(a.signatures) == 0 {
+		return buf, nil
+	}
+
+	buf, err = embedDocxSignatures(buf.Bytes(), a.signatures)
+	if err != nil {
+		return nil, fmt.Errorf("embed signatures: %w", err)
+	}
+
+	return buf, nil
+}
+
+// ActDocument is a rendered act that is not stored anywhere.
+type ActDocument struct {
+	FileName string
+	Format   ActFormat
+	Content  *bytes.Buffer
+}
+
+// PreviewAct renders the act the inspection would get if it were finished with the request. Nothing is uploaded
+// or written, and the status of the inspection is not checked, so the act can be shown before finishing.
+// The act has a number only if the inspection was given one by an earlier attempt to finish it.
+func (s *Service) PreviewAct(ctx goctx.Context, request FinishInspectionRequest, format ActFormat) (ActDocument, error) {
+	if format == "" {
+		format = ActFormatDOCX
+	}
+
+	if err := format.Validate(); err != nil {
+		var errs fieldErrors
+		errs.add("format", "%s", err)
+		return ActDocument{}, errs.err()
+	}
+
+	ins, err := s.repository.GetByID(ctx, request.ID)
+	if err != nil {
+		return ActDocument{}, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", request.ID))
+	}
+
+	draft, err := s.draftAct(ctx, ins, &request)
+	if err != nil {
+		return ActDocument{}, err
+	}
+
+	number, err := s.previewActNumber(ins, request.Type, draft.now)
+	if err != nil {
+		return ActDocument{}, err
+	}
+
+	a, name, err := draft.act(number)
+	if err != nil {
+		return ActDocument{}, err
+	}
+
+	template, err := s.actTemplate(ctx, request.Type)
+	if err != nil {
+		return ActDocument{}, fmt.Errorf("get act template: %w", err)
+	}
+
+	buf, err := s.actRenderer(format, template).render(a)
+	if err != nil {
+		return ActDocument{}, fmt.Errorf("render %s act: %w", format, err)
+	}
+
+	return ActDocument{
+		FileName: name + "." + string(format),
+		Format:   format,
+		Content:  buf,
+	}, nil
+}
+
+// actFormats returns the requested act formats, DOCX when none are given.
+func (r FinishInspectionRequest) actFormats() []ActFormat {
+	if len(r.ActFormats) == 0 {
+		return []ActFormat{ActFormatDOCX}
+	}
+
+	return r.ActFormats
+}
+
+// actFile is an uploaded act file with the template it was rendered from. Signature is set when the service
+// signs acts.
+type actFile struct {
+	format     ActFormat
+	file       file.File
+	templateID *int
+	signature  []byte
+	sha256     string
+}
+
+// uploadActs renders, signs and digests the act in every format and uploads the files. Nothing is uploaded when
+// rendering fails. Every uploaded file is recorded as upload of the inspection until it is attached, and a file
+// with the same content recorded by a failed attempt is taken instead of uploading it again.
+func (s *Service) uploadActs(ctx goctx.Context, log golog.Logger, a act, template ActTemplate, name string, formats []ActFormat,
+	upload ActUpload, headers file.ForwardedHeaders) ([]actFile, error) {
+	buffers := make([]*bytes.Buffer, 0, len(formats))
+	rendered := make([]actFile, 0, len(formats))
+	for _, format := range formats {
+		buf, err := s.actRenderer(format, template).render(a)
+		if err != nil {
+			return nil, fmt.Errorf("render %s act: %w", format, err)
+		}
+
+		buffers = append(buffers, buf)
+		rendered = append(rendered, actFile{
+			format:     format,
+			templateID: &template.ID,
+			signature:  s.signer.sign(buf.Bytes()),
+			sha256:     actDigest(buf.Bytes()),
+		})
+	}
+
+	uploaded, err := s.uploadedActFiles(ctx, upload.InspectionID, rendered, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	acts := make([]actFile, 0, len(formats))
+	for i, f := range rendered {
+		var ok bool
+		if f.file, ok = uploaded[i]; ok {
+			acts = append(acts, f)
+			continue
+		}
+
+		f.file, err = s.fileService.Upload(ctx, name+"."+string(f.format), buffers[i], headers)
+		if err != nil {
+			return nil, fmt.Errorf("upload %s act: %w", f.format, upstream("file-service", err))
+		}
+
+		record := upload
+		record.FileID = f.file.ID
+		record.Format = f.format
+		record.SHA256 = f.sha256
+		if err = s.repository.AddActUpload(ctx, record); err != nil {
+			// The file is still attached when the attempt succeeds; only its retry cannot take it.
+			log.Errorf("failed to record upload of act file %d: %v", f.file.ID, err)
+		}
+
+		acts = append(acts, f)
+	}
+
+	return acts, nil
+}
+
+// uploadedActFiles returns the files recorded as uploads of the inspection that have the content of the rendered acts,
+// by the index of the act.
+func (s *Service) uploadedActFiles(ctx goctx.Context, inspectionID int, rendered []actFile,
+	headers file.ForwardedHeaders) (map[int]file.File, error) {
+	uploads, err := s.repository.GetActUploads(ctx, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("get act uploads: %w", err)
+	}
+
+	fileIDs := make(map[int]int)
+	for i, f := range rendered {
+		j := slices.IndexFunc(uploads, func(u ActUpload) bool {
+			return u.Format == f.format && u.SHA256 == f.sha256
+		})
+		if j >= 0 {
+			fileIDs[i] = uploads[j].FileID
+		}
+	}
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	// The URLs are resolved again, because the stored ones may no longer be valid.
+	files, err := s.fileService.GetByIDs(ctx, slices.Collect(maps.Values(fileIDs)), pagination.Pagination{}, headers)
+	if err != nil {
+		return nil, fmt.Errorf("get files by ids: %w", upstream("file-service", err))
+	}
+
+	uploaded := make(map[int]file.File, len(fileIDs))
+	for i, id := range fileIDs {
+		// A file the file service no longer has is uploaded again.
+		j := slices.IndexFunc(files, func(f file.File) bool { return f.ID == id })
+		if j >= 0 {
+			uploaded[i] = files[j]
+		}
+	}
+
+	return uploaded, nil
+}
+
+// addActAttachments attaches the uploaded act files to the inspection. reason is set when the act is regenerated.
+func addActAttachments(ctx goctx.Context, repository Repository, inspectionID int, acts []actFile, reason *string) ([]Attachment, error) {
+	attachments := make([]Attachment, 0, len(acts))
+	for _, f := range acts {
+		attachment, err := repository.AddAttachment(ctx, Attachment{
+			InspectionID:  inspectionID,
+			Type:          f.format.AttachmentType(),
+			FileID:        f.file.ID,
+			ActTemplateID: f.templateID,
+			Reason:        reason,
+			Signature:     f.signature,
+			SHA256:        f.sha256,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("add attachment: %w", err)
+		}
+
+		attachment.FileURL = f.file.URL
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
@@ -0,0 +1,127 @@
+package inspection
+
+import (
+	"fmt"
+	"inspection-service/cluster/file"
+	"inspection-service/cluster/task"
+	"slices"
+
+	"github.com/sunshineOfficial/golib/goctx"
+)
+
+// ApproveInspection accepts a submitted inspection. If the task is already finished, the inspection is done right away,
+// otherwise it becomes done when the task service finishes the task.
+func (s *Service) ApproveInspection(ctx goctx.Context, request ReviewRequest, headers file.ForwardedHeaders) (Inspection, error) {
+	if !s.isReviewer(ctx) {
+		return Inspection{}, ErrReviewerRequired
+	}
+
+	ins, err := s.repository.GetByID(ctx, request.ID)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", request.ID))
+	}
+
+	// The task is read under the lock of the inspection, so a task finished meanwhile is either seen here,
+	// or handleFinishedTask waits for the approval and moves the approved inspection to done.
+	taskDone := func() (bool, error) {
+		tsk, err := s.taskService.GetTaskByID(ctx, ins.TaskID)
+		if err != nil {
+			return false, fmt.Errorf("get task by id: %w", upstream("task-service", err))
+		}
+
+		return tsk.Status == task.StatusDone, nil
+	}
+
+	return s.review(ctx, request, StatusApproved, EventTypeApproved, taskDone, headers)
+}
+
+// RejectInspection returns a submitted inspection to the inspector, who has to finish it again with a new act.
+func (s *Service) RejectInspection(ctx goctx.Context, request ReviewRequest, headers file.ForwardedHeaders) (Inspection, error) {
+	if !s.isReviewer(ctx) {
+		return Inspection{}, ErrReviewerRequired
+	}
+
+	if request.Comment == nil || len(*request.Comment) == 0 {
+		return Inspection{}, ErrRejectCommentRequired
+	}
+
+	return s.review(ctx, request, StatusRejected, EventTypeRejected, nil, headers)
+}
+
+func (s *Service) isReviewer(ctx goctx.Context) bool {
+	return slices.Contains(s.access.Reviewers, ctx.Authorize.UserId)
+}
+
+// review moves a submitted inspection to the status chosen by the reviewer. The reviewer may not be the user
+// who submitted the inspection. The inspection becomes done right away when finalize, called under the lock
+// of the inspection, reports so.
+func (s *Service) review(ctx goctx.Context, request ReviewRequest, to Status, eventType EventType, finalize func() (bool, error),
+	headers file.ForwardedHeaders) (Inspection, error) {
+	var ins Inspection
+	err := s.repository.WithTx(ctx, func(tx Repository) error {
+		status, err := tx.GetStatusForUpdate(ctx, request.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", notFound(err, "inspection", request.ID))
+		}
+
+		history, err := tx.GetStatusHistory(ctx, request.ID)
+		if err != nil {
+			return fmt.Errorf("get status history: %w", err)
+		}
+
+		if userID, ok := submittedBy(history); ok && userID == ctx.Authorize.UserId {
+			return ErrSelfReview
+		}
+
+		if err = transition(ctx, tx, request.ID, status, to, ctx.Authorize.UserId, request.Comment); err != nil {
+			return err
+		}
+
+		if finalize != nil {
+			done, err := finalize()
+			if err != nil {
+				return err
+			}
+
+			if done {
+				if err = transition(ctx, tx, request.ID, to, StatusDone, ctx.Authorize.UserId, nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		ins, err = tx.GetByID(ctx, request.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection by id: %w", err)
+		}
+
+		event := newEvent(ctx, eventType, ins)
+		event.Comment = request.Comment
+
+		if err = tx.AddEvent(ctx, event); err != nil {
+			return fmt.Errorf("add review event: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	if err = s.fillAttachmentFileURLs(ctx, []Inspection{ins}, headers); err != nil {
+		return Inspection{}, fmt.Errorf("fill attachment file urls: %w", err)
+	}
+
+	return ins, nil
+}
+
+// submittedBy returns the user who submitted the inspection last according to its status history.
+func submittedBy(history []StatusChange) (int, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].To == StatusSubmitted {
+			return history[i].UserID, history[i].UserID != 0
+		}
+	}
+
+	return 0, false
+}
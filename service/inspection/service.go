@@ -1,15 +1,17 @@
 package inspection
 
 import (
-	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"inspection-service/cluster/brigade"
 	"inspection-service/cluster/file"
 	"inspection-service/cluster/subscriber"
 	"inspection-service/config"
 	"io"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/sunshineOfficial/golib/goctx"
@@ -18,57 +20,70 @@ import (
 	"github.com/sunshineOfficial/golib/pagination"
 )
 
-const kafkaSubscribeTimeout = 2 * time.Minute
+const (
+	kafkaSubscribeTimeout   = 2 * time.Minute
+	maxIdempotencyKeyLength = 255
+)
 
 type Service struct {
 	repository        Repository
-	publisher         *Publisher
 	analyzerService   AnalyzerService
 	subscriberService SubscriberService
 	fileService       FileService
 	taskService       TaskService
 	brigadeService    BrigadeService
 	templates         config.Templates
+	actTemplates      *actTemplateCache
+	pdfFont           *trueTypeFont
+	signer            *ActSigner
+	numbering         config.ActNumbering
+	access            config.Access
 }
 
-func NewService(repository Repository, publisher *Publisher, analyzerService AnalyzerService, subscriberService SubscriberService, fileService FileService,
-	taskService TaskService, brigadeService BrigadeService, templates config.Templates) *Service {
+func NewService(repository Repository, analyzerService AnalyzerService, subscriberService SubscriberService, fileService FileService,
+	taskService TaskService, brigadeService BrigadeService, templates config.Templates, signer *ActSigner,
+	numbering config.ActNumbering, access config.Access) *Service {
 	return &Service{
 		repository:        repository,
-		publisher:         publisher,
 		analyzerService:   analyzerService,
 		subscriberService: subscriberService,
 		fileService:       fileService,
 		taskService:       taskService,
 		brigadeService:    brigadeService,
 		templates:         templates,
+		actTemplates:      newActTemplateCache(),
+		signer:            signer,
+		numbering:         numbering,
+		access:            access,
 	}
 }
 
-func (s *Service) GetAll(ctx goctx.Context, page pagination.Pagination, sort SortDirection, headers file.ForwardedHeaders) ([]Inspection, error) {
-	if err := page.Validate(); err != nil {
-		return nil, fmt.Errorf("validate pagination: %w", err)
+func (s *Service) GetAll(ctx goctx.Context, filter Filter, page Page, headers file.ForwardedHeaders) (InspectionList, error) {
+	if err := filter.Validate(); err != nil {
+		return InspectionList{}, err
 	}
-	if err := sort.Validate(); err != nil {
-		return nil, fmt.Errorf("validate sort: %w", err)
+
+	page = page.Normalize()
+	if err := page.Validate(); err != nil {
+		return InspectionList{}, err
 	}
 
-	inspections, err := s.repository.GetAll(ctx, page, sort)
+	list, err := s.repository.GetAll(ctx, filter, page)
 	if err != nil {
-		return nil, fmt.Errorf("get all inspections: %w", err)
+		return InspectionList{}, fmt.Errorf("get all inspections: %w", err)
 	}
 
-	if err = s.fillAttachmentFileURLs(ctx, inspections, headers); err != nil {
-		return nil, fmt.Errorf("fill attachment file urls: %w", err)
+	if err = s.fillAttachmentFileURLs(ctx, list.Items, headers); err != nil {
+		return InspectionList{}, fmt.Errorf("fill attachment file urls: %w", err)
 	}
 
-	return inspections, nil
+	return list, nil
 }
 
 func (s *Service) GetByTaskID(ctx goctx.Context, taskID int, headers file.ForwardedHeaders) (Inspection, error) {
 	ins, err := s.repository.GetByTaskID(ctx, taskID)
 	if err != nil {
-		return Inspection{}, fmt.Errorf("get inspection by task id: %w", err)
+		return Inspection{}, fmt.Errorf("get inspection by task id: %w", notFound(err, "task_inspection", taskID))
 	}
 
 	if err = s.fillAttachmentFileURLs(ctx, []Inspection{ins}, headers); err != nil {
@@ -81,7 +96,7 @@ func (s *Service) GetByTaskID(ctx goctx.Context, taskID int, headers file.Forwar
 func (s *Service) GetByID(ctx goctx.Context, id int, headers file.ForwardedHeaders) (Inspection, error) {
 	ins, err := s.repository.GetByID(ctx, id)
 	if err != nil {
-		return Inspection{}, fmt.Errorf("get inspection by id: %w", err)
+		return Inspection{}, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", id))
 	}
 
 	if err = s.fillAttachmentFileURLs(ctx, []Inspection{ins}, headers); err != nil {
@@ -91,34 +106,60 @@ func (s *Service) GetByID(ctx goctx.Context, id int, headers file.ForwardedHeade
 	return ins, nil
 }
 
-func (s *Service) GetByBrigade(ctx goctx.Context, brigadeID int, page pagination.Pagination, headers file.ForwardedHeaders) ([]Inspection, error) {
+// GetByBrigade returns a page of inspections of all tasks assigned to the brigade.
+func (s *Service) GetByBrigade(ctx goctx.Context, brigadeID int, page Page, headers file.ForwardedHeaders) (InspectionList, error) {
+	page = page.Normalize()
 	if err := page.Validate(); err != nil {
-		return nil, fmt.Errorf("validate pagination: %w", err)
+		return InspectionList{}, err
 	}
 
-	tasks, err := s.taskService.GetTasksByBrigade(ctx, brigadeID, page)
+	tasks, err := s.taskService.GetTasksByBrigade(ctx, brigadeID, pagination.Pagination{})
 	if err != nil {
-		return nil, fmt.Errorf("get tasks by brigade id: %w", err)
+		return InspectionList{}, fmt.Errorf("get tasks by brigade id: %w", upstream("task-service", err))
 	}
 
-	inspections := make([]Inspection, 0, len(tasks))
+	taskIDs := make([]int, 0, len(tasks))
 	for _, t := range tasks {
-		ins, iErr := s.repository.GetByTaskID(ctx, t.ID)
-		if errors.Is(iErr, sql.ErrNoRows) {
-			continue
-		}
-		if iErr != nil {
-			return nil, fmt.Errorf("get inspection by task id %d: %w", t.ID, iErr)
-		}
+		taskIDs = append(taskIDs, t.ID)
+	}
+
+	list, err := s.repository.GetByTaskIDs(ctx, taskIDs, page)
+	if err != nil {
+		return InspectionList{}, fmt.Errorf("get inspections by task ids: %w", err)
+	}
 
-		inspections = append(inspections, ins)
+	if err = s.fillAttachmentFileURLs(ctx, list.Items, headers); err != nil {
+		return InspectionList{}, fmt.Errorf("fill attachment file urls: %w", err)
 	}
 
-	if err = s.fillAttachmentFileURLs(ctx, inspections, headers); err != nil {
-		return nil, fmt.Errorf("fill attachment file urls: %w", err)
+	return list, nil
+}
+
+// GetStats returns inspection counts grouped as requested along with the totals of all groups.
+func (s *Service) GetStats(ctx goctx.Context, request StatsRequest) (Stats, error) {
+	if err := request.Validate(); err != nil {
+		return Stats{}, err
 	}
 
-	return inspections, nil
+	groups, err := s.repository.GetStats(ctx, request)
+	if err != nil {
+		return Stats{}, fmt.Errorf("get stats: %w", err)
+	}
+
+	return newStats(groups), nil
+}
+
+func (s *Service) GetStatusHistory(ctx goctx.Context, id int) ([]StatusChange, error) {
+	if _, err := s.repository.GetByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", id))
+	}
+
+	history, err := s.repository.GetStatusHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get status history: %w", err)
+	}
+
+	return history, nil
 }
 
 func (s *Service) fillAttachmentFileURLs(ctx goctx.Context, inspections []Inspection, headers file.ForwardedHeaders) error {
@@ -141,7 +182,7 @@ func (s *Service) fillAttachmentFileURLs(ctx goctx.Context, inspections []Inspec
 
 	files, err := s.fileService.GetByIDs(ctx, fileIDs, pagination.Pagination{}, headers)
 	if err != nil {
-		return fmt.Errorf("get files by ids: %w", err)
+		return fmt.Errorf("get files by ids: %w", upstream("file-service", err))
 	}
 
 	urlsByID := make(map[int]string, len(files))
@@ -164,12 +205,15 @@ func (s *Service) fillAttachmentFileURLs(ctx goctx.Context, inspections []Inspec
 	return nil
 }
 
-func (s *Service) AttachPhoto(ctx goctx.Context, log golog.Logger, request AttachPhotoRequest) (Attachment, error) {
-	if request.Type != AttachmentTypeDevicePhoto && request.Type != AttachmentTypeSealPhoto {
-		return Attachment{}, fmt.Errorf("invalid attachment type: %d", request.Type)
+// attachPhoto checks the photo with the analyzer, uploads it and attaches it to the inspection.
+func (s *Service) attachPhoto(ctx goctx.Context, log golog.Logger, inspectionID int, photo Photo, headers file.ForwardedHeaders) (Attachment, error) {
+	if photo.Type != AttachmentTypeDevicePhoto && photo.Type != AttachmentTypeSealPhoto {
+		return Attachment{}, fmt.Errorf("%w: invalid attachment type: %d", ErrValidation, photo.Type)
 	}
 
-	f, err := request.FileHeader.Open()
+	// Large parts of the form are spooled to a temporary file, so the photo is read from it twice, by the analyzer
+	// and by the upload, instead of being copied to memory.
+	f, err := photo.FileHeader.Open()
 	if err != nil {
 		return Attachment{}, fmt.Errorf("open file: %w", err)
 	}
@@ -180,36 +224,33 @@ func (s *Service) AttachPhoto(ctx goctx.Context, log golog.Logger, request Attac
 		}
 	}()
 
-	var fileBuffer bytes.Buffer
-	_, err = io.Copy(&fileBuffer, f)
+	processedImage, err := s.analyzerService.ProcessImage(ctx, photo.FileHeader.Filename, io.NewSectionReader(f, 0, photo.FileHeader.Size))
 	if err != nil {
-		return Attachment{}, fmt.Errorf("copy file: %w", err)
-	}
-
-	processedImage, err := s.analyzerService.ProcessImage(ctx, request.FileHeader.Filename, bytes.NewReader(fileBuffer.Bytes()))
-	if err != nil {
-		return Attachment{}, fmt.Errorf("process image: %w", err)
+		return Attachment{}, fmt.Errorf("process image: %w", upstream("analyzer-service", err))
 	}
 
 	if processedImage.IsBlurred || processedImage.HasError {
-		return Attachment{}, ErrBlurredPhoto
+		return Attachment{}, BlurredPhotoError{
+			BlurScore:    processedImage.BlurScore,
+			QualityScore: processedImage.QualityScore,
+		}
 	}
 
 	var object subscriber.Object
-	switch request.Type {
+	switch photo.Type {
 	case AttachmentTypeDevicePhoto:
-		object, err = s.subscriberService.GetObjectByDeviceID(ctx, request.DeviceID)
+		object, err = s.subscriberService.GetObjectByDeviceID(ctx, photo.DeviceID)
 	case AttachmentTypeSealPhoto:
-		object, err = s.subscriberService.GetObjectBySealID(ctx, request.SealID)
+		object, err = s.subscriberService.GetObjectBySealID(ctx, photo.SealID)
 	default:
-		return Attachment{}, fmt.Errorf("invalid attachment type: %d", request.Type)
+		return Attachment{}, fmt.Errorf("%w: invalid attachment type: %d", ErrValidation, photo.Type)
 	}
 
 	if err != nil {
-		return Attachment{}, fmt.Errorf("get object: %w", err)
+		return Attachment{}, fmt.Errorf("get object: %w", upstream("subscriber-service", err))
 	}
 
-	number, err := attachmentNumber(request, object)
+	number, err := attachmentNumber(photo, object)
 	if err != nil {
 		return Attachment{}, fmt.Errorf("get attachment number: %w", err)
 	}
@@ -217,20 +258,44 @@ func (s *Service) AttachPhoto(ctx goctx.Context, log golog.Logger, request Attac
 	fileName := fmt.Sprintf(
 		"%s - %s №%s от %s%s",
 		object.Address,
-		attachmentName(request.Type),
+		attachmentName(photo.Type),
 		number,
 		gotime.MoscowNow().Format("02.01.2006 15.04.05"),
-		filepath.Ext(request.FileHeader.Filename),
+		filepath.Ext(photo.FileHeader.Filename),
 	)
 
-	uploadedFile, err := s.fileService.Upload(ctx, fileName, bytes.NewReader(fileBuffer.Bytes()), request.FileHeaders)
+	uploadedFile, err := s.fileService.Upload(ctx, fileName, io.NewSectionReader(f, 0, photo.FileHeader.Size), headers)
 	if err != nil {
-		return Attachment{}, fmt.Errorf("upload file: %w", err)
+		return Attachment{}, fmt.Errorf("upload file: %w", upstream("file-service", err))
 	}
 
-	attachment, err := s.repository.AddAttachment(ctx, request.InspectionID, uploadedFile.ID, request.Type)
+	// The status is checked again under the lock of the inspection, so a photo processed while the inspection
+	// was submitted is not attached to it.
+	var attachment Attachment
+	err = s.repository.WithTx(ctx, func(tx Repository) error {
+		status, err := tx.GetStatusForUpdate(ctx, inspectionID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", notFound(err, "inspection", inspectionID))
+		}
+
+		if !status.IsEditable() {
+			return ErrNotEditable
+		}
+
+		attachment, err = tx.AddAttachment(ctx, Attachment{
+			InspectionID: inspectionID,
+			Type:         photo.Type,
+			FileID:       uploadedFile.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("add attachment: %w", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return Attachment{}, fmt.Errorf("add attachment: %w", err)
+		log.Warnf("photo file %d is not attached to any inspection", uploadedFile.ID)
+		return Attachment{}, err
 	}
 
 	attachment.FileURL = uploadedFile.URL
@@ -249,115 +314,331 @@ func attachmentName(t AttachmentType) string {
 	}
 }
 
-func attachmentNumber(request AttachPhotoRequest, object subscriber.Object) (string, error) {
-	switch request.Type {
+func attachmentNumber(photo Photo, object subscriber.Object) (string, error) {
+	switch photo.Type {
 	case AttachmentTypeDevicePhoto:
 		for _, device := range object.Devices {
-			if device.ID == request.DeviceID {
+			if device.ID == photo.DeviceID {
 				return device.Number, nil
 			}
 		}
 
-		return "", fmt.Errorf("device %d not found", request.DeviceID)
+		return "", NotFoundError{Entity: "device", ID: photo.DeviceID}
 
 	case AttachmentTypeSealPhoto:
 		for _, device := range object.Devices {
 			for _, seal := range device.Seals {
-				if seal.ID == request.SealID {
+				if seal.ID == photo.SealID {
 					return seal.Number, nil
 				}
 			}
 		}
 
-		return "", fmt.Errorf("seal %d not found", request.SealID)
+		return "", NotFoundError{Entity: "seal", ID: photo.SealID}
 
 	default:
-		return "", fmt.Errorf("invalid attachment type: %d", request.Type)
+		return "", fmt.Errorf("%w: invalid attachment type: %d", ErrValidation, photo.Type)
 	}
 }
 
 func (s *Service) FinishInspection(ctx goctx.Context, log golog.Logger, request FinishInspectionRequest, headers file.ForwardedHeaders) (file.File, error) {
+	if len(request.IdempotencyKey) > maxIdempotencyKeyLength {
+		return file.File{}, ErrIdempotencyKeyTooLong
+	}
+
+	replayed, ok, err := s.replayFinish(ctx, request, headers)
+	if err != nil {
+		return file.File{}, fmt.Errorf("replay finish: %w", err)
+	}
+	if ok {
+		return replayed, nil
+	}
+
 	ins, err := s.repository.GetByID(ctx, request.ID)
 	if err != nil {
-		return file.File{}, fmt.Errorf("get inspection by id: %w", err)
+		return file.File{}, fmt.Errorf("get inspection by id: %w", notFound(err, "inspection", request.ID))
 	}
 
-	tsk, err := s.taskService.GetTaskByID(ctx, ins.TaskID)
+	if !ins.Status.CanTransitionTo(StatusSubmitted) {
+		return file.File{}, TransitionError{From: ins.Status, To: StatusSubmitted}
+	}
+
+	if len(request.IdempotencyKey) == 0 {
+		return s.finish(ctx, log, ins, request, headers)
+	}
+
+	// The key is reserved before the act number is assigned and the act is uploaded, so a concurrent retry
+	// waits for the response instead of repeating them.
+	reserved, err := s.repository.ReserveIdempotencyKey(ctx, request.IdempotencyKey, ins.ID)
 	if err != nil {
-		return file.File{}, fmt.Errorf("get task by id: %w", err)
+		return file.File{}, fmt.Errorf("reserve idempotency key: %w", err)
 	}
+	if !reserved {
+		replayed, ok, err = s.replayFinish(ctx, request, headers)
+		if err != nil {
+			return file.File{}, fmt.Errorf("replay finish: %w", err)
+		}
+		if ok {
+			return replayed, nil
+		}
 
-	if tsk.BrigadeID == nil {
-		return file.File{}, fmt.Errorf("task %d has no brigade", ins.TaskID)
+		return file.File{}, ErrIdempotencyKeyInUse
 	}
 
-	brig, err := s.brigadeService.GetBrigadeByID(ctx, *tsk.BrigadeID)
+	uploadedFile, err := s.finish(ctx, log, ins, request, headers)
 	if err != nil {
-		return file.File{}, fmt.Errorf("get brigade by id: %w", err)
+		// The finish is rolled back, so a retry with the key may start it again.
+		if releaseErr := s.repository.ReleaseIdempotencyKey(ctx, request.IdempotencyKey); releaseErr != nil {
+			log.Errorf("failed to release idempotency key: %v", releaseErr)
+		}
+
+		return file.File{}, err
 	}
 
-	contract, err := s.subscriberService.GetLastContractByObjectID(ctx, tsk.ObjectID)
+	return uploadedFile, nil
+}
+
+// finish submits the inspection with a new act and completes the idempotency key reserved for the request.
+// The act is numbered and uploaded before the transaction that submits the inspection, so neither the counter
+// of the series nor the inspection is locked while the files are uploaded.
+func (s *Service) finish(ctx goctx.Context, log golog.Logger, ins Inspection, request FinishInspectionRequest, headers file.ForwardedHeaders) (file.File, error) {
+	draft, err := s.draftAct(ctx, ins, &request)
 	if err != nil {
-		return file.File{}, fmt.Errorf("get contract by object id: %w", err)
+		return file.File{}, err
 	}
 
-	if len(contract.Object.Devices) == 0 {
-		return file.File{}, errors.New("no devices found")
+	template, err := s.actTemplate(ctx, request.Type)
+	if err != nil {
+		return file.File{}, fmt.Errorf("get act template: %w", err)
+	}
+
+	uploads, err := s.repository.GetActUploads(ctx, ins.ID)
+	if err != nil {
+		return file.File{}, fmt.Errorf("get act uploads: %w", err)
 	}
 
-	var buf *bytes.Buffer
-	switch request.Type {
-	case TypeLimitation, TypeResumption:
-		buf, err = s.generateUniversalAct(request, brig, contract)
-	case TypeVerification, TypeUnauthorizedConnection:
-		devices, dErr := s.repository.GetPreviousDeviceInspections(ctx, contract.Object.Devices[0].ID, request.ID)
-		if dErr != nil {
-			return file.File{}, fmt.Errorf("get device inspections: %w", dErr)
+	// A retry draws the act up at the time of the failed attempt, so an unchanged act has the content of the files
+	// that attempt uploaded, and they are attached instead of new ones.
+	if i := slices.IndexFunc(uploads, func(u ActUpload) bool { return u.Reason == nil }); i >= 0 {
+		draft.now = uploads[i].DrawnAt.In(gotime.Moscow)
+	}
+
+	number, err := s.reserveActNumber(ctx, ins, request.Type, draft.now)
+	if err != nil {
+		return file.File{}, err
+	}
+
+	a, actName, err := draft.act(number)
+	if err != nil {
+		return file.File{}, err
+	}
+
+	upload := ActUpload{InspectionID: ins.ID, DrawnAt: draft.now}
+	acts, err := s.uploadActs(ctx, log, a, template, actName, request.actFormats(), upload, headers)
+	if err != nil {
+		return file.File{}, err
+	}
+
+	// The first requested format is the response, the other files are reachable through the attachments.
+	uploadedFile := acts[0].file
+
+	err = s.repository.WithTx(ctx, func(tx Repository) error {
+		status, err := tx.GetStatusForUpdate(ctx, ins.ID)
+		if err != nil {
+			return fmt.Errorf("get inspection status: %w", err)
 		}
 
-		buf, err = s.generateControlAct(request, brig, contract, devices)
-	default:
-		return file.File{}, fmt.Errorf("invalid inspection type: %d", request.Type)
+		if err = transition(ctx, tx, ins.ID, status, StatusSubmitted, ctx.Authorize.UserId, nil); err != nil {
+			return err
+		}
+
+		// A rejected inspection is resubmitted with new readings and a new act, which replace the previous ones.
+		if status == StatusRejected {
+			if err = tx.DeleteInspectedDevices(ctx, ins.ID); err != nil {
+				return fmt.Errorf("delete inspected devices: %w", err)
+			}
+
+			if err = tx.SupersedeActs(ctx, ins.ID); err != nil {
+				return fmt.Errorf("supersede acts: %w", err)
+			}
+		}
+
+		if err = tx.ReplaceSignatures(ctx, ins.ID, request.Signatures); err != nil {
+			return fmt.Errorf("replace signatures: %w", err)
+		}
+
+		if _, err = addActAttachments(ctx, tx, ins.ID, acts, nil); err != nil {
+			return err
+		}
+
+		if err = tx.SettleActUploads(ctx, ins.ID); err != nil {
+			return fmt.Errorf("settle act uploads: %w", err)
+		}
+
+		if err = tx.AddInspectedDevices(ctx, ins.ID, request.InspectedDevices); err != nil {
+			return fmt.Errorf("add inspected devices: %w", err)
+		}
+
+		ins, err = tx.FinishInspection(ctx, request)
+		if err != nil {
+			return fmt.Errorf("finish inspection: %w", err)
+		}
+
+		if err = tx.AddEvent(ctx, newEvent(ctx, EventTypeFinish, ins)); err != nil {
+			return fmt.Errorf("add finish event: %w", err)
+		}
+
+		if len(request.IdempotencyKey) == 0 {
+			return nil
+		}
+
+		response, err := json.Marshal(uploadedFile)
+		if err != nil {
+			return fmt.Errorf("marshal response: %w", err)
+		}
+
+		err = tx.CompleteIdempotencyKey(ctx, IdempotencyKey{
+			Key:          request.IdempotencyKey,
+			InspectionID: ins.ID,
+			FileID:       uploadedFile.ID,
+			Response:     response,
+		})
+		if err != nil {
+			return fmt.Errorf("complete idempotency key: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		// The act files stay recorded as uploads of the inspection for the retry.
+		return file.File{}, err
 	}
 
+	return uploadedFile, nil
+}
+
+// actDraft is the act of an inspection drawn up now, filled with everything but its number.
+type actDraft struct {
+	request  FinishInspectionRequest
+	brigade  brigade.Brigade
+	contract subscriber.Contract
+	previous map[int][]InspectedDevice
+	now      time.Time
+}
+
+// draftAct validates the request and computes consumption of the inspected devices for the act of the inspection
+// drawn up now. Nothing is stored.
+func (s *Service) draftAct(ctx goctx.Context, ins Inspection, request *FinishInspectionRequest) (actDraft, error) {
+	brig, contract, err := s.actParties(ctx, ins)
 	if err != nil {
-		return file.File{}, fmt.Errorf("generate act: %w", err)
+		return actDraft{}, err
 	}
 
-	actType := "о введении ограничения и возобновления"
-	if request.Type == TypeVerification || request.Type == TypeUnauthorizedConnection {
-		actType = "контроля"
+	if err = request.Validate(contract.Object); err != nil {
+		return actDraft{}, err
 	}
 
-	actName := fmt.Sprintf(
-		"Акт %s №%d от %s (%s).docx",
-		actType,
-		request.ID,
-		gotime.MoscowNow().Format(gotime.DateOnlyNet),
-		contract.Object.Address,
-	)
+	previous, err := s.previousReadings(ctx, *request)
+	if err != nil {
+		return actDraft{}, err
+	}
+
+	if err = request.computeConsumption(previous); err != nil {
+		return actDraft{}, err
+	}
+
+	return actDraft{
+		request:  *request,
+		brigade:  brig,
+		contract: contract,
+		previous: previous,
+		now:      gotime.MoscowNow(),
+	}, nil
+}
 
-	uploadedFile, err := s.fileService.Upload(ctx, actName, buf, headers)
+// act fills the drafted act with the number and returns it with its file name without extension.
+func (d actDraft) act(number string) (act, string, error) {
+	return newAct(d.request, number, d.brigade, d.contract, d.previous, d.now)
+}
+
+// actParties returns the brigade of the inspection task and the last contract of its object.
+func (s *Service) actParties(ctx goctx.Context, ins Inspection) (brigade.Brigade, subscriber.Contract, error) {
+	tsk, err := s.taskService.GetTaskByID(ctx, ins.TaskID)
 	if err != nil {
-		return file.File{}, fmt.Errorf("upload file: %w", err)
+		return brigade.Brigade{}, subscriber.Contract{}, fmt.Errorf("get task by id: %w", upstream("task-service", err))
+	}
+
+	if tsk.BrigadeID == nil {
+		return brigade.Brigade{}, subscriber.Contract{}, fmt.Errorf("%w: task %d has no brigade", ErrConflict, ins.TaskID)
 	}
 
-	_, err = s.repository.AddAttachment(ctx, ins.ID, uploadedFile.ID, AttachmentTypeAct)
+	brig, err := s.brigadeService.GetBrigadeByID(ctx, *tsk.BrigadeID)
 	if err != nil {
-		return file.File{}, fmt.Errorf("add attachment: %w", err)
+		return brigade.Brigade{}, subscriber.Contract{}, fmt.Errorf("get brigade by id: %w", upstream("brigade-service", err))
 	}
 
-	err = s.repository.AddInspectedDevices(ctx, ins.ID, request.InspectedDevices)
+	contract, err := s.subscriberService.GetLastContractByObjectID(ctx, tsk.ObjectID)
 	if err != nil {
-		return file.File{}, fmt.Errorf("add inspected devices: %w", err)
+		return brigade.Brigade{}, subscriber.Contract{}, fmt.Errorf("get contract by object id: %w", upstream("subscriber-service", err))
 	}
 
-	ins, err = s.repository.FinishInspection(ctx, request)
+	return brig, contract, nil
+}
+
+// previousReadings returns readings of the inspected devices taken in other inspections, newest first.
+func (s *Service) previousReadings(ctx goctx.Context, request FinishInspectionRequest) (map[int][]InspectedDevice, error) {
+	previous := make(map[int][]InspectedDevice, len(request.InspectedDevices))
+	for _, d := range request.InspectedDevices {
+		devices, err := s.repository.GetPreviousDeviceInspections(ctx, request.ID, d.DeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("get device inspections: %w", err)
+		}
+
+		previous[d.DeviceID] = devices
+	}
+
+	return previous, nil
+}
+
+// replayFinish returns the stored response of a previous finish made with the same idempotency key; keys reserved
+// by a finish that has not completed are not replayed. The file URL is resolved again, because the stored one
+// may no longer be valid.
+func (s *Service) replayFinish(ctx goctx.Context, request FinishInspectionRequest, headers file.ForwardedHeaders) (file.File, bool, error) {
+	if len(request.IdempotencyKey) == 0 {
+		return file.File{}, false, nil
+	}
+
+	key, err := s.repository.GetIdempotencyKey(ctx, request.IdempotencyKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return file.File{}, false, nil
+	}
 	if err != nil {
-		return file.File{}, fmt.Errorf("finish inspection: %w", err)
+		return file.File{}, false, fmt.Errorf("get idempotency key: %w", err)
 	}
 
-	go s.publisher.Publish(ctx, log, EventTypeFinish, ins)
+	if key.InspectionID != request.ID {
+		return file.File{}, false, ErrIdempotencyKeyMismatch
+	}
 
-	return uploadedFile, nil
+	// A reserved key is claimed by ReserveIdempotencyKey, which takes over reservations left by crashed requests.
+	if len(key.Response) == 0 {
+		return file.File{}, false, nil
+	}
+
+	var response file.File
+	if err = json.Unmarshal(key.Response, &response); err != nil {
+		return file.File{}, false, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	files, err := s.fileService.GetByIDs(ctx, []int{key.FileID}, pagination.Pagination{}, headers)
+	if err != nil {
+		return file.File{}, false, fmt.Errorf("get files by ids: %w", upstream("file-service", err))
+	}
+	if len(files) == 0 {
+		return file.File{}, false, fmt.Errorf("file %d not found", key.FileID)
+	}
+
+	response.URL = files[0].URL
+
+	return response, true, nil
 }
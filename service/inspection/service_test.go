@@ -1,15 +1,31 @@
 package inspection
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	clusterbrigade "inspection-service/cluster/brigade"
 	clusterfile "inspection-service/cluster/file"
+	clustersubscriber "inspection-service/cluster/subscriber"
 	clustertask "inspection-service/cluster/task"
+	"inspection-service/config"
 
+	"github.com/shopspring/decimal"
 	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
 	"github.com/sunshineOfficial/golib/pagination"
 )
 
@@ -17,14 +33,81 @@ type repositoryMock struct {
 	inspections         []Inspection
 	inspectionsByTaskID map[int]Inspection
 	inspectionsByID     map[int]Inspection
-	gotSort             SortDirection
+	gotPage             Page
+	gotTaskIDs          []int
+	pageCalls           int
+	gotStatsRequest     StatsRequest
+	statsGroups         []StatsGroup
+	gotFilter           Filter
 	getAllCalled        bool
+	events              []Event
+	pendingEvents       []OutboxEvent
+	sentEvents          []int
+	failedEvents        []int
+	finishErr           error
+	supersedeErr        error
+	idempotencyKeys     map[string]IdempotencyKey
+	statusChanges       []StatusChange
+	deletedDevices      bool
+	attachmentTypes     []AttachmentType
+	actTemplateIDs      []*int
+	actTemplates        []ActTemplate
+	contentLoads        int
+	attachments         []Attachment
+	supersededActs      []int
+	previousReadings    []InspectedDevice
+	signatures          []Signature
+	actNumberCounters   map[ActNumber]int
+	actUploads          []ActUpload
+	abandonedUploads    []ActUpload
+	inTx                bool
+	numberedOutsideTx   bool
+	withoutBrigade      []Inspection
+	brigades            map[int]int
 }
 
-func (m *repositoryMock) GetAll(_ context.Context, _ pagination.Pagination, sort SortDirection) ([]Inspection, error) {
+// txMu runs the transactions of the repository mock one at a time, like row locks of concurrent requests.
+var txMu sync.Mutex
+
+// WithTx rolls back act numbers, act uploads and attachments when fn fails, like the database does; other changes are kept.
+func (m *repositoryMock) WithTx(_ context.Context, fn func(tx Repository) error) error {
+	txMu.Lock()
+	defer txMu.Unlock()
+
+	uploads, abandoned := slices.Clone(m.actUploads), slices.Clone(m.abandonedUploads)
+	mocksMu.Lock()
+	attachments := slices.Clone(m.attachments)
+	mocksMu.Unlock()
+	counters := maps.Clone(m.actNumberCounters)
+	numbers := make(map[int]*ActNumber, len(m.inspectionsByID))
+	for id, ins := range m.inspectionsByID {
+		numbers[id] = ins.ActNumber
+	}
+
+	m.inTx = true
+	err := fn(m)
+	m.inTx = false
+
+	if err != nil {
+		m.actUploads, m.abandonedUploads = uploads, abandoned
+		mocksMu.Lock()
+		m.attachments = attachments
+		mocksMu.Unlock()
+		m.actNumberCounters = counters
+		for id, ins := range m.inspectionsByID {
+			ins.ActNumber = numbers[id]
+			m.inspectionsByID[id] = ins
+		}
+	}
+
+	return err
+}
+
+func (m *repositoryMock) GetAll(_ context.Context, filter Filter, page Page) (InspectionList, error) {
 	m.getAllCalled = true
-	m.gotSort = sort
-	return m.inspections, nil
+	m.gotFilter = filter
+	m.gotPage = page
+	return InspectionList{Items: m.inspections, Total: len(m.inspections)}, nil
 }
 
 func (m repositoryMock) GetByTaskID(_ context.Context, taskID int) (Inspection, error) {
@@ -36,8 +119,229 @@ func (m repositoryMock) GetByTaskID(_ context.Context, taskID int) (Inspection,
 	return ins, nil
 }
 
-func (m repositoryMock) AddAttachment(context.Context, int, int, AttachmentType) (Attachment, error) {
-	return Attachment{}, nil
+// GetPage pages m.inspections, which are expected to be sorted by ID.
+func (m *repositoryMock) GetPage(_ context.Context, filter Filter, page Page) (InspectionList, error) {
+	m.gotFilter = filter
+	m.pageCalls++
+
+	after, err := page.After()
+	if err != nil {
+		return InspectionList{}, err
+	}
+
+	list := InspectionList{Items: []Inspection{}}
+	for _, ins := range m.inspections {
+		if after != nil && ins.ID <= after.ID {
+			continue
+		}
+		if len(list.Items) == page.Limit {
+			list.NextCursor = page.Next(list.Items[len(list.Items)-1])
+			break
+		}
+
+		list.Items = append(list.Items, ins)
+	}
+
+	return list, nil
+}
+
+// GetByTaskIDs orders inspections by ID in the page direction and applies Offset and Limit like the repository.
+func (m *repositoryMock) GetByTaskIDs(_ context.Context, taskIDs []int, page Page) (InspectionList, error) {
+	m.gotTaskIDs = taskIDs
+	m.gotPage = page
+
+	items := []Inspection{}
+	for _, taskID := range taskIDs {
+		if ins, ok := m.inspectionsByTaskID[taskID]; ok {
+			items = append(items, ins)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b Inspection) int {
+		if page.Direction == SortDesc {
+			return b.ID - a.ID
+		}
+
+		return a.ID - b.ID
+	})
+
+	list := InspectionList{Total: len(items)}
+	items = items[min(page.Offset, len(items)):]
+	if page.Limit > 0 {
+		items = items[:min(page.Limit, len(items))]
+	}
+	list.Items = items
+
+	return list, nil
+}
+
+func (m repositoryMock) GetWithoutBrigade(_ context.Context, afterID, limit int) ([]Inspection, error) {
+	var inspections []Inspection
+	for _, ins := range m.withoutBrigade {
+		if ins.ID > afterID && len(inspections) < limit {
+			inspections = append(inspections, ins)
+		}
+	}
+
+	return inspections, nil
+}
+
+func (m *repositoryMock) SetBrigade(_ context.Context, inspectionID, brigadeID int) error {
+	if m.brigades == nil {
+		m.brigades = make(map[int]int)
+	}
+
+	m.brigades[inspectionID] = brigadeID
+	return nil
+}
+
+func (m *repositoryMock) GetStats(_ context.Context, request StatsRequest) ([]StatsGroup, error) {
+	m.gotStatsRequest = request
+	return m.statsGroups, nil
+}
+
+// mocksMu guards the mocks called by the workers of a photo batch.
+var mocksMu sync.Mutex
+
+func (m *repositoryMock) AddAttachment(_ context.Context, attachment Attachment) (Attachment, error) {
+	mocksMu.Lock()
+	defer mocksMu.Unlock()
+
+	m.attachmentTypes = append(m.attachmentTypes, attachment.Type)
+	m.actTemplateIDs = append(m.actTemplateIDs, attachment.ActTemplateID)
+	m.attachments = append(m.attachments, attachment)
+	return attachment, nil
+}
+
+func (m *repositoryMock) ReplaceSignatures(_ context.Context, _ int, signatures []Signature) error {
+	m.signatures = signatures
+	return nil
+}
+
+func (m repositoryMock) GetSignatures(context.Context, int) ([]Signature, error) {
+	return m.signatures, nil
+}
+
+func (m *repositoryMock) SupersedeActs(_ context.Context, inspectionID int) error {
+	if m.supersedeErr != nil {
+		return m.supersedeErr
+	}
+
+	m.supersededActs = append(m.supersededActs, inspectionID)
+	return nil
+}
+
+func (m repositoryMock) GetActsBySHA256(_ context.Context, sha256 string) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, a := range m.attachments {
+		if a.SHA256 == sha256 {
+			attachments = append(attachments, a)
+		}
+	}
+
+	return attachments, nil
+}
+
+func (m repositoryMock) GetAttachmentByFileID(_ context.Context, fileID int) (Attachment, error) {
+	for _, a := range m.attachments {
+		if a.FileID == fileID {
+			return a, nil
+		}
+	}
+
+	return Attachment{}, sql.ErrNoRows
+}
+
+func (m *repositoryMock) AddActUpload(_ context.Context, upload ActUpload) error {
+	upload.CreatedAt = time.Now()
+	m.actUploads = append(m.actUploads, upload)
+	return nil
+}
+
+func (m repositoryMock) GetActUploads(_ context.Context, inspectionID int) ([]ActUpload, error) {
+	var uploads []ActUpload
+	for _, u := range m.actUploads {
+		if u.InspectionID == inspectionID {
+			uploads = append(uploads, u)
+		}
+	}
+
+	return uploads, nil
+}
+
+func (m *repositoryMock) SettleActUploads(_ context.Context, inspectionID int) error {
+	m.actUploads = slices.DeleteFunc(m.actUploads, func(u ActUpload) bool {
+		if u.InspectionID != inspectionID {
+			return false
+		}
+
+		attached := slices.ContainsFunc(m.attachments, func(a Attachment) bool { return a.FileID == u.FileID })
+		if !attached {
+			m.abandonedUploads = append(m.abandonedUploads, u)
+		}
+
+		return true
+	})
+
+	return nil
+}
+
+func (m *repositoryMock) AssignActNumber(_ context.Context, inspectionID int, series ActNumber) (ActNumber, error) {
+	m.numberedOutsideTx = m.numberedOutsideTx || !m.inTx
+
+	ins := m.inspectionsByID[inspectionID]
+	if ins.ActNumber == nil || !ins.ActNumber.sameSeries(series) {
+		if m.actNumberCounters == nil {
+			m.actNumberCounters = make(map[ActNumber]int)
+		}
+
+		m.actNumberCounters[series]++
+		series.Number = m.actNumberCounters[series]
+		ins.ActNumber = &series
+		m.inspectionsByID[inspectionID] = ins
+	}
+
+	return *ins.ActNumber, nil
+}
+
+func (m *repositoryMock) GetLatestActTemplate(_ context.Context, inspectionType Type) (ActTemplate, error) {
+	for _, template := range slices.Backward(m.actTemplates) {
+		if template.Type == inspectionType {
+			template.Content = nil
+			return template, nil
+		}
+	}
+
+	return ActTemplate{}, sql.ErrNoRows
+}
+
+func (m *repositoryMock) GetActTemplateContent(_ context.Context, id int) ([]byte, error) {
+	m.contentLoads++
+	for _, template := range m.actTemplates {
+		if template.ID == id {
+			return template.Content, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m *repositoryMock) GetActTemplates(context.Context) ([]ActTemplate, error) {
+	return m.actTemplates, nil
+}
+
+func (m *repositoryMock) AddActTemplate(_ context.Context, template ActTemplate) (ActTemplate, error) {
+	for _, t := range m.actTemplates {
+		if t.Type == template.Type {
+			template.Version = max(template.Version, t.Version)
+		}
+	}
+
+	template.ID = len(m.actTemplates) + 1
+	template.Version++
+	m.actTemplates = append(m.actTemplates, template)
+
+	return template, nil
 }
 
 func (m repositoryMock) GetByID(_ context.Context, id int) (Inspection, error) {
@@ -49,29 +353,154 @@ func (m repositoryMock) GetByID(_ context.Context, id int) (Inspection, error) {
 	return ins, nil
 }
 
-func (m repositoryMock) GetPreviousDeviceInspections(context.Context, int, int) ([]InspectedDevice, error) {
-	return nil, nil
+func (m repositoryMock) GetPreviousDeviceInspections(_ context.Context, inspectionID, deviceID int) ([]InspectedDevice, error) {
+	var readings []InspectedDevice
+	for _, reading := range m.previousReadings {
+		if reading.DeviceID == deviceID && reading.InspectionID != inspectionID {
+			readings = append(readings, reading)
+		}
+	}
+
+	return readings, nil
 }
 
 func (m repositoryMock) AddInspectedDevices(context.Context, int, []InspectedDeviceRequest) error {
 	return nil
 }
 
-func (m repositoryMock) StartInspection(context.Context, int) (Inspection, error) {
-	return Inspection{}, nil
+func (m *repositoryMock) DeleteInspectedDevices(context.Context, int) error {
+	m.deletedDevices = true
+	return nil
+}
+
+func (m repositoryMock) StartInspection(_ context.Context, taskID int, _ *int) (Inspection, error) {
+	return Inspection{TaskID: taskID, Status: StatusInWork}, nil
+}
+
+func (m repositoryMock) FinishInspection(_ context.Context, request FinishInspectionRequest) (Inspection, error) {
+	if m.finishErr != nil {
+		return Inspection{}, m.finishErr
+	}
+
+	return m.inspectionsByID[request.ID], nil
+}
+
+func (m *repositoryMock) AddEvent(_ context.Context, event Event) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+// GetPendingEvents follows the contract of get_pending_events.sql: an unsent event is returned only when it is due
+// and no earlier event of its inspection is unsent. Failed events back off until the end of the test.
+func (m repositoryMock) GetPendingEvents(_ context.Context, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	waiting := make(map[int]bool)
+	for _, event := range m.pendingEvents {
+		if slices.Contains(m.sentEvents, event.ID) {
+			continue
+		}
+
+		if !waiting[event.InspectionID] && !slices.Contains(m.failedEvents, event.ID) && len(events) < limit {
+			events = append(events, event)
+		}
+
+		waiting[event.InspectionID] = true
+	}
+
+	return events, nil
+}
+
+func (m *repositoryMock) MarkEventSent(_ context.Context, id int) error {
+	m.sentEvents = append(m.sentEvents, id)
+	return nil
+}
+
+func (m *repositoryMock) MarkEventFailed(_ context.Context, id int, _ string) error {
+	m.failedEvents = append(m.failedEvents, id)
+	return nil
+}
+
+func (m repositoryMock) GetStatusForUpdate(_ context.Context, id int) (Status, error) {
+	ins, ok := m.inspectionsByID[id]
+	if !ok {
+		return StatusUnknown, sql.ErrNoRows
+	}
+
+	return ins.Status, nil
+}
+
+func (m repositoryMock) GetIdempotencyKey(_ context.Context, key string) (IdempotencyKey, error) {
+	k, ok := m.idempotencyKeys[key]
+	if !ok {
+		return IdempotencyKey{}, sql.ErrNoRows
+	}
+
+	return k, nil
+}
+
+func (m repositoryMock) UpdateStatus(_ context.Context, id int, status Status) error {
+	ins := m.inspectionsByID[id]
+	ins.Status = status
+	m.inspectionsByID[id] = ins
+
+	for taskID, byTask := range m.inspectionsByTaskID {
+		if byTask.ID == id {
+			byTask.Status = status
+			m.inspectionsByTaskID[taskID] = byTask
+		}
+	}
+
+	return nil
+}
+
+func (m *repositoryMock) AddStatusChange(_ context.Context, change StatusChange) error {
+	m.statusChanges = append(m.statusChanges, change)
+	return nil
+}
+
+func (m repositoryMock) GetStatusHistory(context.Context, int) ([]StatusChange, error) {
+	return m.statusChanges, nil
 }
 
-func (m repositoryMock) FinishInspection(context.Context, FinishInspectionRequest) (Inspection, error) {
-	return Inspection{}, nil
+// ReserveIdempotencyKey follows reserve_idempotency_key.sql: a reservation of the inspection left without
+// a response for 10 minutes is taken over.
+func (m *repositoryMock) ReserveIdempotencyKey(_ context.Context, key string, inspectionID int) (bool, error) {
+	if k, ok := m.idempotencyKeys[key]; ok {
+		expired := len(k.Response) == 0 && k.InspectionID == inspectionID && time.Since(k.CreatedAt) > 10*time.Minute
+		if !expired {
+			return false, nil
+		}
+	}
+	if m.idempotencyKeys == nil {
+		m.idempotencyKeys = make(map[string]IdempotencyKey)
+	}
+
+	m.idempotencyKeys[key] = IdempotencyKey{Key: key, InspectionID: inspectionID, CreatedAt: time.Now()}
+	return true, nil
+}
+
+func (m *repositoryMock) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	if k, ok := m.idempotencyKeys[key]; ok && len(k.Response) == 0 {
+		delete(m.idempotencyKeys, key)
+	}
+
+	return nil
+}
+
+func (m *repositoryMock) CompleteIdempotencyKey(_ context.Context, key IdempotencyKey) error {
+
+	m.idempotencyKeys[key.Key] = key
+	return nil
 }
 
 type taskServiceMock struct {
+	tasksByID        map[int]clustertask.Task
 	tasksByBrigadeID map[int][]clustertask.Task
 	gotPage          pagination.Pagination
 }
 
-func (m taskServiceMock) GetTaskByID(goctx.Context, int) (clustertask.Task, error) {
-	return clustertask.Task{}, nil
+func (m taskServiceMock) GetTaskByID(_ goctx.Context, id int) (clustertask.Task, error) {
+	return m.tasksByID[id], nil
 }
 
 func (m *taskServiceMock) GetTasksByBrigade(_ goctx.Context, brigadeID int, page pagination.Pagination) ([]clustertask.Task, error) {
@@ -81,13 +510,71 @@ func (m *taskServiceMock) GetTasksByBrigade(_ goctx.Context, brigadeID int, page
 }
 
 type fileServiceMock struct {
-	filesByID  map[int]clusterfile.File
-	gotIDs     []int
-	gotHeaders clusterfile.ForwardedHeaders
+	filesByID      map[int]clusterfile.File
+	contentsByURL  map[string][]byte
+	gotIDs         []int
+	gotHeaders     clusterfile.ForwardedHeaders
+	uploadedNames  []string
+	uploaded       [][]byte
+	discardContent bool
+}
+
+type subscriberServiceMock struct {
+	contract clustersubscriber.Contract
+}
+
+func (m subscriberServiceMock) GetLastContractByObjectID(goctx.Context, int) (clustersubscriber.Contract, error) {
+	return m.contract, nil
+}
+
+func (m subscriberServiceMock) GetObjectByDeviceID(goctx.Context, int) (clustersubscriber.Object, error) {
+	return m.contract.Object, nil
+}
+
+func (m subscriberServiceMock) GetObjectBySealID(goctx.Context, int) (clustersubscriber.Object, error) {
+	return m.contract.Object, nil
+}
+
+type brigadeServiceMock struct {
+	brigade clusterbrigade.Brigade
+}
+
+func (m brigadeServiceMock) GetBrigadeByID(goctx.Context, int) (clusterbrigade.Brigade, error) {
+	return m.brigade, nil
 }
 
-func (m *fileServiceMock) Upload(goctx.Context, string, io.Reader, clusterfile.ForwardedHeaders) (clusterfile.File, error) {
-	return clusterfile.File{}, nil
+func (m *fileServiceMock) Upload(_ goctx.Context, fileName string, content io.Reader, _ clusterfile.ForwardedHeaders) (clusterfile.File, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if m.discardContent {
+		_, err = io.Copy(io.Discard, content)
+	} else {
+		data, err = io.ReadAll(content)
+	}
+	if err != nil {
+		return clusterfile.File{}, err
+	}
+
+	mocksMu.Lock()
+	defer mocksMu.Unlock()
+
+	m.uploadedNames = append(m.uploadedNames, fileName)
+	m.uploaded = append(m.uploaded, data)
+
+	uploaded := clusterfile.File{ID: 500 + len(m.uploadedNames), FileName: fileName}
+	uploaded.URL = fmt.Sprintf("https://files.test/%d", uploaded.ID)
+	if m.filesByID == nil {
+		m.filesByID = make(map[int]clusterfile.File)
+	}
+	if m.contentsByURL == nil {
+		m.contentsByURL = make(map[string][]byte)
+	}
+	m.filesByID[uploaded.ID] = uploaded
+	m.contentsByURL[uploaded.URL] = data
+
+	return uploaded, nil
 }
 
 func (m *fileServiceMock) GetByIDs(_ goctx.Context, ids []int, page pagination.Pagination, headers clusterfile.ForwardedHeaders) ([]clusterfile.File, error) {
@@ -104,7 +591,19 @@ func (m *fileServiceMock) GetByIDs(_ goctx.Context, ids []int, page pagination.P
 	return files, nil
 }
 
-func TestGetByBrigadeReturnsInspectionsForBrigadeTasks(t *testing.T) {
+func (m *fileServiceMock) Download(_ goctx.Context, fileURL string) (io.ReadCloser, error) {
+	mocksMu.Lock()
+	defer mocksMu.Unlock()
+
+	content, ok := m.contentsByURL[fileURL]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", fileURL)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func TestGetByBrigadeLoadsInspectionsOfAllTasksAtOnce(t *testing.T) {
 	taskService := &taskServiceMock{
 		tasksByBrigadeID: map[int][]clustertask.Task{
 			7: {
@@ -115,34 +614,82 @@ func TestGetByBrigadeReturnsInspectionsForBrigadeTasks(t *testing.T) {
 		},
 	}
 
+	repository := &repositoryMock{
+		inspectionsByTaskID: map[int]Inspection{
+			10: {ID: 100, TaskID: 10},
+			30: {ID: 300, TaskID: 30},
+		},
+	}
+
 	service := &Service{
-		repository: &repositoryMock{
-			inspectionsByTaskID: map[int]Inspection{
-				10: {ID: 100, TaskID: 10},
-				30: {ID: 300, TaskID: 30},
+		repository:  repository,
+		taskService: taskService,
+		fileService: &fileServiceMock{},
+	}
+
+	got, err := service.GetByBrigade(goctx.Wrap(context.Background()), 7, Page{Limit: 2}, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("GetByBrigade returned error: %v", err)
+	}
+
+	if len(got.Items) != 2 || got.Items[0].ID != 100 || got.Items[1].ID != 300 {
+		t.Fatalf("got.Items = %+v, want inspections 100 and 300", got.Items)
+	}
+	if len(repository.gotTaskIDs) != 3 {
+		t.Fatalf("repository.gotTaskIDs = %v, want all brigade tasks", repository.gotTaskIDs)
+	}
+	if want := (Page{Limit: 2, SortBy: SortByID, Direction: SortAsc}); repository.gotPage != want {
+		t.Fatalf("repository.gotPage = %+v, want %+v", repository.gotPage, want)
+	}
+	if taskService.gotPage != (pagination.Pagination{}) {
+		t.Fatalf("taskService.gotPage = %+v, want all tasks", taskService.gotPage)
+	}
+}
+
+func TestGetByBrigadeReturnsInspectionsForBrigadeTasks(t *testing.T) {
+	taskService := &taskServiceMock{
+		tasksByBrigadeID: map[int][]clustertask.Task{
+			7: {
+				{ID: 30},
+				{ID: 10},
+				{ID: 20},
+				{ID: 40},
 			},
 		},
+	}
+
+	// Task 20 has no inspection yet.
+	repository := &repositoryMock{
+		inspectionsByTaskID: map[int]Inspection{
+			10: {ID: 100, TaskID: 10},
+			30: {ID: 300, TaskID: 30},
+			40: {ID: 400, TaskID: 40},
+		},
+	}
+
+	service := &Service{
+		repository:  repository,
 		taskService: taskService,
 		fileService: &fileServiceMock{},
 	}
 
-	page := pagination.Pagination{Limit: 2, Offset: 4}
+	page := Page{Offset: 1, Limit: 2, SortBy: SortByID, Direction: SortDesc}
 	got, err := service.GetByBrigade(goctx.Wrap(context.Background()), 7, page, clusterfile.ForwardedHeaders{})
 	if err != nil {
 		t.Fatalf("GetByBrigade returned error: %v", err)
 	}
 
-	if len(got) != 2 {
-		t.Fatalf("len(got) = %d, want 2", len(got))
+	if len(got.Items) != 2 || got.Items[0].ID != 300 || got.Items[1].ID != 100 {
+		t.Fatalf("got.Items = %+v, want inspections 300 and 100 in page order", got.Items)
 	}
-	if got[0].ID != 100 {
-		t.Fatalf("got[0].ID = %d, want 100", got[0].ID)
+	if got.Total != 3 {
+		t.Fatalf("got.Total = %d, want 3 inspections without the missing one", got.Total)
 	}
-	if got[1].ID != 300 {
-		t.Fatalf("got[1].ID = %d, want 300", got[1].ID)
+	if repository.gotPage != page {
+		t.Fatalf("repository.gotPage = %+v, want %+v", repository.gotPage, page)
 	}
-	if taskService.gotPage != page {
-		t.Fatalf("taskService.gotPage = %+v, want %+v", taskService.gotPage, page)
+	if !slices.Equal(repository.gotTaskIDs, []int{30, 10, 20, 40}) {
+		t.Fatalf("repository.gotTaskIDs = %v, want all brigade tasks", repository.gotTaskIDs)
 	}
 }
 
@@ -177,16 +724,16 @@ func TestGetAllReturnsAttachmentFileURLs(t *testing.T) {
 	}
 
 	headers := clusterfile.ForwardedHeaders{Host: "api.example.test", Proto: "https"}
-	got, err := service.GetAll(goctx.Wrap(context.Background()), pagination.Pagination{}, SortDirection(""), headers)
+	got, err := service.GetAll(goctx.Wrap(context.Background()), Filter{}, Page{}, headers)
 	if err != nil {
 		t.Fatalf("GetAll returned error: %v", err)
 	}
 
-	if got[0].Attachments[0].FileURL != "https://example.test/storage/photo.jpg" {
-		t.Fatalf("got[0].Attachments[0].FileURL = %q, want %q", got[0].Attachments[0].FileURL, "https://example.test/storage/photo.jpg")
+	if got.Items[0].Attachments[0].FileURL != "https://example.test/storage/photo.jpg" {
+		t.Fatalf("got.Items[0].Attachments[0].FileURL = %q, want %q", got.Items[0].Attachments[0].FileURL, "https://example.test/storage/photo.jpg")
 	}
-	if got[1].Attachments[0].FileURL != "https://example.test/storage/act.docx" {
-		t.Fatalf("got[1].Attachments[0].FileURL = %q, want %q", got[1].Attachments[0].FileURL, "https://example.test/storage/act.docx")
+	if got.Items[1].Attachments[0].FileURL != "https://example.test/storage/act.docx" {
+		t.Fatalf("got.Items[1].Attachments[0].FileURL = %q, want %q", got.Items[1].Attachments[0].FileURL, "https://example.test/storage/act.docx")
 	}
 	if len(fileService.gotIDs) != 2 || fileService.gotIDs[0] != 70 || fileService.gotIDs[1] != 80 {
 		t.Fatalf("fileService.gotIDs = %+v, want [70 80]", fileService.gotIDs)
@@ -245,20 +792,45 @@ func TestGetByIDReturnsInspection(t *testing.T) {
 	}
 }
 
-func TestGetAllPassesSortToRepository(t *testing.T) {
+func TestGetByIDReturnsNotFoundError(t *testing.T) {
+	service := &Service{
+		repository:  &repositoryMock{},
+		fileService: &fileServiceMock{},
+	}
+
+	_, err := service.GetByID(goctx.Wrap(context.Background()), 42, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByID error = %v, want ErrNotFound", err)
+	}
+
+	var notFoundErr NotFoundError
+	if !errors.As(err, &notFoundErr) {
+		t.Fatalf("GetByID error = %v, want NotFoundError", err)
+	}
+	if notFoundErr.Code() != "inspection_not_found" {
+		t.Fatalf("code = %q, want %q", notFoundErr.Code(), "inspection_not_found")
+	}
+}
+
+func TestGetAllPassesNormalizedPageAndFilterToRepository(t *testing.T) {
 	repository := &repositoryMock{inspections: []Inspection{{ID: 42}}}
 	service := &Service{
 		repository:  repository,
 		fileService: &fileServiceMock{},
 	}
 
-	_, err := service.GetAll(goctx.Wrap(context.Background()), pagination.Pagination{}, SortDesc, clusterfile.ForwardedHeaders{})
+	filter := Filter{Types: []Type{TypeUnauthorizedConnection}}
+	_, err := service.GetAll(goctx.Wrap(context.Background()), filter, Page{Limit: 20, Direction: SortDesc}, clusterfile.ForwardedHeaders{})
 	if err != nil {
 		t.Fatalf("GetAll returned error: %v", err)
 	}
 
-	if repository.gotSort != SortDesc {
-		t.Fatalf("repository sort = %q, want %q", repository.gotSort, SortDesc)
+	want := Page{Limit: 20, SortBy: SortByInspectAt, Direction: SortDesc}
+	if repository.gotPage != want {
+		t.Fatalf("repository page = %+v, want %+v", repository.gotPage, want)
+	}
+	if len(repository.gotFilter.Types) != 1 || repository.gotFilter.Types[0] != TypeUnauthorizedConnection {
+		t.Fatalf("repository filter = %+v, want %+v", repository.gotFilter, filter)
 	}
 }
 
@@ -269,11 +841,886 @@ func TestGetAllRejectsInvalidSort(t *testing.T) {
 		fileService: &fileServiceMock{},
 	}
 
-	_, err := service.GetAll(goctx.Wrap(context.Background()), pagination.Pagination{}, SortDirection("newest"), clusterfile.ForwardedHeaders{})
-	if err == nil {
-		t.Fatal("GetAll returned nil error, want invalid sort error")
+	_, err := service.GetAll(goctx.Wrap(context.Background()), Filter{}, Page{Direction: SortDirection("newest")}, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("GetAll error = %v, want ErrValidation", err)
 	}
 	if repository.getAllCalled {
 		t.Fatal("repository.GetAll was called for invalid sort")
 	}
 }
+
+func TestHandleStartedTaskWritesStartEventToOutbox(t *testing.T) {
+	repository := &repositoryMock{}
+	service := &Service{repository: repository}
+
+	err := service.handleStartedTask(context.Background(), clustertask.Task{ID: 10, Status: clustertask.StatusInWork}, 0)
+	if err != nil {
+		t.Fatalf("handleStartedTask returned error: %v", err)
+	}
+
+	if len(repository.events) != 1 {
+		t.Fatalf("len(repository.events) = %d, want 1", len(repository.events))
+	}
+	if repository.events[0].Type != EventTypeStart {
+		t.Fatalf("event type = %d, want %d", repository.events[0].Type, EventTypeStart)
+	}
+	if repository.events[0].Inspection.TaskID != 10 {
+		t.Fatalf("event inspection task id = %d, want 10", repository.events[0].Inspection.TaskID)
+	}
+}
+
+func newFinishTestService(t testing.TB, repository *repositoryMock, fileService *fileServiceMock) *Service {
+	t.Helper()
+
+	brigadeID := 3
+	fontPath, _ := writeTestFont(t)
+
+	service := &Service{
+		repository: repository,
+		subscriberService: subscriberServiceMock{
+			contract: clustersubscriber.Contract{
+				Subscriber: clustersubscriber.Subscriber{AccountNumber: "100500", Surname: "Иванов", Name: "Иван"},
+				Object: clustersubscriber.Object{
+					ID:      5,
+					Address: "ул. Ленина, 1",
+					Devices: []clustersubscriber.Device{
+						{
+							ID:        11,
+							Type:      "Меркурий",
+							Number:    "D-11",
+							PlaceType: clustersubscriber.DevicePlaceFlat,
+							Seals:     []clustersubscriber.Seal{{ID: 21, DeviceID: 11, Number: "S-21", Place: "клеммная крышка"}},
+						},
+					},
+				},
+			},
+		},
+		fileService: fileService,
+		taskService: &taskServiceMock{
+			tasksByID: map[int]clustertask.Task{7: {ID: 7, BrigadeID: &brigadeID, ObjectID: 5}},
+		},
+		brigadeService: brigadeServiceMock{
+			brigade: clusterbrigade.Brigade{
+				ID: brigadeID,
+				Inspectors: []clusterbrigade.Inspector{
+					{Surname: "Петров", Name: "Петр", Patronymic: "Петрович"},
+					{Surname: "Сидоров", Name: "Сидор"},
+				},
+			},
+		},
+		templates: config.Templates{
+			Universal: "templates/universal_act.docx",
+			Control:   "templates/control_act.docx",
+			Font:      fontPath,
+		},
+		actTemplates: newActTemplateCache(),
+		access:       config.Access{Reviewers: []int{testReviewerID}, Admins: []int{testAdminID}},
+	}
+
+	if err := service.InitActTemplates(context.Background()); err != nil {
+		t.Fatalf("InitActTemplates returned error: %v", err)
+	}
+
+	return service
+}
+
+const testReviewerID = 9
+
+func newReviewerContext() goctx.Context {
+	ctx := goctx.Wrap(context.Background())
+	ctx.Authorize.UserId = testReviewerID
+	return ctx
+}
+
+const testAdminID = 8
+
+func newAdminContext() goctx.Context {
+	ctx := goctx.Wrap(context.Background())
+	ctx.Authorize.UserId = testAdminID
+	return ctx
+}
+
+func newFinishTestRequest() FinishInspectionRequest {
+	return FinishInspectionRequest{
+		ID:             42,
+		Type:           TypeLimitation,
+		Resolution:     ResolutionLimited,
+		Method:         "отключение автомата",
+		MethodBy:       MethodByInspector,
+		ReasonType:     ReasonTypeInspectorLimited,
+		EnergyActionAt: time.Date(2026, time.May, 9, 12, 0, 0, 0, time.UTC),
+		InspectedDevices: []InspectedDeviceRequest{
+			{
+				DeviceID:       11,
+				InspectedSeals: []InspectedSealRequest{{SealID: 21}},
+			},
+		},
+	}
+}
+
+func TestFinishInspectionAddsNoEventWhenTransactionFails(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	_, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{})
+	if err == nil {
+		t.Fatal("FinishInspection returned nil error, want transaction error")
+	}
+
+	if len(fileService.uploadedNames) != 1 {
+		t.Fatalf("len(fileService.uploadedNames) = %d, want 1", len(fileService.uploadedNames))
+	}
+	if len(repository.events) != 0 {
+		t.Fatalf("len(repository.events) = %d, want 0", len(repository.events))
+	}
+}
+
+func TestFinishInspectionRetryAttachesActUploadedByFailedAttempt(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+	ctx := goctx.Wrap(context.Background())
+
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err == nil {
+		t.Fatal("FinishInspection returned no error")
+	}
+	if len(repository.actUploads) != 1 {
+		t.Fatalf("repository.actUploads = %+v, want the act of the failed attempt", repository.actUploads)
+	}
+
+	// The mock does not roll back the status change of the failed attempt.
+	ins := repository.inspectionsByID[42]
+	ins.Status = StatusInWork
+	repository.inspectionsByID[42] = ins
+	repository.finishErr = nil
+
+	got, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if len(fileService.uploadedNames) != 1 {
+		t.Fatalf("uploaded = %v, want the act uploaded once", fileService.uploadedNames)
+	}
+	if got.ID != 501 || got.URL != "https://files.test/501" {
+		t.Fatalf("got = %+v, want file 501 of the failed attempt", got)
+	}
+	if len(repository.attachments) != 1 || repository.attachments[0].FileID != 501 {
+		t.Fatalf("repository.attachments = %+v, want file 501", repository.attachments)
+	}
+	if len(repository.actUploads) != 0 || len(repository.abandonedUploads) != 0 {
+		t.Fatalf("uploads = %+v, abandoned = %+v, want the attached upload forgotten", repository.actUploads, repository.abandonedUploads)
+	}
+}
+
+func TestFinishInspectionAbandonsActOfFailedAttemptWithOtherContent(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+	ctx := goctx.Wrap(context.Background())
+
+	request := newFinishTestRequest()
+	request.Type = TypeVerification
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); err == nil {
+		t.Fatal("FinishInspection returned no error")
+	}
+
+	ins := repository.inspectionsByID[42]
+	ins.Status = StatusInWork
+	repository.inspectionsByID[42] = ins
+	repository.finishErr = nil
+
+	if _, err := service.FinishInspection(ctx, golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if len(fileService.uploadedNames) != 2 {
+		t.Fatalf("uploaded = %v, want the control act and the limitation act", fileService.uploadedNames)
+	}
+	if len(repository.attachments) != 1 || repository.attachments[0].FileID != 502 {
+		t.Fatalf("repository.attachments = %+v, want file 502", repository.attachments)
+	}
+	if len(repository.actUploads) != 0 || len(repository.abandonedUploads) != 1 || repository.abandonedUploads[0].FileID != 501 {
+		t.Fatalf("uploads = %+v, abandoned = %+v, want file 501 abandoned", repository.actUploads, repository.abandonedUploads)
+	}
+}
+
+func TestFinishInspectionReplaysResponseForSameIdempotencyKey(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	request := newFinishTestRequest()
+	request.IdempotencyKey = "retry-1"
+
+	first, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("first FinishInspection returned error: %v", err)
+	}
+
+	fileService.filesByID = map[int]clusterfile.File{first.ID: {ID: first.ID, URL: "https://files/fresh"}}
+
+	second, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("second FinishInspection returned error: %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Fatalf("second.ID = %d, want %d", second.ID, first.ID)
+	}
+	if second.URL != "https://files/fresh" {
+		t.Fatalf("second.URL = %q, want the URL resolved again", second.URL)
+	}
+	if len(fileService.uploadedNames) != 1 {
+		t.Fatalf("len(fileService.uploadedNames) = %d, want 1", len(fileService.uploadedNames))
+	}
+}
+
+func TestFinishInspectionRejectsKeyOfFinishInProgress(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		idempotencyKeys: map[string]IdempotencyKey{"retry-1": {Key: "retry-1", InspectionID: 42, CreatedAt: time.Now()}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	request := newFinishTestRequest()
+	request.IdempotencyKey = "retry-1"
+
+	_, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Fatalf("FinishInspection error = %v, want ErrIdempotencyKeyInUse", err)
+	}
+	if len(fileService.uploadedNames) != 0 || len(repository.actNumberCounters) != 0 {
+		t.Fatalf("uploaded %v and assigned act numbers %v, want no side effects", fileService.uploadedNames, repository.actNumberCounters)
+	}
+}
+
+func TestFinishInspectionTakesOverExpiredReservation(t *testing.T) {
+	// The request that reserved the key crashed before it completed the finish.
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		idempotencyKeys: map[string]IdempotencyKey{"retry-1": {Key: "retry-1", InspectionID: 42, CreatedAt: time.Now().Add(-11 * time.Minute)}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	request := newFinishTestRequest()
+	request.IdempotencyKey = "retry-1"
+
+	uploaded, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	key := repository.idempotencyKeys["retry-1"]
+	if len(key.Response) == 0 || key.FileID != uploaded.ID {
+		t.Fatalf("idempotency key = %+v, want it completed with file %d", key, uploaded.ID)
+	}
+	if repository.inspectionsByID[42].Status != StatusSubmitted {
+		t.Fatalf("status = %v, want submitted", repository.inspectionsByID[42].Status)
+	}
+}
+
+func TestFinishInspectionReleasesKeyWhenFinishFails(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+		finishErr:       errors.New("connection reset"),
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	request := newFinishTestRequest()
+	request.IdempotencyKey = "retry-1"
+
+	if _, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); err == nil {
+		t.Fatal("FinishInspection returned nil error, want transaction error")
+	}
+
+	if _, ok := repository.idempotencyKeys["retry-1"]; ok {
+		t.Fatal("idempotency key is still reserved after a failed finish")
+	}
+}
+
+func TestPreviousReadingsAreTakenFromOtherInspectionsOfDevice(t *testing.T) {
+	repository := &repositoryMock{
+		previousReadings: []InspectedDevice{
+			{DeviceID: 11, InspectionID: 41, Value: decimal.NewFromInt(100)},
+			{DeviceID: 11, InspectionID: 42, Value: decimal.NewFromInt(120)},
+			{DeviceID: 12, InspectionID: 41, Value: decimal.NewFromInt(7)},
+		},
+	}
+	service := &Service{repository: repository}
+
+	// The request ID differs from the device ID, so swapped arguments find no readings.
+	previous, err := service.previousReadings(goctx.Wrap(context.Background()), newFinishTestRequest())
+	if err != nil {
+		t.Fatalf("previousReadings returned error: %v", err)
+	}
+
+	got := previous[11]
+	if len(got) != 1 || got[0].InspectionID != 41 || !got[0].Value.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("previous[11] = %+v, want the reading of inspection 41", got)
+	}
+}
+
+func TestFinishInspectionRejectsSubmittedInspection(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	_, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{})
+	var transitionErr TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("FinishInspection error = %v, want TransitionError", err)
+	}
+	if transitionErr.From != StatusSubmitted || transitionErr.To != StatusSubmitted {
+		t.Fatalf("transitionErr = %+v, want Submitted -> Submitted", transitionErr)
+	}
+	if len(fileService.uploadedNames) != 0 {
+		t.Fatalf("len(fileService.uploadedNames) = %d, want 0", len(fileService.uploadedNames))
+	}
+}
+
+func TestFinishInspectionSubmitsInspection(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	_, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if got := repository.inspectionsByID[42].Status; got != StatusSubmitted {
+		t.Fatalf("status = %s, want %s", got, StatusSubmitted)
+	}
+	if len(repository.statusChanges) != 1 || *repository.statusChanges[0].From != StatusInWork || repository.statusChanges[0].To != StatusSubmitted {
+		t.Fatalf("repository.statusChanges = %+v, want InWork -> Submitted", repository.statusChanges)
+	}
+	if len(repository.events) != 1 || repository.events[0].Inspection.Status != StatusSubmitted {
+		t.Fatalf("repository.events = %+v, want one event with submitted inspection", repository.events)
+	}
+}
+
+func TestFinishInspectionAttachesActInEveryFormat(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	request := newFinishTestRequest()
+	request.ActFormats = []ActFormat{ActFormatPDF, ActFormatDOCX}
+
+	got, err := service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if got.ID != 501 || filepath.Ext(got.FileName) != ".pdf" {
+		t.Fatalf("FinishInspection = %+v, want the pdf act", got)
+	}
+	if len(fileService.uploadedNames) != 2 || filepath.Ext(fileService.uploadedNames[1]) != ".docx" {
+		t.Fatalf("fileService.uploadedNames = %v, want pdf and docx acts", fileService.uploadedNames)
+	}
+	if !slices.Equal(repository.attachmentTypes, []AttachmentType{AttachmentTypeActPDF, AttachmentTypeAct}) {
+		t.Fatalf("repository.attachmentTypes = %v, want [ActPDF Act]", repository.attachmentTypes)
+	}
+	for _, id := range repository.actTemplateIDs {
+		if id == nil || *id != 1 {
+			t.Fatalf("repository.actTemplateIDs = %v, want template 1 for both acts", repository.actTemplateIDs)
+		}
+	}
+}
+
+func TestFinishInspectionStoresSignaturesAndSignsActs(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	var err error
+	service.signer, err = NewActSigner(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatalf("NewActSigner returned error: %v", err)
+	}
+
+	request := newFinishTestRequest()
+	request.Signatures = []Signature{{Signer: SignerConsumer, Image: newTestSignature(t, 200, 100)}}
+
+	if _, err = service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if len(repository.signatures) != 1 || repository.signatures[0].Signer != SignerConsumer {
+		t.Fatalf("repository.signatures = %+v, want the consumer signature", repository.signatures)
+	}
+	if len(repository.attachments) != 1 || len(repository.attachments[0].Signature) != ed25519.SignatureSize {
+		t.Fatalf("repository.attachments = %+v, want the signed act", repository.attachments)
+	}
+}
+
+func TestPreviewActStoresNothing(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	document, err := service.PreviewAct(goctx.Wrap(context.Background()), newFinishTestRequest(), "")
+	if err != nil {
+		t.Fatalf("PreviewAct returned error: %v", err)
+	}
+
+	if document.Format != ActFormatDOCX || filepath.Ext(document.FileName) != ".docx" {
+		t.Fatalf("document = %s %s, want docx", document.Format, document.FileName)
+	}
+	if text := documentText(t, document.Content.Bytes()); !strings.Contains(text, "№"+noActNumber) {
+		t.Fatalf("act is numbered before finishing: %s", text)
+	}
+
+	if len(fileService.uploadedNames) != 0 || len(repository.attachmentTypes) != 0 || len(repository.statusChanges) != 0 ||
+		len(repository.actNumberCounters) != 0 {
+		t.Fatalf("preview stored uploads %v, attachments %v, status changes %v, act numbers %v", fileService.uploadedNames,
+			repository.attachmentTypes, repository.statusChanges, repository.actNumberCounters)
+	}
+	if got := repository.inspectionsByID[42].Status; got != StatusInWork {
+		t.Fatalf("status = %s, want %s", got, StatusInWork)
+	}
+}
+
+func TestPreviewActRejectsUnknownFormat(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	_, err := service.PreviewAct(goctx.Wrap(context.Background()), newFinishTestRequest(), "odt")
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("PreviewAct error = %v, want ErrValidation", err)
+	}
+}
+
+func newRegenerateTestInspection(status Status) Inspection {
+	request := newFinishTestRequest()
+	inspectAt := time.Date(2026, time.May, 9, 21, 30, 0, 0, time.UTC)
+	declared := decimal.NewFromInt(120)
+	consumption := decimal.NewFromInt(100)
+
+	return Inspection{
+		ID:             request.ID,
+		TaskID:         7,
+		Status:         status,
+		Type:           &request.Type,
+		Resolution:     &request.Resolution,
+		Method:         &request.Method,
+		MethodBy:       &request.MethodBy,
+		ReasonType:     &request.ReasonType,
+		InspectAt:      &inspectAt,
+		EnergyActionAt: &request.EnergyActionAt,
+		InspectedDevices: []InspectedDevice{
+			{
+				DeviceID:            11,
+				InspectionID:        request.ID,
+				Value:               decimal.NewFromInt(1500),
+				Consumption:         &consumption,
+				DeclaredConsumption: &declared,
+				InspectedSeals:      []InspectedSeal{{SealID: 21, DeviceID: 11, InspectionID: request.ID, IsBroken: true}},
+				CreatedAt:           inspectAt,
+			},
+		},
+		Attachments: []Attachment{{ID: 1, InspectionID: request.ID, Type: AttachmentTypeAct, FileID: 400}},
+	}
+}
+
+func TestRegenerateActSupersedesPreviousAct(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: newRegenerateTestInspection(StatusApproved)},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	request := RegenerateActRequest{ID: 42, Reason: "исправлен шаблон"}
+	got, err := service.RegenerateAct(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("RegenerateAct returned error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Type != AttachmentTypeAct || got[0].FileID != 501 {
+		t.Fatalf("RegenerateAct = %+v, want the docx act", got)
+	}
+	if got[0].Reason == nil || *got[0].Reason != request.Reason {
+		t.Fatalf("reason = %v, want %q", got[0].Reason, request.Reason)
+	}
+	if !slices.Equal(repository.supersededActs, []int{42}) {
+		t.Fatalf("repository.supersededActs = %v, want [42]", repository.supersededActs)
+	}
+	// The act keeps the Moscow date of the inspection, not the date of regeneration.
+	if len(fileService.uploadedNames) != 1 || !strings.Contains(fileService.uploadedNames[0], "от 10.05.2026") {
+		t.Fatalf("fileService.uploadedNames = %v, want the act dated 10.05.2026", fileService.uploadedNames)
+	}
+	if len(repository.statusChanges) != 0 || len(repository.events) != 0 {
+		t.Fatalf("regeneration changed status %v or added events %v", repository.statusChanges, repository.events)
+	}
+}
+
+func TestRegenerateActRetryAttachesActUploadedByFailedAttempt(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: newRegenerateTestInspection(StatusApproved)},
+		supersedeErr:    errors.New("connection reset"),
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+	ctx := goctx.Wrap(context.Background())
+
+	request := RegenerateActRequest{ID: 42, Reason: "исправлен шаблон"}
+	if _, err := service.RegenerateAct(ctx, golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{}); !errors.Is(err, repository.supersedeErr) {
+		t.Fatalf("RegenerateAct error = %v, want %v", err, repository.supersedeErr)
+	}
+	if len(repository.actUploads) != 1 || repository.actUploads[0].FileID != 501 {
+		t.Fatalf("repository.actUploads = %+v, want file 501 of the failed attempt", repository.actUploads)
+	}
+
+	repository.supersedeErr = nil
+
+	got, err := service.RegenerateAct(ctx, golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("RegenerateAct returned error: %v", err)
+	}
+
+	if len(fileService.uploadedNames) != 1 {
+		t.Fatalf("uploaded = %v, want the act uploaded once", fileService.uploadedNames)
+	}
+	if len(got) != 1 || got[0].FileID != 501 || got[0].FileURL != "https://files.test/501" {
+		t.Fatalf("RegenerateAct = %+v, want file 501 of the failed attempt", got)
+	}
+	if len(repository.actUploads) != 0 || len(repository.abandonedUploads) != 0 {
+		t.Fatalf("uploads = %+v, abandoned = %+v, want the attached upload forgotten", repository.actUploads, repository.abandonedUploads)
+	}
+}
+
+func TestRegenerateActRejectsInspectionWithoutFinalAct(t *testing.T) {
+	for _, ins := range []Inspection{
+		{ID: 42, TaskID: 7, Status: StatusInWork},
+		newRegenerateTestInspection(StatusRejected),
+	} {
+		repository := &repositoryMock{inspectionsByID: map[int]Inspection{42: ins}}
+		fileService := &fileServiceMock{}
+		service := newFinishTestService(t, repository, fileService)
+
+		request := RegenerateActRequest{ID: 42, Reason: "исправлен шаблон"}
+		_, err := service.RegenerateAct(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+		if !errors.Is(err, ErrActNotRegenerable) {
+			t.Fatalf("RegenerateAct of %s inspection error = %v, want ErrActNotRegenerable", ins.Status, err)
+		}
+		if len(fileService.uploadedNames) != 0 || len(repository.supersededActs) != 0 {
+			t.Fatalf("RegenerateAct of %s inspection uploaded %v, superseded %v", ins.Status, fileService.uploadedNames,
+				repository.supersededActs)
+		}
+	}
+}
+
+func TestRegenerateActRequiresReason(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: newRegenerateTestInspection(StatusApproved)},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	request := RegenerateActRequest{ID: 42, Reason: " "}
+	_, err := service.RegenerateAct(goctx.Wrap(context.Background()), golog.NewLogger("test"), request, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("RegenerateAct error = %v, want ErrValidation", err)
+	}
+}
+
+func TestSubmittedRequestKeepsStoredConsumption(t *testing.T) {
+	request := submittedRequest(newRegenerateTestInspection(StatusApproved), newValidationTestObject())
+
+	if request.Type != TypeLimitation || request.Method != "отключение автомата" {
+		t.Fatalf("request = %+v, want the stored limitation", request)
+	}
+
+	device := request.InspectedDevices[0]
+	if !device.ComputedConsumption.Equal(decimal.NewFromInt(100)) || !device.Consumption.Equal(decimal.NewFromInt(120)) {
+		t.Fatalf("consumption = computed %s, declared %s, want 100 and 120", device.ComputedConsumption, device.Consumption)
+	}
+	if len(device.InspectedSeals) != 1 || !device.InspectedSeals[0].IsBroken {
+		t.Fatalf("seals = %+v, want the broken seal 21", device.InspectedSeals)
+	}
+}
+
+func TestSubmittedRequestPutsUnassignedSealsUnderTheirDevices(t *testing.T) {
+	ins := newRegenerateTestInspection(StatusApproved)
+	ins.InspectedDevices = append(ins.InspectedDevices, InspectedDevice{DeviceID: 12, InspectionID: ins.ID})
+	ins.UnassignedSeals = []InspectedSeal{
+		{SealID: 22, InspectionID: ins.ID, IsBroken: true},
+		{SealID: 99, InspectionID: ins.ID},
+	}
+
+	request := submittedRequest(ins, newValidationTestObject())
+
+	seals := request.InspectedDevices[1].InspectedSeals
+	if len(seals) != 1 || seals[0].SealID != 22 || !seals[0].IsBroken {
+		t.Fatalf("seals of device 12 = %+v, want the broken seal 22", seals)
+	}
+	if len(request.InspectedDevices[0].InspectedSeals) != 1 {
+		t.Fatalf("seals of device 11 = %+v, want only seal 21", request.InspectedDevices[0].InspectedSeals)
+	}
+}
+
+func TestHandleFinishedTaskClosesInspection(t *testing.T) {
+	for _, tc := range []struct {
+		from, want Status
+	}{
+		{from: StatusApproved, want: StatusDone},
+		{from: StatusInWork, want: StatusCancelled},
+		{from: StatusRejected, want: StatusCancelled},
+		{from: StatusSubmitted, want: StatusSubmitted},
+		{from: StatusDone, want: StatusDone},
+	} {
+		t.Run(tc.from.String(), func(t *testing.T) {
+			repository := &repositoryMock{
+				inspectionsByID:     map[int]Inspection{42: {ID: 42, TaskID: 7, Status: tc.from}},
+				inspectionsByTaskID: map[int]Inspection{7: {ID: 42, TaskID: 7, Status: tc.from}},
+			}
+			service := &Service{repository: repository}
+
+			if err := service.handleFinishedTask(context.Background(), clustertask.Task{ID: 7}, 9); err != nil {
+				t.Fatalf("handleFinishedTask returned error: %v", err)
+			}
+
+			if got := repository.inspectionsByID[42].Status; got != tc.want {
+				t.Fatalf("status = %s, want %s", got, tc.want)
+			}
+
+			changes := 0
+			if tc.want != tc.from {
+				changes = 1
+			}
+			if len(repository.statusChanges) != changes || changes == 1 && repository.statusChanges[0].UserID != 9 {
+				t.Fatalf("repository.statusChanges = %+v, want %d changes by user 9", repository.statusChanges, changes)
+			}
+		})
+	}
+}
+
+func TestAttachPhotosRejectsSubmittedInspection(t *testing.T) {
+	service := &Service{
+		repository: &repositoryMock{
+			inspectionsByID: map[int]Inspection{42: {ID: 42, Status: StatusSubmitted}},
+		},
+	}
+
+	_, err := service.AttachPhotos(goctx.Wrap(context.Background()), golog.NewLogger("test"), AttachPhotosRequest{
+		InspectionID: 42,
+		Photos:       []Photo{{Type: AttachmentTypeDevicePhoto}},
+	})
+	if !errors.Is(err, ErrNotEditable) {
+		t.Fatalf("AttachPhotos error = %v, want %v", err, ErrNotEditable)
+	}
+}
+
+func TestRejectInspectionRequiresComment(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	_, err := service.RejectInspection(newReviewerContext(), ReviewRequest{ID: 42}, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrRejectCommentRequired) {
+		t.Fatalf("RejectInspection error = %v, want %v", err, ErrRejectCommentRequired)
+	}
+	if got := repository.inspectionsByID[42].Status; got != StatusSubmitted {
+		t.Fatalf("status = %s, want %s", got, StatusSubmitted)
+	}
+}
+
+func TestRejectedInspectionIsResubmittedWithNewReadings(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	comment := "показания не совпадают с фото"
+	got, err := service.RejectInspection(newReviewerContext(), ReviewRequest{ID: 42, Comment: &comment}, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("RejectInspection returned error: %v", err)
+	}
+	if got.Status != StatusRejected {
+		t.Fatalf("got.Status = %s, want %s", got.Status, StatusRejected)
+	}
+	if len(repository.events) != 1 || repository.events[0].Type != EventTypeRejected || *repository.events[0].Comment != comment {
+		t.Fatalf("repository.events = %+v, want one rejected event with comment", repository.events)
+	}
+
+	_, err = service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+	if !repository.deletedDevices {
+		t.Fatal("previous inspected devices were not deleted on resubmit")
+	}
+	if !slices.Equal(repository.supersededActs, []int{42}) {
+		t.Fatalf("repository.supersededActs = %v, want the previous act of 42 superseded on resubmit", repository.supersededActs)
+	}
+	if len(fileService.uploadedNames) != 1 {
+		t.Fatalf("len(fileService.uploadedNames) = %d, want 1", len(fileService.uploadedNames))
+	}
+	if got := repository.inspectionsByID[42].Status; got != StatusSubmitted {
+		t.Fatalf("status = %s, want %s", got, StatusSubmitted)
+	}
+}
+
+func TestApproveInspectionOfFinishedTaskIsDone(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+	service.taskService = &taskServiceMock{
+		tasksByID: map[int]clustertask.Task{7: {ID: 7, Status: clustertask.StatusDone}},
+	}
+
+	got, err := service.ApproveInspection(newReviewerContext(), ReviewRequest{ID: 42}, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("ApproveInspection returned error: %v", err)
+	}
+
+	if got.Status != StatusDone {
+		t.Fatalf("got.Status = %s, want %s", got.Status, StatusDone)
+	}
+	if len(repository.statusChanges) != 2 {
+		t.Fatalf("len(repository.statusChanges) = %d, want 2", len(repository.statusChanges))
+	}
+	if len(repository.events) != 1 || repository.events[0].Type != EventTypeApproved {
+		t.Fatalf("repository.events = %+v, want one approved event", repository.events)
+	}
+}
+
+// lockCheckingTaskService records whether tasks are read inside a transaction of the repository.
+type lockCheckingTaskService struct {
+	taskServiceMock
+	repository *repositoryMock
+	readInTx   bool
+}
+
+func (m *lockCheckingTaskService) GetTaskByID(ctx goctx.Context, id int) (clustertask.Task, error) {
+	m.readInTx = m.repository.inTx
+	return m.taskServiceMock.GetTaskByID(ctx, id)
+}
+
+func TestApproveInspectionOfTaskFinishedDuringReviewIsDone(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID:     map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+		inspectionsByTaskID: map[int]Inspection{7: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+	taskService := &lockCheckingTaskService{
+		taskServiceMock: taskServiceMock{tasksByID: map[int]clustertask.Task{7: {ID: 7, Status: clustertask.StatusDone}}},
+		repository:      repository,
+	}
+	service.taskService = taskService
+
+	// The task is finished while the inspection waits for its review, which keeps it submitted.
+	if err := service.handleFinishedTask(context.Background(), clustertask.Task{ID: 7}, 9); err != nil {
+		t.Fatalf("handleFinishedTask returned error: %v", err)
+	}
+
+	got, err := service.ApproveInspection(newReviewerContext(), ReviewRequest{ID: 42}, clusterfile.ForwardedHeaders{})
+	if err != nil {
+		t.Fatalf("ApproveInspection returned error: %v", err)
+	}
+
+	if got.Status != StatusDone {
+		t.Fatalf("got.Status = %s, want %s", got.Status, StatusDone)
+	}
+	if !taskService.readInTx {
+		t.Fatal("task was read outside the lock of the inspection")
+	}
+}
+
+func TestReviewRequiresReviewer(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	ctx := goctx.Wrap(context.Background())
+	ctx.Authorize.UserId = 5
+
+	_, err := service.ApproveInspection(ctx, ReviewRequest{ID: 42}, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrReviewerRequired) {
+		t.Fatalf("ApproveInspection error = %v, want %v", err, ErrReviewerRequired)
+	}
+
+	comment := "нет фото пломбы"
+	_, err = service.RejectInspection(ctx, ReviewRequest{ID: 42, Comment: &comment}, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrReviewerRequired) {
+		t.Fatalf("RejectInspection error = %v, want %v", err, ErrReviewerRequired)
+	}
+	if len(repository.statusChanges) != 0 {
+		t.Fatalf("repository.statusChanges = %+v, want none", repository.statusChanges)
+	}
+}
+
+func TestApproveInspectionForbidsSelfApproval(t *testing.T) {
+	inWork := StatusInWork
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusSubmitted}},
+		statusChanges:   []StatusChange{{InspectionID: 42, From: &inWork, To: StatusSubmitted, UserID: testReviewerID}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	_, err := service.ApproveInspection(newReviewerContext(), ReviewRequest{ID: 42}, clusterfile.ForwardedHeaders{})
+	if !errors.Is(err, ErrSelfReview) {
+		t.Fatalf("ApproveInspection error = %v, want %v", err, ErrSelfReview)
+	}
+	if got := repository.inspectionsByID[42].Status; got != StatusSubmitted {
+		t.Fatalf("status = %s, want %s", got, StatusSubmitted)
+	}
+}
+
+func TestGetAllRejectsInvalidFilter(t *testing.T) {
+	repository := &repositoryMock{}
+	service := &Service{
+		repository:  repository,
+		fileService: &fileServiceMock{},
+	}
+
+	from := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	filter := Filter{
+		Statuses:      []Status{Status(42)},
+		InspectAtFrom: &from,
+		InspectAtTo:   &to,
+	}
+
+	_, err := service.GetAll(goctx.Wrap(context.Background()), filter, Page{Direction: SortDesc}, clusterfile.ForwardedHeaders{})
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("GetAll error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 2 {
+		t.Fatalf("fields = %+v, want 2 fields", validationErr.Fields)
+	}
+	if repository.getAllCalled {
+		t.Fatal("repository.GetAll was called for invalid filter")
+	}
+}
@@ -0,0 +1,344 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lukasjarosch/go-docx"
+)
+
+const (
+	// maxSignatureSize limits a signature image, which signature pads export as a small PNG.
+	maxSignatureSize = 512 << 10
+	// maxSignatureSide limits the width and height of a signature image in pixels.
+	maxSignatureSide = 2000
+)
+
+type Signer string
+
+const (
+	SignerConsumer   Signer = "consumer"
+	SignerInspector1 Signer = "inspector1"
+	SignerInspector2 Signer = "inspector2"
+)
+
+// signers lists the signers in the order their signatures are placed on the act.
+var signers = []Signer{SignerConsumer, SignerInspector1, SignerInspector2}
+
+func (s Signer) Validate() error {
+	if !slices.Contains(signers, s) {
+		return fmt.Errorf("signer must be %q, %q or %q", SignerConsumer, SignerInspector1, SignerInspector2)
+	}
+
+	return nil
+}
+
+// Signature is a handwritten signature captured on the inspector's device. Image is a PNG, base64 in JSON.
+type Signature struct {
+	Signer Signer `json:"Signer"`
+	Image  []byte `json:"Image"`
+}
+
+func validateSignatures(errs *fieldErrors, signatures []Signature) {
+	seen := make(map[Signer]bool, len(signatures))
+	for i, s := range signatures {
+		field := fmt.Sprintf("Signatures[%d]", i)
+
+		if err := s.Signer.Validate(); err != nil {
+			errs.add(field+".Signer", "%s", err)
+		} else if seen[s.Signer] {
+			errs.add(field+".Signer", "signer %q is listed more than once", s.Signer)
+		}
+		seen[s.Signer] = true
+
+		if len(s.Image) > maxSignatureSize {
+			errs.add(field+".Image", "must not exceed %d bytes", maxSignatureSize)
+			continue
+		}
+
+		config, err := png.DecodeConfig(bytes.NewReader(s.Image))
+		if err != nil {
+			errs.add(field+".Image", "is not a PNG image: %s", err)
+			continue
+		}
+
+		if config.Width == 0 || config.Height == 0 || config.Width > maxSignatureSide || config.Height > maxSignatureSide {
+			errs.add(field+".Image", "must be from 1 to %d pixels on each side, got %dx%d", maxSignatureSide, config.Width, config.Height)
+		}
+	}
+}
+
+// actSignature is a signature placed in the act at the placeholder of its signer. Templates uploaded before
+// the placeholders were introduced have none, so there the signature is placed at the end of the act under a label
+// naming the signer.
+type actSignature struct {
+	signer Signer
+	label  string
+	image  []byte
+	width  int
+	height int
+}
+
+// signatureBlank fills the placeholder of a signer who has not signed, leaving the line to sign by hand.
+const signatureBlank = "______________"
+
+// placeholder returns the template placeholder of the signature of the signer, without delimiters.
+func (s Signer) placeholder() string {
+	return string(s) + "_signature"
+}
+
+// marker returns the text the placeholder of the signature is filled with before the image replaces it.
+// It consists of private use characters, which never occur in acts.
+func (s actSignature) marker() string {
+	return "\ue000" + string(s.signer) + "\ue000"
+}
+
+// newActSignatures orders the signatures of the request and labels them with the names filled into the act.
+func newActSignatures(signatures []Signature, fields map[string]any) ([]actSignature, error) {
+	labels := map[Signer]string{
+		SignerConsumer:   fmt.Sprintf("Потребитель %s", fields["consumer_fio"]),
+		SignerInspector1: fmt.Sprintf("Инспектор Энергоинспекции %s", fields["inspector1_initials"]),
+		SignerInspector2: fmt.Sprintf("Инспектор Энергоинспекции %s", fields["inspector2_initials"]),
+	}
+
+	result := make([]actSignature, 0, len(signatures))
+	for _, signer := range signers {
+		i := slices.IndexFunc(signatures, func(s Signature) bool { return s.Signer == signer })
+		if i < 0 {
+			continue
+		}
+
+		config, err := png.DecodeConfig(bytes.NewReader(signatures[i].Image))
+		if err != nil {
+			return nil, fmt.Errorf("decode signature of %s: %w", signer, err)
+		}
+
+		result = append(result, actSignature{
+			signer: signer,
+			label:  strings.TrimSpace(labels[signer]),
+			image:  signatures[i].Image,
+			width:  config.Width,
+			height: config.Height,
+		})
+	}
+
+	return result, nil
+}
+
+// placeholders returns the fields of the act with the signature placeholders filled: with the markers of
+// the signatures, surrounded by spaces so that they are separate words, or with blanks for missing signatures.
+func (a act) placeholders() docx.PlaceholderMap {
+	fields := maps.Clone(a.fields)
+	for _, signer := range signers {
+		fields[signer.placeholder()] = signatureBlank
+	}
+
+	for _, s := range a.signatures {
+		fields[s.signer.placeholder()] = " " + s.marker() + " "
+	}
+
+	return fields
+}
+
+// size returns the size of the signature scaled to the given height, narrowed to maxWidth if needed.
+func (s actSignature) size(height, maxWidth float64) (float64, float64) {
+	width := height * float64(s.width) / float64(s.height)
+	if width > maxWidth {
+		return maxWidth, maxWidth * float64(s.height) / float64(s.width)
+	}
+
+	return width, height
+}
+
+// rgba decodes the signature into RGB samples and an alpha channel.
+func (s actSignature) rgba() (rgb, alpha []byte, err error) {
+	img, err := png.Decode(bytes.NewReader(s.image))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := img.Bounds()
+	rgb = make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	alpha = make([]byte, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// Colors are premultiplied, PDF expects them straight.
+			if a != 0 {
+				r, g, b = r*0xFFFF/a, g*0xFFFF/a, b*0xFFFF/a
+			}
+			rgb = append(rgb, byte(r>>8), byte(g>>8), byte(b>>8))
+			alpha = append(alpha, byte(a>>8))
+		}
+	}
+
+	return rgb, alpha, nil
+}
+
+// Sizes of signatures in docx, in EMU.
+const (
+	docxSignatureHeight   = 540000  // 1.5 cm
+	docxSignatureMaxWidth = 2160000 // 6 cm
+)
+
+// embedDocxSignatures draws the signatures in place of their markers in the document body. Signatures without
+// a marker are appended to the end of the body, each in a paragraph with its label. The images are added
+// to the package as media parts.
+func embedDocxSignatures(document []byte, signatures []actSignature) (*bytes.Buffer, error) {
+	r, err := zip.NewReader(bytes.NewReader(document), int64(len(document)))
+	if err != nil {
+		return nil, fmt.Errorf("open docx: %w", err)
+	}
+
+	var (
+		buf  bytes.Buffer
+		w    = zip.NewWriter(&buf)
+		rels strings.Builder
+	)
+
+	for i := range signatures {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdSignature%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/signature%d.png"/>`,
+			i+1, i+1)
+	}
+
+	for _, part := range r.File {
+		content, err := readZipFile(part)
+		if err != nil {
+			return nil, err
+		}
+
+		switch part.Name {
+		case "word/document.xml":
+			content, err = placeDocxSignatures(content, signatures)
+		case "word/_rels/document.xml.rels":
+			content, err = insertBefore(content, "</Relationships>", "", rels.String())
+		case "[Content_Types].xml":
+			if !bytes.Contains(content, []byte(`Extension="png"`)) {
+				content, err = insertBefore(content, "<Default ", "</Types>", `<Default Extension="png" ContentType="image/png"/>`)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", part.Name, err)
+		}
+
+		f, err := w.Create(part.Name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", part.Name, err)
+		}
+		if _, err = f.Write(content); err != nil {
+			return nil, fmt.Errorf("write %s: %w", part.Name, err)
+		}
+	}
+
+	for i, s := range signatures {
+		name := fmt.Sprintf("word/media/signature%d.png", i+1)
+
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err = f.Write(s.image); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, fmt.Errorf("close docx: %w", err)
+	}
+
+	return &buf, nil
+}
+
+// placeDocxSignatures replaces the marker of every signature with its picture. The run with the marker is split
+// around the picture, and the text after it keeps the properties of the run.
+func placeDocxSignatures(document []byte, signatures []actSignature) ([]byte, error) {
+	var appended strings.Builder
+	for i, s := range signatures {
+		drawing := docxSignatureDrawing(s, i+1)
+
+		marker := bytes.Index(document, []byte(s.marker()))
+		if marker < 0 {
+			var label bytes.Buffer
+			_ = xml.EscapeText(&label, []byte(s.label))
+
+			fmt.Fprintf(&appended, `<w:p><w:r><w:t xml:space="preserve">%s </w:t></w:r><w:r>%s</w:r></w:p>`, label.String(), drawing)
+			continue
+		}
+
+		run := max(bytes.LastIndex(document[:marker], []byte("<w:r>")), bytes.LastIndex(document[:marker], []byte("<w:r ")))
+		text := bytes.LastIndex(document[:marker], []byte("<w:t"))
+		if run < 0 || text < run {
+			return nil, fmt.Errorf("signature of %s is not in a run", s.signer)
+		}
+
+		var properties []byte
+		if start := bytes.Index(document[run:text], []byte("<w:rPr>")); start >= 0 {
+			if end := bytes.Index(document[run:text], []byte("</w:rPr>")); end > start {
+				properties = document[run+start : run+end+len("</w:rPr>")]
+			}
+		}
+
+		split := fmt.Sprintf(`</w:t></w:r><w:r>%s</w:r><w:r>%s<w:t xml:space="preserve">`, drawing, properties)
+		document = slices.Concat(document[:marker], []byte(split), document[marker+len(s.marker()):])
+	}
+
+	if appended.Len() == 0 {
+		return document, nil
+	}
+
+	return insertBefore(document, "<w:sectPr", "</w:body>", appended.String())
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", f.Name, err)
+	}
+
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.Name, err)
+	}
+
+	return content, nil
+}
+
+// insertBefore inserts s before the last occurrence of marker, or of fallback when there is no marker.
+func insertBefore(content []byte, marker, fallback, s string) ([]byte, error) {
+	i := bytes.LastIndex(content, []byte(marker))
+	if i < 0 && fallback != "" {
+		i = bytes.LastIndex(content, []byte(fallback))
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("%s not found", marker)
+	}
+
+	return slices.Concat(content[:i], []byte(s), content[i:]), nil
+}
+
+// docxSignatureDrawing returns the inline picture of the n-th signature.
+func docxSignatureDrawing(s actSignature, n int) string {
+	cx, cy := s.size(docxSignatureHeight, docxSignatureMaxWidth)
+
+	return fmt.Sprintf(`<w:drawing>`+
+		`<wp:inline distT="0" distB="0" distL="0" distR="0" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="Signature %d"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">`+
+		`<a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%d" name="signature%d.png"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="rIdSignature%d" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"/>`+
+		`<a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing>`,
+		int(cx), int(cy), 10000+n, n, n, n, n, int(cx), int(cy))
+}
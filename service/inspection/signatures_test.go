@@ -0,0 +1,255 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lukasjarosch/go-docx"
+)
+
+// newTestSignature returns a PNG of a diagonal stroke on a transparent background.
+func newTestSignature(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		img.Set(x, x*height/width, color.NRGBA{B: 0x80, A: 0xFF})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode returned error: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func newTestSignedAct(t *testing.T) act {
+	t.Helper()
+
+	a, _ := newTestControlAct(t)
+
+	signatures, err := newActSignatures([]Signature{
+		{Signer: SignerInspector1, Image: newTestSignature(t, 300, 100)},
+		{Signer: SignerConsumer, Image: newTestSignature(t, 200, 100)},
+	}, a.fields)
+	if err != nil {
+		t.Fatalf("newActSignatures returned error: %v", err)
+	}
+
+	a.signatures = signatures
+
+	return a
+}
+
+func TestValidateSignaturesReportsInvalidSignatures(t *testing.T) {
+	image := newTestSignature(t, 200, 100)
+
+	var errs fieldErrors
+	validateSignatures(&errs, []Signature{
+		{Signer: SignerConsumer, Image: image},
+		{Signer: SignerConsumer, Image: image},
+		{Signer: "witness", Image: image},
+		{Signer: SignerInspector1, Image: []byte("GIF89a")},
+		{Signer: SignerInspector2, Image: newTestSignature(t, maxSignatureSide+1, 10)},
+	})
+
+	var validationErr ValidationError
+	if !errors.As(errs.err(), &validationErr) {
+		t.Fatalf("validateSignatures error = %v, want ValidationError", errs.err())
+	}
+
+	fields := make([]string, 0, len(validationErr.Fields))
+	for _, f := range validationErr.Fields {
+		fields = append(fields, f.Field)
+	}
+
+	want := []string{"Signatures[1].Signer", "Signatures[2].Signer", "Signatures[3].Image", "Signatures[4].Image"}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Fatalf("fields = %v, want %v", fields, want)
+	}
+}
+
+func TestNewActSignaturesOrdersAndLabelsSigners(t *testing.T) {
+	a := newTestSignedAct(t)
+
+	if len(a.signatures) != 2 {
+		t.Fatalf("len(a.signatures) = %d, want 2", len(a.signatures))
+	}
+	if !strings.HasPrefix(a.signatures[0].label, "Потребитель") {
+		t.Fatalf("first label = %q, want the consumer", a.signatures[0].label)
+	}
+	if !strings.Contains(a.signatures[1].label, "Инспектор Энергоинспекции Петров П.") {
+		t.Fatalf("second label = %q, want the first inspector", a.signatures[1].label)
+	}
+}
+
+func TestDocxRendererPlacesSignaturesAtPlaceholders(t *testing.T) {
+	template, err := os.ReadFile("templates/control_act.docx")
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	parts := renderTestDocxParts(t, template, newTestSignedAct(t))
+
+	if _, ok := parts["word/media/signature2.png"]; !ok {
+		t.Fatal("act has no signature images")
+	}
+	if !strings.Contains(parts["[Content_Types].xml"], `Extension="png"`) {
+		t.Fatal("content types do not list png")
+	}
+	if !strings.Contains(parts["word/_rels/document.xml.rels"], `Id="rIdSignature2"`) {
+		t.Fatal("document relationships do not reference signature images")
+	}
+
+	document := parts["word/document.xml"]
+	consumer := strings.Index(document, `r:embed="rIdSignature1"`)
+	inspector := strings.Index(document, `r:embed="rIdSignature2"`)
+	acquainted := strings.Index(document, "С актом ознакомлен")
+	if inspector < 0 || inspector > acquainted || consumer < acquainted || consumer > strings.LastIndex(document, "(подпись)") {
+		t.Fatal("signatures are not drawn at their placeholders")
+	}
+	if strings.Contains(document, "Инспектор Энергоинспекции Петров") || strings.Contains(document, "\ue000") {
+		t.Fatal("signatures are appended or their markers are left in the document")
+	}
+	if !strings.Contains(document, signatureBlank) {
+		t.Fatal("placeholder of the missing signature is not left blank")
+	}
+}
+
+func TestDocxRendererAppendsSignaturesWithoutPlaceholders(t *testing.T) {
+	template := rewriteTestTemplate(t, "templates/control_act.docx", "{consumer_signature}", "______________")
+	if err := validateActTemplate(actKindControl, template); err != nil {
+		t.Fatalf("validateActTemplate returned error: %v", err)
+	}
+
+	document := renderTestDocxParts(t, template, newTestSignedAct(t))["word/document.xml"]
+
+	label := strings.Index(document, "Потребитель")
+	consumer := strings.Index(document, `r:embed="rIdSignature1"`)
+	if consumer < strings.LastIndex(document, "(подпись)") || consumer > strings.LastIndex(document, "<w:sectPr") || label > consumer {
+		t.Fatal("signature without placeholder is not appended to the end of the body")
+	}
+	if !strings.Contains(document, `r:embed="rIdSignature2"`) {
+		t.Fatal("signature with placeholder is not drawn")
+	}
+}
+
+// renderTestDocxParts renders the act with the template and returns the parts of the docx package.
+func renderTestDocxParts(t *testing.T, template []byte, a act) map[string]string {
+	t.Helper()
+
+	buf, err := docxRenderer{template: template}.render(a)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	if _, err = docx.OpenBytes(buf.Bytes()); err != nil {
+		t.Fatalf("rendered act is not a docx document: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader returned error: %v", err)
+	}
+
+	parts := make(map[string]string, len(archive.File))
+	for _, f := range archive.File {
+		content, err := readZipFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts[f.Name] = string(content)
+	}
+
+	return parts
+}
+
+func TestPDFRendererDrawsSignatures(t *testing.T) {
+	font, chars := loadTestFont(t)
+	a := newTestSignedAct(t)
+
+	buf, err := pdfRenderer{font: font, template: readTestTemplate(t, a.kind)}.render(a)
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	data := buf.Bytes()
+	checkPDFCrossReferences(t, data)
+
+	if !bytes.Contains(data, []byte("/XObject << /Im1 ")) || !bytes.Contains(data, []byte("/SMask ")) {
+		t.Fatal("pages do not reference signature images with soft masks")
+	}
+
+	streams := pdfStreams(t, data)
+
+	var drawn int
+	for _, stream := range streams {
+		drawn += strings.Count(string(stream), " Do Q")
+	}
+	if drawn != 2 {
+		t.Fatalf("pdf draws %d images, want 2", drawn)
+	}
+
+	text := pdfText(t, streams, chars)
+	if strings.Contains(text, "ИнспекторЭнергоинспекцииПетров") || !strings.Contains(text, signatureBlank) {
+		t.Fatalf("signatures are not drawn at their placeholders: %s", text)
+	}
+}
+
+func TestActSignerDetectsChangedActs(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, ed25519.SeedSize))
+
+	signer, err := NewActSigner(seed)
+	if err != nil {
+		t.Fatalf("NewActSigner returned error: %v", err)
+	}
+
+	content := []byte("act")
+	signature := signer.sign(content)
+	if !signer.verify(content, signature) {
+		t.Fatal("signature of the act does not verify")
+	}
+	if signer.verify([]byte("changed act"), signature) {
+		t.Fatal("signature verifies a changed act")
+	}
+
+	key, err := (&Service{signer: signer}).GetActSigningKey()
+	if err != nil {
+		t.Fatalf("GetActSigningKey returned error: %v", err)
+	}
+
+	public, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || !ed25519.Verify(public, content, signature) {
+		t.Fatalf("published key %q does not verify act signatures", key.PublicKey)
+	}
+}
+
+func TestNewActSignerWithoutKeyDoesNotSign(t *testing.T) {
+	signer, err := NewActSigner("")
+	if err != nil {
+		t.Fatalf("NewActSigner returned error: %v", err)
+	}
+
+	if signer.Enabled() || signer.sign([]byte("act")) != nil {
+		t.Fatal("signer without a key signs acts")
+	}
+
+	if _, err = (&Service{signer: signer}).GetActSigningKey(); !errors.Is(err, ErrActSigningDisabled) {
+		t.Fatalf("GetActSigningKey error = %v, want %v", err, ErrActSigningDisabled)
+	}
+
+	if _, err = NewActSigner(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Fatal("NewActSigner accepted a short key")
+	}
+}
@@ -0,0 +1,64 @@
+package inspection
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+)
+
+// ActSigner signs act files with the Ed25519 key of the service. The signature is detached: it is stored with
+// the attachment, so a file changed after it was issued fails verification with the public key.
+type ActSigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewActSigner creates a signer from a base64 Ed25519 seed. Without a seed acts are not signed.
+func NewActSigner(seed string) (*ActSigner, error) {
+	if len(seed) == 0 {
+		return &ActSigner{}, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("decode act signing key: %w", err)
+	}
+
+	if len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("act signing key must be a %d byte seed, got %d bytes", ed25519.SeedSize, len(raw))
+	}
+
+	return &ActSigner{key: ed25519.NewKeyFromSeed(raw)}, nil
+}
+
+// Enabled reports whether acts are signed.
+func (s *ActSigner) Enabled() bool {
+	return s != nil && s.key != nil
+}
+
+// PublicKey returns the base64 Ed25519 public key that checks act signatures, or an empty string when signing
+// is disabled.
+func (s *ActSigner) PublicKey() string {
+	if !s.Enabled() {
+		return ""
+	}
+
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// sign returns the signature of the content, or nil when signing is disabled.
+func (s *ActSigner) sign(content []byte) []byte {
+	if !s.Enabled() {
+		return nil
+	}
+
+	return ed25519.Sign(s.key, content)
+}
+
+// verify reports whether the signature was made by the key of the signer for the content.
+func (s *ActSigner) verify(content, signature []byte) bool {
+	if !s.Enabled() {
+		return false
+	}
+
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), content, signature)
+}
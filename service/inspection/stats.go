@@ -0,0 +1,175 @@
+package inspection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sunshineOfficial/golib/goctx"
+)
+
+const brigadeBackfillBatchSize = 100
+
+type StatsGroupKey string
+
+const (
+	StatsGroupByType       StatsGroupKey = "type"
+	StatsGroupByResolution StatsGroupKey = "resolution"
+	StatsGroupByStatus     StatsGroupKey = "status"
+	StatsGroupByBrigade    StatsGroupKey = "brigade"
+)
+
+func (k StatsGroupKey) Validate() error {
+	switch k {
+	case StatsGroupByType, StatsGroupByResolution, StatsGroupByStatus, StatsGroupByBrigade:
+		return nil
+	default:
+		return fmt.Errorf("group key must be %q, %q, %q, or %q", StatsGroupByType, StatsGroupByResolution, StatsGroupByStatus, StatsGroupByBrigade)
+	}
+}
+
+// BackfillBrigades sets the brigade of inspections started before brigades were stored with them, so that
+// statistics by brigade count them. The brigade is taken from the task; inspections of tasks without one keep none.
+func (s *Service) BackfillBrigades(ctx context.Context) error {
+	var afterID int
+	for {
+		inspections, err := s.repository.GetWithoutBrigade(ctx, afterID, brigadeBackfillBatchSize)
+		if err != nil {
+			return fmt.Errorf("get inspections without brigade: %w", err)
+		}
+		if len(inspections) == 0 {
+			return nil
+		}
+
+		for _, ins := range inspections {
+			afterID = ins.ID
+
+			tsk, err := s.taskService.GetTaskByID(goctx.Wrap(ctx), ins.TaskID)
+			if err != nil {
+				return fmt.Errorf("get task by id: %w", upstream("task-service", err))
+			}
+			if tsk.BrigadeID == nil {
+				continue
+			}
+
+			if err = s.repository.SetBrigade(ctx, ins.ID, *tsk.BrigadeID); err != nil {
+				return fmt.Errorf("set brigade of inspection %d: %w", ins.ID, err)
+			}
+		}
+	}
+}
+
+// StatsPeriod is the length of time buckets of statistics. Buckets start at midnight in Moscow,
+// weeks start on Monday.
+type StatsPeriod string
+
+const (
+	StatsPeriodDay   StatsPeriod = "day"
+	StatsPeriodWeek  StatsPeriod = "week"
+	StatsPeriodMonth StatsPeriod = "month"
+)
+
+func (p StatsPeriod) Validate() error {
+	switch p {
+	case "", StatsPeriodDay, StatsPeriodWeek, StatsPeriodMonth:
+		return nil
+	default:
+		return fmt.Errorf("period must be empty, %q, %q, or %q", StatsPeriodDay, StatsPeriodWeek, StatsPeriodMonth)
+	}
+}
+
+// StatsRequest selects inspections by Filter and splits them into groups by GroupBy keys
+// and by Period of the inspection start. Without keys and period there is a single group.
+type StatsRequest struct {
+	Filter  Filter
+	GroupBy []StatsGroupKey
+	Period  StatsPeriod
+}
+
+func (r StatsRequest) Validate() error {
+	if err := r.Filter.Validate(); err != nil {
+		return err
+	}
+
+	var errs fieldErrors
+
+	seen := make(map[StatsGroupKey]struct{}, len(r.GroupBy))
+	for _, key := range r.GroupBy {
+		if err := key.Validate(); err != nil {
+			errs.add("groupBy", "%s", err)
+		}
+		if _, dup := seen[key]; dup {
+			errs.add("groupBy", "%q is listed more than once", key)
+		}
+		seen[key] = struct{}{}
+	}
+
+	if err := r.Period.Validate(); err != nil {
+		errs.add("period", "%s", err)
+	}
+
+	return errs.err()
+}
+
+// Stats holds totals of every group and of all selected inspections.
+type Stats struct {
+	Groups []StatsGroup `json:"Groups"`
+	Total  StatsTotals  `json:"Total"`
+}
+
+// StatsGroup has a value for each key the statistics are grouped by. A key that is not grouped by,
+// or has no value like Type of an inspection in work, is omitted.
+type StatsGroup struct {
+	Type       *Type       `json:"Type,omitempty"`
+	Resolution *Resolution `json:"Resolution,omitempty"`
+	Status     *Status     `json:"Status,omitempty"`
+	BrigadeID  *int        `json:"BrigadeID,omitempty"`
+	Period     *time.Time  `json:"Period,omitempty"`
+	StatsTotals
+}
+
+// StatsTotals counts inspections of a group. Finished inspections are the ones with results;
+// AverageDurationSeconds is the mean time from their start to the last submission of results.
+type StatsTotals struct {
+	Count                  int     `json:"Count"`
+	ViolationCount         int     `json:"ViolationCount"`
+	ViolationShare         float64 `json:"ViolationShare"`
+	FinishedCount          int     `json:"FinishedCount"`
+	AverageDurationSeconds float64 `json:"AverageDurationSeconds"`
+}
+
+func NewStatsTotals(count, violationCount, finishedCount int, averageDurationSeconds float64) StatsTotals {
+	t := StatsTotals{
+		Count:                  count,
+		ViolationCount:         violationCount,
+		FinishedCount:          finishedCount,
+		AverageDurationSeconds: averageDurationSeconds,
+	}
+
+	if count > 0 {
+		t.ViolationShare = float64(violationCount) / float64(count)
+	}
+
+	return t
+}
+
+func (t StatsTotals) add(other StatsTotals) StatsTotals {
+	finished := t.FinishedCount + other.FinishedCount
+
+	var average float64
+	if finished > 0 {
+		duration := t.AverageDurationSeconds*float64(t.FinishedCount) + other.AverageDurationSeconds*float64(other.FinishedCount)
+		average = duration / float64(finished)
+	}
+
+	return NewStatsTotals(t.Count+other.Count, t.ViolationCount+other.ViolationCount, finished, average)
+}
+
+func newStats(groups []StatsGroup) Stats {
+	stats := Stats{Groups: groups}
+	for _, g := range groups {
+		stats.Total = stats.Total.add(g.StatsTotals)
+	}
+
+	return stats
+}
@@ -0,0 +1,78 @@
+package inspection
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	clustertask "inspection-service/cluster/task"
+
+	"github.com/sunshineOfficial/golib/goctx"
+)
+
+func TestStatsRequestValidateReportsGroupKeysAndPeriod(t *testing.T) {
+	err := StatsRequest{
+		GroupBy: []StatsGroupKey{StatsGroupByType, "inspector", StatsGroupByType},
+		Period:  "year",
+	}.Validate()
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate error = %v, want ValidationError", err)
+	}
+	if len(validationErr.Fields) != 3 {
+		t.Fatalf("fields = %+v, want 3 fields", validationErr.Fields)
+	}
+}
+
+func TestGetStatsSumsGroupsIntoTotal(t *testing.T) {
+	brigadeID := 7
+	repository := &repositoryMock{
+		statsGroups: []StatsGroup{
+			{BrigadeID: &brigadeID, StatsTotals: NewStatsTotals(4, 1, 2, 600)},
+			{StatsTotals: NewStatsTotals(6, 2, 4, 900)},
+		},
+	}
+	service := &Service{repository: repository}
+
+	request := StatsRequest{GroupBy: []StatsGroupKey{StatsGroupByBrigade}, Period: StatsPeriodMonth}
+	got, err := service.GetStats(goctx.Wrap(context.Background()), request)
+	if err != nil {
+		t.Fatalf("GetStats returned error: %v", err)
+	}
+
+	want := StatsTotals{Count: 10, ViolationCount: 3, ViolationShare: 0.3, FinishedCount: 6, AverageDurationSeconds: 800}
+	if got.Total != want {
+		t.Fatalf("Total = %+v, want %+v", got.Total, want)
+	}
+	if got.Groups[0].ViolationShare != 0.25 {
+		t.Fatalf("Groups[0].ViolationShare = %v, want 0.25", got.Groups[0].ViolationShare)
+	}
+	if repository.gotStatsRequest.Period != StatsPeriodMonth {
+		t.Fatalf("repository request = %+v, want %+v", repository.gotStatsRequest, request)
+	}
+}
+
+func TestBackfillBrigadesTakesBrigadeFromTask(t *testing.T) {
+	brigadeID := 3
+	repository := &repositoryMock{
+		withoutBrigade: []Inspection{{ID: 1, TaskID: 10}, {ID: 2, TaskID: 20}, {ID: 3, TaskID: 30}},
+	}
+	service := &Service{
+		repository: repository,
+		taskService: &taskServiceMock{tasksByID: map[int]clustertask.Task{
+			10: {ID: 10, BrigadeID: &brigadeID},
+			20: {ID: 20},
+			30: {ID: 30, BrigadeID: &brigadeID},
+		}},
+	}
+
+	if err := service.BackfillBrigades(context.Background()); err != nil {
+		t.Fatalf("BackfillBrigades returned error: %v", err)
+	}
+
+	if want := map[int]int{1: 3, 3: 3}; !maps.Equal(repository.brigades, want) {
+		t.Fatalf("repository.brigades = %v, want %v", repository.brigades, want)
+	}
+}
@@ -0,0 +1,68 @@
+package inspection
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// transitions lists the statuses an inspection may move to from each status.
+// Done and Cancelled are terminal.
+var transitions = map[Status][]Status{
+	StatusInWork:    {StatusSubmitted, StatusCancelled},
+	StatusSubmitted: {StatusApproved, StatusRejected, StatusCancelled},
+	StatusRejected:  {StatusSubmitted, StatusCancelled},
+	StatusApproved:  {StatusDone},
+}
+
+func (s Status) CanTransitionTo(to Status) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// IsEditable reports whether photos and results may still be added to the inspection.
+func (s Status) IsEditable() bool {
+	return s == StatusInWork || s == StatusRejected
+}
+
+func (s Status) String() string {
+	switch s {
+	case StatusInWork:
+		return "InWork"
+	case StatusDone:
+		return "Done"
+	case StatusSubmitted:
+		return "Submitted"
+	case StatusApproved:
+		return "Approved"
+	case StatusRejected:
+		return "Rejected"
+	case StatusCancelled:
+		return "Cancelled"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// transition moves the locked inspection from one status to another and records the change in the status history.
+func transition(ctx context.Context, tx Repository, inspectionID int, from, to Status, userID int, comment *string) error {
+	if !from.CanTransitionTo(to) {
+		return TransitionError{From: from, To: to}
+	}
+
+	if err := tx.UpdateStatus(ctx, inspectionID, to); err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+
+	err := tx.AddStatusChange(ctx, StatusChange{
+		InspectionID: inspectionID,
+		From:         &from,
+		To:           to,
+		UserID:       userID,
+		Comment:      comment,
+	})
+	if err != nil {
+		return fmt.Errorf("add status change: %w", err)
+	}
+
+	return nil
+}
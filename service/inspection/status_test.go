@@ -0,0 +1,31 @@
+package inspection
+
+import "testing"
+
+func TestStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from Status
+		to   Status
+		want bool
+	}{
+		{from: StatusInWork, to: StatusSubmitted, want: true},
+		{from: StatusInWork, to: StatusCancelled, want: true},
+		{from: StatusInWork, to: StatusDone, want: false},
+		{from: StatusSubmitted, to: StatusApproved, want: true},
+		{from: StatusSubmitted, to: StatusRejected, want: true},
+		{from: StatusSubmitted, to: StatusSubmitted, want: false},
+		{from: StatusRejected, to: StatusSubmitted, want: true},
+		{from: StatusApproved, to: StatusDone, want: true},
+		{from: StatusApproved, to: StatusRejected, want: false},
+		{from: StatusDone, to: StatusInWork, want: false},
+		{from: StatusCancelled, to: StatusInWork, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+" -> "+tt.to.String(), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Fatalf("CanTransitionTo = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
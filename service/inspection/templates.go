@@ -0,0 +1,333 @@
+package inspection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukasjarosch/go-docx"
+	"github.com/sunshineOfficial/golib/goctx"
+)
+
+// maxActTemplateSize limits the size of an uploaded act template.
+const maxActTemplateSize = 10 << 20
+
+// ActTemplate is a version of the docx template of the acts of an inspection type. Versions never change:
+// an upload becomes the next version of its type and is used for acts generated after it.
+type ActTemplate struct {
+	ID        int       `json:"ID"`
+	Type      Type      `json:"Type"`
+	Version   int       `json:"Version"`
+	FileName  string    `json:"FileName"`
+	CreatedBy int       `json:"CreatedBy"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	Content   []byte    `json:"-"`
+}
+
+type UploadActTemplateRequest struct {
+	Type       Type
+	FileHeader *multipart.FileHeader
+}
+
+// actTypes are the inspection types that have acts, in the order templates are initialized.
+var actTypes = []Type{TypeLimitation, TypeResumption, TypeVerification, TypeUnauthorizedConnection}
+
+// actKindOf returns the kind of the act of an inspection type.
+func actKindOf(t Type) (actKind, error) {
+	switch t {
+	case TypeLimitation, TypeResumption:
+		return actKindUniversal, nil
+	case TypeVerification, TypeUnauthorizedConnection:
+		return actKindControl, nil
+	default:
+		return 0, fmt.Errorf("unknown inspection type %d", t)
+	}
+}
+
+// actPlaceholders lists the placeholders filled by the generator of each act kind. Row placeholders are filled
+// once per inspected device and must share a table row. Signature placeholders of every signer are filled
+// for both kinds by the renderers.
+var actPlaceholders = map[actKind]struct{ fields, rows []string }{
+	actKindUniversal: {
+		fields: []string{
+			"act_number", "is_limitation", "is_resumption", "act_day", "act_month", "act_year", "act_hour", "act_minute",
+			"act_place", "consumer_fio", "address", "have_automaton", "no_automaton", "account_number", "is_incomplete_payment",
+			"is_other_reason", "other_reason", "is_energy_limited", "is_energy_stopped", "is_energy_resumed", "energy_hour",
+			"energy_minute", "energy_day", "energy_month", "energy_year", "is_by_consumer", "is_by_inspector", "method",
+			"is_inside", "is_outside", "other_place", "is_consumer_limited", "is_inspector_limited", "is_not_introduced",
+			"is_not_introduced_reason", "inspector1_initials", "inspector2_initials",
+		},
+		rows: []string{"device_type", "device_number", "device_value", "seals"},
+	},
+	actKindControl: {
+		fields: []string{
+			"act_number", "is_verification", "is_unauthorized_connection", "act_day", "act_month", "act_year", "act_hour",
+			"act_minute", "act_place", "consumer_fio", "address", "have_automaton", "no_automaton", "account_number",
+			"consumer_phone", "is_incomplete_payment", "is_other_reason", "other_reason", "is_energy_limited",
+			"is_energy_stopped", "energy_hour", "energy_minute", "energy_day", "energy_month", "energy_year", "is_by_consumer",
+			"is_by_inspector", "is_checked", "check_hour", "check_minute", "check_day", "check_month", "check_year",
+			"is_violation_not_detected", "is_violation_detected", "is_expense_available", "is_other_violation",
+			"other_violation", "is_not_unauthorized_consumers", "is_unauthorized_consumers", "unauthorized_description",
+			"is_inside", "is_outside", "other_place", "old_value_day", "old_value_month", "old_value_year",
+			"unauthorized_explanation", "inspector1_initials", "inspector2_initials",
+		},
+		rows: []string{"device_type", "device_number", "device_value", "old_device_value", "device_consumption", "seals"},
+	},
+}
+
+// validateActTemplate checks that the template has every placeholder the generator of the act kind fills and no others,
+// and that the row placeholders can be repeated for every device. Signature placeholders may be missing, then
+// signatures are placed at the end of the act.
+func validateActTemplate(kind actKind, content []byte) error {
+	var errs fieldErrors
+
+	doc, err := docx.OpenBytes(content)
+	if err != nil {
+		errs.add("File", "is not a docx document: %s", err)
+		return errs.err()
+	}
+
+	defer doc.Close()
+
+	list, err := doc.GetPlaceHoldersList()
+	if err != nil {
+		errs.add("File", "cannot read placeholders: %s", err)
+		return errs.err()
+	}
+
+	found := make(map[string]bool, len(list))
+	for _, placeholder := range list {
+		found[docx.RemovePlaceholderDelimiter(placeholder)] = true
+	}
+
+	placeholders := actPlaceholders[kind]
+	expected := slices.Concat(placeholders.fields, placeholders.rows)
+	for _, key := range expected {
+		if !found[key] {
+			errs.add("File", "placeholder %s is missing", docx.AddPlaceholderDelimiter(key))
+		}
+	}
+
+	known := slices.Clone(expected)
+	for _, signer := range signers {
+		known = append(known, signer.placeholder())
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(found)) {
+		if !slices.Contains(known, key) {
+			errs.add("File", "placeholder %s is unknown", docx.AddPlaceholderDelimiter(key))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs.err()
+	}
+
+	document := string(doc.GetFile(docx.DocumentXml))
+
+	rowStart, rowEnd, err := findTableRow(document, docx.AddPlaceholderDelimiter(placeholders.rows[0]))
+	if err != nil {
+		errs.add("File", "%s", err)
+		return errs.err()
+	}
+
+	for _, key := range placeholders.rows {
+		if placeholder := docx.AddPlaceholderDelimiter(key); !strings.Contains(document[rowStart:rowEnd], placeholder) {
+			errs.add("File", "placeholder %s is not in the device table row or is split between runs", placeholder)
+		}
+	}
+
+	return errs.err()
+}
+
+// actTemplateCache keeps template contents by ID. Versions never change, so entries are never invalidated.
+type actTemplateCache struct {
+	mu       sync.Mutex
+	contents map[int][]byte
+}
+
+func newActTemplateCache() *actTemplateCache {
+	return &actTemplateCache{contents: make(map[int][]byte)}
+}
+
+func (c *actTemplateCache) get(id int) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, ok := c.contents[id]
+
+	return content, ok
+}
+
+func (c *actTemplateCache) put(id int, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.contents[id] = content
+}
+
+// InitActTemplates loads the font of PDF acts, stores the configured templates as the first versions of inspection
+// types that have none yet and validates the latest version of every type, so that the service does not start
+// with a missing font or a broken template.
+func (s *Service) InitActTemplates(ctx context.Context) error {
+	font, err := loadTrueType(s.templates.Font)
+	if err != nil {
+		return fmt.Errorf("load pdf font: %w", err)
+	}
+
+	s.pdfFont = font
+
+	for _, t := range actTypes {
+		kind, _ := actKindOf(t)
+
+		latest, err := s.repository.GetLatestActTemplate(ctx, t)
+		if errors.Is(err, sql.ErrNoRows) {
+			latest, err = s.seedActTemplate(ctx, t, kind)
+		}
+		if err != nil {
+			return fmt.Errorf("get act template of type %d: %w", t, err)
+		}
+
+		latest, err = s.withContent(ctx, latest)
+		if err != nil {
+			return err
+		}
+
+		if err = validateActTemplate(kind, latest.Content); err != nil {
+			return fmt.Errorf("act template %s version %d of type %d: %w", latest.FileName, latest.Version, t, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) seedActTemplate(ctx context.Context, t Type, kind actKind) (ActTemplate, error) {
+	path := s.templates.Universal
+	if kind == actKindControl {
+		path = s.templates.Control
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("read template: %w", err)
+	}
+
+	template, err := s.repository.AddActTemplate(ctx, ActTemplate{Type: t, FileName: filepath.Base(path), Content: content})
+	if err != nil {
+		// Another instance may have stored the template first.
+		if latest, latestErr := s.repository.GetLatestActTemplate(ctx, t); latestErr == nil {
+			return latest, nil
+		}
+
+		return ActTemplate{}, fmt.Errorf("add act template: %w", err)
+	}
+
+	return template, nil
+}
+
+// actTemplate returns the latest template of the inspection type with its content.
+func (s *Service) actTemplate(ctx context.Context, t Type) (ActTemplate, error) {
+	template, err := s.repository.GetLatestActTemplate(ctx, t)
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("get latest act template: %w", err)
+	}
+
+	return s.withContent(ctx, template)
+}
+
+func (s *Service) withContent(ctx context.Context, template ActTemplate) (ActTemplate, error) {
+	if content, ok := s.actTemplates.get(template.ID); ok {
+		template.Content = content
+		return template, nil
+	}
+
+	content, err := s.repository.GetActTemplateContent(ctx, template.ID)
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("get act template content: %w", err)
+	}
+
+	s.actTemplates.put(template.ID, content)
+	template.Content = content
+
+	return template, nil
+}
+
+// GetActTemplates returns all template versions, the latest version of each type first. Only admins see them.
+func (s *Service) GetActTemplates(ctx goctx.Context) ([]ActTemplate, error) {
+	if !s.isAdmin(ctx) {
+		return nil, ErrAdminRequired
+	}
+
+	templates, err := s.repository.GetActTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get act templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// UploadActTemplate validates the template against the generator of the inspection type and stores it
+// as the next version of the type. Only admins may upload templates.
+func (s *Service) UploadActTemplate(ctx goctx.Context, request UploadActTemplateRequest) (ActTemplate, error) {
+	if !s.isAdmin(ctx) {
+		return ActTemplate{}, ErrAdminRequired
+	}
+
+	kind, err := actKindOf(request.Type)
+	if err != nil {
+		var errs fieldErrors
+		errs.add("Type", "%s", err)
+		return ActTemplate{}, errs.err()
+	}
+
+	if request.FileHeader.Size > maxActTemplateSize {
+		var errs fieldErrors
+		errs.add("File", "must not exceed %d bytes", maxActTemplateSize)
+		return ActTemplate{}, errs.err()
+	}
+
+	f, err := request.FileHeader.Open()
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("open template: %w", err)
+	}
+
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxActTemplateSize))
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("read template: %w", err)
+	}
+
+	if err = validateActTemplate(kind, content); err != nil {
+		return ActTemplate{}, err
+	}
+
+	template, err := s.repository.AddActTemplate(ctx, ActTemplate{
+		Type:      request.Type,
+		FileName:  request.FileHeader.Filename,
+		CreatedBy: ctx.Authorize.UserId,
+		Content:   content,
+	})
+	if err != nil {
+		return ActTemplate{}, fmt.Errorf("add act template: %w", err)
+	}
+
+	s.actTemplates.put(template.ID, content)
+	template.Content = content
+
+	return template, nil
+}
+
+func (s *Service) isAdmin(ctx goctx.Context) bool {
+	return slices.Contains(s.access.Admins, ctx.Authorize.UserId)
+}
@@ -0,0 +1,236 @@
+package inspection
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"mime/multipart"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	clusterbrigade "inspection-service/cluster/brigade"
+	clustersubscriber "inspection-service/cluster/subscriber"
+
+	"github.com/sunshineOfficial/golib/gotime"
+)
+
+func TestActPlaceholdersMatchGenerators(t *testing.T) {
+	control, _ := newTestControlAct(t)
+
+	universal, err := newUniversalAct(newFinishTestRequest(), "7", clusterbrigade.Brigade{
+		Inspectors: []clusterbrigade.Inspector{{Surname: "Петров", Name: "Петр"}, {Surname: "Сидоров", Name: "Сидор"}},
+	}, clustersubscriber.Contract{
+		Object: clustersubscriber.Object{
+			Devices: []clustersubscriber.Device{
+				{ID: 11, PlaceType: clustersubscriber.DevicePlaceFlat, Seals: []clustersubscriber.Seal{{ID: 21, DeviceID: 11}}},
+			},
+		},
+	}, gotime.MoscowNow())
+	if err != nil {
+		t.Fatalf("newUniversalAct returned error: %v", err)
+	}
+
+	for _, a := range []act{universal, control} {
+		placeholders := actPlaceholders[a.kind]
+
+		if got, want := slices.Sorted(maps.Keys(a.fields)), slices.Sorted(slices.Values(placeholders.fields)); !slices.Equal(got, want) {
+			t.Fatalf("act %d fields = %v, want %v", a.kind, got, want)
+		}
+		if got, want := slices.Sorted(maps.Keys(a.rows[0])), slices.Sorted(slices.Values(placeholders.rows)); !slices.Equal(got, want) {
+			t.Fatalf("act %d rows = %v, want %v", a.kind, got, want)
+		}
+	}
+}
+
+func TestValidateActTemplateAcceptsBundledTemplates(t *testing.T) {
+	for kind, path := range map[actKind]string{actKindUniversal: "templates/universal_act.docx", actKindControl: "templates/control_act.docx"} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read template: %v", err)
+		}
+
+		if err = validateActTemplate(kind, content); err != nil {
+			t.Fatalf("validateActTemplate(%s) returned error: %v", path, err)
+		}
+	}
+}
+
+func TestValidateActTemplateReportsPlaceholders(t *testing.T) {
+	content := rewriteTestTemplate(t, "templates/universal_act.docx", "{act_place}", "{place}")
+
+	err := validateActTemplate(actKindUniversal, content)
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("validateActTemplate error = %v, want ValidationError", err)
+	}
+
+	var messages []string
+	for _, f := range validationErr.Fields {
+		messages = append(messages, f.Message)
+	}
+
+	want := []string{"placeholder {act_place} is missing", "placeholder {place} is unknown"}
+	if !slices.Equal(messages, want) {
+		t.Fatalf("messages = %v, want %v", messages, want)
+	}
+}
+
+func TestUploadActTemplateIsUsedForNextActs(t *testing.T) {
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	content := rewriteTestTemplate(t, "templates/universal_act.docx", "АКТ", "АКТ (ред. 2)")
+
+	ctx := newAdminContext()
+
+	template, err := service.UploadActTemplate(ctx, UploadActTemplateRequest{
+		Type:       TypeLimitation,
+		FileHeader: newTestFileHeader(t, "universal_v2.docx", content),
+	})
+	if err != nil {
+		t.Fatalf("UploadActTemplate returned error: %v", err)
+	}
+
+	if template.Version != 2 || template.CreatedBy != testAdminID {
+		t.Fatalf("template = %+v, want version 2 uploaded by the admin", template)
+	}
+
+	loads := repository.contentLoads
+
+	latest, err := service.actTemplate(ctx, TypeLimitation)
+	if err != nil {
+		t.Fatalf("actTemplate returned error: %v", err)
+	}
+
+	if latest.ID != template.ID || !bytes.Equal(latest.Content, content) {
+		t.Fatalf("latest template = %d, want uploaded %d", latest.ID, template.ID)
+	}
+	if repository.contentLoads != loads {
+		t.Fatalf("content of the uploaded template was loaded from the repository, want cached")
+	}
+}
+
+func TestUploadActTemplateRejectsInvalidTemplate(t *testing.T) {
+	service := newFinishTestService(t, &repositoryMock{}, &fileServiceMock{})
+
+	content, err := os.ReadFile("templates/universal_act.docx")
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	_, err = service.UploadActTemplate(newAdminContext(), UploadActTemplateRequest{
+		Type:       TypeVerification,
+		FileHeader: newTestFileHeader(t, "universal.docx", content),
+	})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("UploadActTemplate error = %v, want ErrValidation", err)
+	}
+}
+
+func TestActTemplatesRequireAdmin(t *testing.T) {
+	repository := &repositoryMock{}
+	service := newFinishTestService(t, repository, &fileServiceMock{})
+
+	content, err := os.ReadFile("templates/universal_act.docx")
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	added := len(repository.actTemplates)
+
+	_, err = service.UploadActTemplate(newReviewerContext(), UploadActTemplateRequest{
+		Type:       TypeLimitation,
+		FileHeader: newTestFileHeader(t, "universal.docx", content),
+	})
+	if !errors.Is(err, ErrAdminRequired) {
+		t.Fatalf("UploadActTemplate error = %v, want ErrAdminRequired", err)
+	}
+	if len(repository.actTemplates) != added {
+		t.Fatal("template of a non-admin was stored")
+	}
+
+	if _, err = service.GetActTemplates(newReviewerContext()); !errors.Is(err, ErrAdminRequired) {
+		t.Fatalf("GetActTemplates error = %v, want ErrAdminRequired", err)
+	}
+}
+
+// rewriteTestTemplate returns the template with old replaced by new in its document.
+func rewriteTestTemplate(t *testing.T, path, old, new string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("zip.NewReader returned error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+
+		if f.Name == "word/document.xml" {
+			data = []byte(strings.ReplaceAll(string(data), old, new))
+		}
+
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			t.Fatalf("create %s: %v", f.Name, err)
+		}
+		if _, err = fw.Write(data); err != nil {
+			t.Fatalf("write %s: %v", f.Name, err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatalf("close template: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func newTestFileHeader(t testing.TB, name string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	fw, err := w.CreateFormFile("File", name)
+	if err != nil {
+		t.Fatalf("CreateFormFile returned error: %v", err)
+	}
+	if _, err = fw.Write(content); err != nil {
+		t.Fatalf("write form file: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(int64(len(content)) + 1<<20)
+	if err != nil {
+		t.Fatalf("ReadForm returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = form.RemoveAll() })
+
+	return form.File["File"][0]
+}
@@ -0,0 +1,422 @@
+package inspection
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"slices"
+)
+
+// trueTypeFont holds the metrics of a TrueType font that are needed to lay out text and the tables that are needed
+// to embed a subset of the font into PDF.
+type trueTypeFont struct {
+	tables     map[string][]byte
+	glyphData  [][]byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	advances   []int
+	glyphs     map[rune]uint16
+}
+
+var errTrueTypeTruncated = errors.New("font file is truncated")
+
+// loadTrueType reads and parses the font at path.
+func loadTrueType(path string) (*trueTypeFont, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read font: %w", err)
+	}
+
+	font, err := parseTrueType(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", path, err)
+	}
+
+	return font, nil
+}
+
+func parseTrueType(data []byte) (*trueTypeFont, error) {
+	tables, err := trueTypeTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"head", "hhea", "hmtx", "cmap", "maxp", "loca", "glyf"} {
+		if _, ok := tables[name]; !ok {
+			return nil, fmt.Errorf("font has no %s table", name)
+		}
+	}
+
+	f := &trueTypeFont{tables: tables}
+
+	head := tables["head"]
+	if len(head) < 54 {
+		return nil, errTrueTypeTruncated
+	}
+
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, errors.New("font has zero units per em")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+
+	hhea := tables["hhea"]
+	if len(hhea) < 36 {
+		return nil, errTrueTypeTruncated
+	}
+
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := tables["hmtx"]
+	if metrics == 0 || len(hmtx) < 4*metrics {
+		return nil, errTrueTypeTruncated
+	}
+
+	f.advances = make([]int, metrics)
+	for i := range f.advances {
+		f.advances[i] = int(binary.BigEndian.Uint16(hmtx[4*i:]))
+	}
+
+	if f.glyphs, err = parseCmap(tables["cmap"]); err != nil {
+		return nil, fmt.Errorf("parse cmap: %w", err)
+	}
+
+	if f.glyphData, err = parseGlyphs(tables["maxp"], tables["loca"], tables["glyf"], int(int16(binary.BigEndian.Uint16(head[50:])))); err != nil {
+		return nil, fmt.Errorf("parse glyphs: %w", err)
+	}
+
+	return f, nil
+}
+
+// parseGlyphs splits the glyf table into the outlines of every glyph by the offsets in loca of the given format.
+func parseGlyphs(maxp, loca, glyf []byte, locaFormat int) ([][]byte, error) {
+	if len(maxp) < 6 {
+		return nil, errTrueTypeTruncated
+	}
+
+	count := int(binary.BigEndian.Uint16(maxp[4:]))
+
+	offset := func(i int) int {
+		if locaFormat == 0 {
+			return 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+
+		return int(binary.BigEndian.Uint32(loca[4*i:]))
+	}
+
+	if size := (count + 1) * 2 * (locaFormat + 1); len(loca) < size {
+		return nil, errTrueTypeTruncated
+	}
+
+	glyphs := make([][]byte, count)
+	for i := range glyphs {
+		start, end := offset(i), offset(i+1)
+		if start > end || end > len(glyf) {
+			return nil, errTrueTypeTruncated
+		}
+
+		glyphs[i] = glyf[start:end]
+	}
+
+	return glyphs, nil
+}
+
+func trueTypeTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errTrueTypeTruncated
+	}
+
+	version := binary.BigEndian.Uint32(data)
+	if version != 0x00010000 && version != 0x74727565 {
+		return nil, errors.New("not a TrueType font")
+	}
+
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*count {
+		return nil, errTrueTypeTruncated
+	}
+
+	tables := make(map[string][]byte, count)
+	for i := range count {
+		record := data[12+16*i:]
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset+length > len(data) {
+			return nil, errTrueTypeTruncated
+		}
+
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+
+	return tables, nil
+}
+
+// parseCmap reads the Windows Unicode BMP subtable, which every font usable for Russian text has.
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errTrueTypeTruncated
+	}
+
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	if len(cmap) < 4+8*count {
+		return nil, errTrueTypeTruncated
+	}
+
+	for i := range count {
+		record := cmap[4+8*i:]
+		platform := binary.BigEndian.Uint16(record)
+		encoding := binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if platform != 3 || encoding != 1 || offset+4 > len(cmap) {
+			continue
+		}
+
+		if format := binary.BigEndian.Uint16(cmap[offset:]); format != 4 {
+			return nil, fmt.Errorf("unsupported cmap format %d", format)
+		}
+
+		return parseCmapFormat4(cmap[offset:])
+	}
+
+	return nil, errors.New("font has no Windows Unicode cmap")
+}
+
+func parseCmapFormat4(table []byte) (map[rune]uint16, error) {
+	if len(table) < 14 {
+		return nil, errTrueTypeTruncated
+	}
+
+	segments := int(binary.BigEndian.Uint16(table[6:])) / 2
+	endCodes := 14
+	startCodes := endCodes + 2*segments + 2
+	deltas := startCodes + 2*segments
+	rangeOffsets := deltas + 2*segments
+	if len(table) < rangeOffsets+2*segments {
+		return nil, errTrueTypeTruncated
+	}
+
+	glyphs := make(map[rune]uint16)
+	for i := range segments {
+		end := int(binary.BigEndian.Uint16(table[endCodes+2*i:]))
+		start := int(binary.BigEndian.Uint16(table[startCodes+2*i:]))
+		delta := binary.BigEndian.Uint16(table[deltas+2*i:])
+		rangeOffsetPos := rangeOffsets + 2*i
+		rangeOffset := int(binary.BigEndian.Uint16(table[rangeOffsetPos:]))
+
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			var glyph uint16
+			if rangeOffset == 0 {
+				glyph = uint16(c) + delta
+			} else {
+				pos := rangeOffsetPos + rangeOffset + 2*(c-start)
+				if pos+2 > len(table) {
+					return nil, errTrueTypeTruncated
+				}
+
+				if glyph = binary.BigEndian.Uint16(table[pos:]); glyph != 0 {
+					glyph += delta
+				}
+			}
+
+			if glyph != 0 {
+				glyphs[rune(c)] = glyph
+			}
+		}
+	}
+
+	return glyphs, nil
+}
+
+// glyph returns the glyph of r, or the missing glyph 0 when the font has none.
+func (f *trueTypeFont) glyph(r rune) uint16 {
+	return f.glyphs[r]
+}
+
+// advance returns the advance width of the glyph in thousandths of the font size.
+func (f *trueTypeFont) advance(glyph uint16) int {
+	i := int(glyph)
+	if i >= len(f.advances) {
+		// Glyphs after the last metric share its advance width.
+		i = len(f.advances) - 1
+	}
+
+	return f.scale(f.advances[i])
+}
+
+func (f *trueTypeFont) scale(units int) int {
+	return units * 1000 / f.unitsPerEm
+}
+
+// width returns the width of s set in the font of the given size.
+func (f *trueTypeFont) width(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		total += f.advance(f.glyph(r))
+	}
+
+	return float64(total) * size / 1000
+}
+
+// Flags of a component of a composite glyph.
+const (
+	glyphArgsAreWords   = 0x0001
+	glyphHaveScale      = 0x0008
+	glyphMoreComponents = 0x0020
+	glyphHaveXYScale    = 0x0040
+	glyphHaveTwoByTwo   = 0x0080
+)
+
+// subset returns the font with the outlines of the glyphs only, and of the glyphs they are composed of.
+// Other glyphs keep their numbers but have no outline, so PDF can still address glyphs by their numbers
+// in the whole font. Tables that PDF readers do not use are dropped.
+func (f *trueTypeFont) subset(glyphs []uint16) ([]byte, error) {
+	keep := make(map[int]bool, len(glyphs)+1)
+	queue := []int{0}
+	for _, g := range glyphs {
+		queue = append(queue, int(g))
+	}
+
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		if keep[g] || g >= len(f.glyphData) {
+			continue
+		}
+
+		keep[g] = true
+
+		components, err := glyphComponents(f.glyphData[g])
+		if err != nil {
+			return nil, fmt.Errorf("glyph %d: %w", g, err)
+		}
+
+		queue = append(queue, components...)
+	}
+
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(len(f.glyphData)+1))
+	for i, data := range f.glyphData {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(glyf.Len()))
+		if keep[i] {
+			glyf.Write(data)
+			// Glyphs are aligned to four bytes, as the long loca format recommends.
+			glyf.Write(make([]byte, (4-len(data)%4)%4))
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*len(f.glyphData):], uint32(glyf.Len()))
+
+	head := slices.Clone(f.tables["head"])
+	// The checksum of the whole font is not verified by PDF readers.
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{
+		"glyf": glyf.Bytes(),
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"hmtx": f.tables["hmtx"],
+		"loca": loca,
+		"maxp": f.tables["maxp"],
+	}
+
+	// Hinting programs are kept, since the outlines refer to them.
+	for _, name := range []string{"cvt ", "fpgm", "prep"} {
+		if table, ok := f.tables[name]; ok {
+			tables[name] = table
+		}
+	}
+
+	return writeTrueType(tables), nil
+}
+
+// glyphComponents returns the glyphs a composite glyph is composed of, or none for a simple glyph.
+func glyphComponents(data []byte) ([]int, error) {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+
+	var components []int
+	for pos := 10; ; {
+		if pos+4 > len(data) {
+			return nil, errTrueTypeTruncated
+		}
+
+		flags := binary.BigEndian.Uint16(data[pos:])
+		components = append(components, int(binary.BigEndian.Uint16(data[pos+2:])))
+		pos += 4
+
+		if flags&glyphArgsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+
+		switch {
+		case flags&glyphHaveScale != 0:
+			pos += 2
+		case flags&glyphHaveXYScale != 0:
+			pos += 4
+		case flags&glyphHaveTwoByTwo != 0:
+			pos += 8
+		}
+
+		if flags&glyphMoreComponents == 0 {
+			return components, nil
+		}
+	}
+}
+
+// writeTrueType writes a font file with the tables sorted by tag, as the table directory requires.
+func writeTrueType(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	var font bytes.Buffer
+	_ = binary.Write(&font, binary.BigEndian, uint32(0x00010000))
+
+	// The directory header holds binary search parameters over the table records.
+	selector := bits.Len(uint(len(tags))) - 1
+	searchRange := 16 << selector
+	_ = binary.Write(&font, binary.BigEndian, []uint16{
+		uint16(len(tags)), uint16(searchRange), uint16(selector), uint16(16*len(tags) - searchRange),
+	})
+
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		table := tables[tag]
+		font.WriteString(tag)
+		_ = binary.Write(&font, binary.BigEndian, []uint32{trueTypeChecksum(table), uint32(offset), uint32(len(table))})
+		offset += len(table) + (4-len(table)%4)%4
+	}
+
+	for _, tag := range tags {
+		table := tables[tag]
+		font.Write(table)
+		font.Write(make([]byte, (4-len(table)%4)%4))
+	}
+
+	return font.Bytes()
+}
+
+func trueTypeChecksum(table []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(table); i += 4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+
+	return sum
+}
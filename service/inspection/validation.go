@@ -0,0 +1,213 @@
+package inspection
+
+import (
+	"fmt"
+	"inspection-service/cluster/subscriber"
+	"strings"
+)
+
+// FieldError describes a single invalid field of a request. Field is a path in the request body,
+// for example InspectedDevices[0].InspectedSeals[1].SealID.
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+func (e ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+func (e ValidationError) Code() string {
+	return "invalid_request"
+}
+
+type fieldErrors []FieldError
+
+func (f *fieldErrors) add(field, format string, args ...any) {
+	*f = append(*f, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (f fieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+
+	return ValidationError{Fields: f}
+}
+
+// Validate checks the request against the rules of its Type and verifies that inspected devices and seals belong to the object.
+// All problems are reported at once as ValidationError.
+func (r FinishInspectionRequest) Validate(object subscriber.Object) error {
+	var errs fieldErrors
+
+	switch r.Type {
+	case TypeLimitation:
+		r.validateResolution(&errs, ResolutionLimited, ResolutionStopped)
+		r.validateReasonType(&errs, ReasonTypeNotIntroduced, ReasonTypeConsumerLimited, ReasonTypeInspectorLimited)
+		r.validateMethod(&errs)
+	case TypeResumption:
+		r.validateResolution(&errs, ResolutionResumed)
+		r.validateReasonType(&errs, ReasonTypeNotIntroduced, ReasonTypeConsumerLimited, ReasonTypeInspectorLimited, ReasonTypeResumed)
+		r.validateMethod(&errs)
+	case TypeVerification, TypeUnauthorizedConnection:
+		r.validateResolution(&errs, ResolutionLimited, ResolutionStopped)
+
+		if r.IsUnauthorizedConsumers && isBlank(r.UnauthorizedDescription) {
+			errs.add("UnauthorizedDescription", "is required when IsUnauthorizedConsumers is set")
+		}
+		if r.Type == TypeUnauthorizedConnection && isBlank(r.UnauthorizedExplanation) {
+			errs.add("UnauthorizedExplanation", "is required for unauthorized connection")
+		}
+	default:
+		errs.add("Type", "unknown inspection type %d", r.Type)
+	}
+
+	if r.ReasonType == ReasonTypeNotIntroduced && isBlank(r.ReasonDescription) {
+		errs.add("ReasonDescription", "is required when limitation is not introduced")
+	}
+
+	if r.MethodBy != MethodByConsumer && r.MethodBy != MethodByInspector {
+		errs.add("MethodBy", "unknown method executor %d", r.MethodBy)
+	}
+	if r.EnergyActionAt.IsZero() {
+		errs.add("EnergyActionAt", "is required")
+	}
+
+	r.validateDevices(&errs, object)
+	validateActFormats(&errs, r.ActFormats)
+	validateSignatures(&errs, r.Signatures)
+
+	return errs.err()
+}
+
+func (r RegenerateActRequest) Validate() error {
+	var errs fieldErrors
+
+	if strings.TrimSpace(r.Reason) == "" {
+		errs.add("Reason", "is required")
+	}
+
+	validateActFormats(&errs, r.ActFormats)
+
+	return errs.err()
+}
+
+func validateActFormats(errs *fieldErrors, formats []ActFormat) {
+	seen := make(map[ActFormat]bool, len(formats))
+	for i, format := range formats {
+		field := fmt.Sprintf("ActFormats[%d]", i)
+
+		if err := format.Validate(); err != nil {
+			errs.add(field, "%s", err)
+			continue
+		}
+
+		if seen[format] {
+			errs.add(field, "duplicate act format %q", format)
+		}
+		seen[format] = true
+	}
+}
+
+func (r FinishInspectionRequest) validateResolution(errs *fieldErrors, allowed ...Resolution) {
+	for _, resolution := range allowed {
+		if r.Resolution == resolution {
+			return
+		}
+	}
+
+	errs.add("Resolution", "resolution %d is not allowed for inspection type %d", r.Resolution, r.Type)
+}
+
+func (r FinishInspectionRequest) validateReasonType(errs *fieldErrors, allowed ...ReasonType) {
+	for _, reasonType := range allowed {
+		if r.ReasonType == reasonType {
+			return
+		}
+	}
+
+	errs.add("ReasonType", "reason type %d is not allowed for inspection type %d", r.ReasonType, r.Type)
+}
+
+// validateMethod requires the method of limitation or resumption, which other types do not change.
+func (r FinishInspectionRequest) validateMethod(errs *fieldErrors) {
+	if strings.TrimSpace(r.Method) == "" {
+		errs.add("Method", "is required")
+	}
+}
+
+func (r FinishInspectionRequest) validateDevices(errs *fieldErrors, object subscriber.Object) {
+	if len(r.InspectedDevices) == 0 {
+		errs.add("InspectedDevices", "at least one device is required")
+		return
+	}
+
+	objectDevices := make(map[int]subscriber.Device, len(object.Devices))
+	for _, d := range object.Devices {
+		objectDevices[d.ID] = d
+	}
+
+	seenDevices := make(map[int]struct{}, len(r.InspectedDevices))
+	seenSeals := make(map[int]struct{})
+	for i, d := range r.InspectedDevices {
+		field := fmt.Sprintf("InspectedDevices[%d]", i)
+
+		device, ok := objectDevices[d.DeviceID]
+		if !ok {
+			errs.add(field+".DeviceID", "device %d does not belong to object %d", d.DeviceID, object.ID)
+		}
+		if _, dup := seenDevices[d.DeviceID]; dup {
+			errs.add(field+".DeviceID", "device %d is listed more than once", d.DeviceID)
+		}
+		seenDevices[d.DeviceID] = struct{}{}
+
+		if d.Value.IsNegative() {
+			errs.add(field+".Value", "must not be negative")
+		}
+		if d.Consumption.IsNegative() {
+			errs.add(field+".Consumption", "must not be negative")
+		}
+		if d.IsReplaced && d.IsRollover {
+			errs.add(field+".IsRollover", "cannot be set together with IsReplaced")
+		}
+
+		for j, s := range d.InspectedSeals {
+			sealField := fmt.Sprintf("%s.InspectedSeals[%d].SealID", field, j)
+
+			if ok && !hasSeal(device, s.SealID) {
+				errs.add(sealField, "seal %d does not belong to device %d", s.SealID, d.DeviceID)
+			}
+			if _, dup := seenSeals[s.SealID]; dup {
+				errs.add(sealField, "seal %d is listed more than once", s.SealID)
+			}
+			seenSeals[s.SealID] = struct{}{}
+		}
+	}
+}
+
+func hasSeal(device subscriber.Device, sealID int) bool {
+	for _, s := range device.Seals {
+		if s.ID == sealID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBlank(s *string) bool {
+	return s == nil || strings.TrimSpace(*s) == ""
+}
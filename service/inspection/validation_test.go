@@ -0,0 +1,134 @@
+package inspection
+
+import (
+	"errors"
+	"testing"
+
+	clustersubscriber "inspection-service/cluster/subscriber"
+
+	"github.com/shopspring/decimal"
+)
+
+func newValidationTestObject() clustersubscriber.Object {
+	return clustersubscriber.Object{
+		ID: 5,
+		Devices: []clustersubscriber.Device{
+			{ID: 11, Seals: []clustersubscriber.Seal{{ID: 21, DeviceID: 11}}},
+			{ID: 12, Seals: []clustersubscriber.Seal{{ID: 22, DeviceID: 12}}},
+		},
+	}
+}
+
+func TestFinishInspectionRequestValidateAcceptsValidRequest(t *testing.T) {
+	if err := newFinishTestRequest().Validate(newValidationTestObject()); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+}
+
+func TestFinishInspectionRequestValidateAcceptsVerificationWithoutMethod(t *testing.T) {
+	request := newFinishTestRequest()
+	request.Type = TypeVerification
+	request.Method = ""
+
+	if err := request.Validate(newValidationTestObject()); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+}
+
+func TestFinishInspectionRequestValidateReportsFieldErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *FinishInspectionRequest)
+		fields []string
+	}{
+		{
+			name:   "no devices",
+			modify: func(r *FinishInspectionRequest) { r.InspectedDevices = nil },
+			fields: []string{"InspectedDevices"},
+		},
+		{
+			name:   "unknown type",
+			modify: func(r *FinishInspectionRequest) { r.Type = TypeUnknown },
+			fields: []string{"Type"},
+		},
+		{
+			name: "limitation without reason type",
+			modify: func(r *FinishInspectionRequest) {
+				r.ReasonType = ReasonTypeUnknown
+			},
+			fields: []string{"ReasonType"},
+		},
+		{
+			name: "resumption with limited resolution",
+			modify: func(r *FinishInspectionRequest) {
+				r.Type = TypeResumption
+			},
+			fields: []string{"Resolution"},
+		},
+		{
+			name: "limitation without method",
+			modify: func(r *FinishInspectionRequest) {
+				r.Method = " "
+			},
+			fields: []string{"Method"},
+		},
+		{
+			name: "unauthorized consumers without description",
+			modify: func(r *FinishInspectionRequest) {
+				r.Type = TypeVerification
+				r.IsUnauthorizedConsumers = true
+			},
+			fields: []string{"UnauthorizedDescription"},
+		},
+		{
+			name: "foreign device and seal",
+			modify: func(r *FinishInspectionRequest) {
+				r.InspectedDevices = []InspectedDeviceRequest{
+					{DeviceID: 99},
+					{DeviceID: 12, InspectedSeals: []InspectedSealRequest{{SealID: 21}}},
+				}
+			},
+			fields: []string{"InspectedDevices[0].DeviceID", "InspectedDevices[1].InspectedSeals[0].SealID"},
+		},
+		{
+			name: "negative value",
+			modify: func(r *FinishInspectionRequest) {
+				r.InspectedDevices[0].Value = decimal.NewFromInt(-1)
+			},
+			fields: []string{"InspectedDevices[0].Value"},
+		},
+		{
+			name: "unknown and duplicate act formats",
+			modify: func(r *FinishInspectionRequest) {
+				r.ActFormats = []ActFormat{ActFormatPDF, "odt", ActFormatPDF}
+			},
+			fields: []string{"ActFormats[1]", "ActFormats[2]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newFinishTestRequest()
+			tt.modify(&request)
+
+			err := request.Validate(newValidationTestObject())
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("Validate error = %v, want ErrValidation", err)
+			}
+
+			var validationErr ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate error = %v, want ValidationError", err)
+			}
+
+			if len(validationErr.Fields) != len(tt.fields) {
+				t.Fatalf("fields = %+v, want %v", validationErr.Fields, tt.fields)
+			}
+			for i, field := range tt.fields {
+				if validationErr.Fields[i].Field != field {
+					t.Fatalf("fields[%d] = %q, want %q", i, validationErr.Fields[i].Field, field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package inspection
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"inspection-service/cluster/file"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/pagination"
+)
+
+// maxVerifiedActSize limits the size of a document checked against the issued acts.
+const maxVerifiedActSize = 20 << 20
+
+// VerifyActRequest is a document to check against the issued acts: either an uploaded file or the ID of a file
+// in the file service.
+type VerifyActRequest struct {
+	FileID     int
+	FileHeader *multipart.FileHeader
+}
+
+// ActVerification reports the issued acts that match a document. Matched is false when the document is not an act
+// issued by the service, or was changed after it was issued. Unverifiable is set for a stored act issued before
+// digests were recorded: the act is reported, but its file cannot be checked.
+type ActVerification struct {
+	Matched      bool          `json:"Matched"`
+	Unverifiable bool          `json:"Unverifiable,omitempty"`
+	SHA256       string        `json:"SHA256,omitempty"`
+	Acts         []VerifiedAct `json:"Acts"`
+}
+
+// VerifiedAct is an issued act that matches a document. SupersededAt is set when the act was replaced
+// by a regenerated one. SignatureValid is nil when the act was issued without a signature or the service does
+// not sign acts.
+type VerifiedAct struct {
+	InspectionID   int            `json:"InspectionID"`
+	AttachmentID   int            `json:"AttachmentID"`
+	FileID         int            `json:"FileID"`
+	Type           AttachmentType `json:"Type"`
+	IssuedAt       time.Time      `json:"IssuedAt"`
+	SupersededAt   *time.Time     `json:"SupersededAt,omitempty"`
+	Reason         *string        `json:"Reason,omitempty"`
+	SignatureValid *bool          `json:"SignatureValid,omitempty"`
+}
+
+// ActSigningKey is the public key that checks the signatures of acts issued by the service.
+type ActSigningKey struct {
+	Algorithm string `json:"Algorithm"`
+	PublicKey string `json:"PublicKey"`
+}
+
+func (r VerifyActRequest) Validate() error {
+	var errs fieldErrors
+
+	switch {
+	case r.FileID == 0 && r.FileHeader == nil:
+		errs.add("File", "file or file id is required")
+	case r.FileID != 0 && r.FileHeader != nil:
+		errs.add("File", "must not be given together with file id")
+	case r.FileID < 0:
+		errs.add("FileID", "must be positive")
+	case r.FileHeader != nil && r.FileHeader.Size > maxVerifiedActSize:
+		errs.add("File", "must not exceed %d bytes", maxVerifiedActSize)
+	}
+
+	return errs.err()
+}
+
+func actDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetActSigningKey returns the base64 public key of act signatures, so a signed act can be checked
+// without the service.
+func (s *Service) GetActSigningKey() (ActSigningKey, error) {
+	if !s.signer.Enabled() {
+		return ActSigningKey{}, ErrActSigningDisabled
+	}
+
+	return ActSigningKey{Algorithm: "Ed25519", PublicKey: s.signer.PublicKey()}, nil
+}
+
+// VerifyAct reports whether a document is an act issued by the service, for which inspection and when.
+// An uploaded document is matched by its SHA-256 and its signature is checked against the stored one;
+// a file in the file service is downloaded and checked against the act attachment of the file.
+func (s *Service) VerifyAct(ctx goctx.Context, request VerifyActRequest) (ActVerification, error) {
+	if err := request.Validate(); err != nil {
+		return ActVerification{}, err
+	}
+
+	if request.FileHeader == nil {
+		return s.verifyActFile(ctx, request.FileID)
+	}
+
+	f, err := request.FileHeader.Open()
+	if err != nil {
+		return ActVerification{}, fmt.Errorf("open document: %w", err)
+	}
+
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxVerifiedActSize))
+	if err != nil {
+		return ActVerification{}, fmt.Errorf("read document: %w", err)
+	}
+
+	digest := actDigest(content)
+
+	attachments, err := s.repository.GetActsBySHA256(ctx, digest)
+	if err != nil {
+		return ActVerification{}, fmt.Errorf("get acts by sha256: %w", err)
+	}
+
+	verification := ActVerification{SHA256: digest, Acts: []VerifiedAct{}}
+	for _, a := range attachments {
+		if !a.Type.IsAct() {
+			continue
+		}
+
+		verification.Acts = append(verification.Acts, s.newSignedVerifiedAct(a, content))
+	}
+
+	verification.Matched = len(verification.Acts) != 0
+
+	return verification, nil
+}
+
+// verifyActFile checks the act stored in the file: the file is downloaded and its SHA-256 is compared with the digest
+// recorded when the act was issued. Acts issued before digests were recorded are reported as unverifiable.
+func (s *Service) verifyActFile(ctx goctx.Context, fileID int) (ActVerification, error) {
+	a, err := s.repository.GetAttachmentByFileID(ctx, fileID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ActVerification{Acts: []VerifiedAct{}}, nil
+	}
+	if err != nil {
+		return ActVerification{}, fmt.Errorf("get attachment by file id: %w", err)
+	}
+
+	if !a.Type.IsAct() {
+		return ActVerification{Acts: []VerifiedAct{}}, nil
+	}
+
+	if len(a.SHA256) == 0 {
+		return ActVerification{Unverifiable: true, Acts: []VerifiedAct{newVerifiedAct(a)}}, nil
+	}
+
+	content, err := s.downloadActFile(ctx, fileID)
+	if err != nil {
+		return ActVerification{}, err
+	}
+
+	verification := ActVerification{SHA256: actDigest(content), Acts: []VerifiedAct{}}
+	if verification.SHA256 == a.SHA256 {
+		verification.Acts = append(verification.Acts, s.newSignedVerifiedAct(a, content))
+	}
+
+	verification.Matched = len(verification.Acts) != 0
+
+	return verification, nil
+}
+
+// downloadActFile returns the content of a file from the file service. Files are fetched by their internal URLs,
+// and content beyond the size of a verified act is not read: such a file matches no act anyway.
+func (s *Service) downloadActFile(ctx goctx.Context, fileID int) ([]byte, error) {
+	files, err := s.fileService.GetByIDs(ctx, []int{fileID}, pagination.Pagination{}, file.ForwardedHeaders{})
+	if err != nil {
+		return nil, fmt.Errorf("get file by id: %w", upstream("file-service", err))
+	}
+	if len(files) == 0 {
+		return nil, NotFoundError{Entity: "file", ID: fileID}
+	}
+
+	body, err := s.fileService.Download(ctx, files[0].URL)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", upstream("file-service", err))
+	}
+
+	defer body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(body, maxVerifiedActSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", upstream("file-service", err))
+	}
+
+	return content, nil
+}
+
+// newSignedVerifiedAct reports the act matched by the content, checking the signature of the act when it has one.
+func (s *Service) newSignedVerifiedAct(a Attachment, content []byte) VerifiedAct {
+	act := newVerifiedAct(a)
+	if len(a.Signature) != 0 && s.signer.Enabled() {
+		valid := s.signer.verify(content, a.Signature)
+		act.SignatureValid = &valid
+	}
+
+	return act
+}
+
+func newVerifiedAct(a Attachment) VerifiedAct {
+	return VerifiedAct{
+		InspectionID: a.InspectionID,
+		AttachmentID: a.ID,
+		FileID:       a.FileID,
+		Type:         a.Type,
+		IssuedAt:     a.CreatedAt,
+		SupersededAt: a.SupersededAt,
+		Reason:       a.Reason,
+	}
+}
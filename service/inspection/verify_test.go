@@ -0,0 +1,154 @@
+package inspection
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	clusterfile "inspection-service/cluster/file"
+
+	"github.com/sunshineOfficial/golib/goctx"
+	"github.com/sunshineOfficial/golib/golog"
+)
+
+// newVerifyTestService finishes the test inspection with a signing service and returns the service
+// with the uploaded act.
+func newVerifyTestService(t *testing.T) (*Service, *repositoryMock, *fileServiceMock) {
+	t.Helper()
+
+	repository := &repositoryMock{
+		inspectionsByID: map[int]Inspection{42: {ID: 42, TaskID: 7, Status: StatusInWork}},
+	}
+	fileService := &fileServiceMock{}
+	service := newFinishTestService(t, repository, fileService)
+
+	var err error
+	service.signer, err = NewActSigner(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatalf("NewActSigner returned error: %v", err)
+	}
+
+	if _, err = service.FinishInspection(goctx.Wrap(context.Background()), golog.NewLogger("test"), newFinishTestRequest(), clusterfile.ForwardedHeaders{}); err != nil {
+		t.Fatalf("FinishInspection returned error: %v", err)
+	}
+
+	if len(fileService.uploaded) != 1 || len(repository.attachments) != 1 {
+		t.Fatalf("uploaded %d files, %d attachments, want 1", len(fileService.uploaded), len(repository.attachments))
+	}
+	if got, want := repository.attachments[0].SHA256, actDigest(fileService.uploaded[0]); got != want {
+		t.Fatalf("attachment SHA256 = %q, want %q", got, want)
+	}
+
+	return service, repository, fileService
+}
+
+func TestVerifyActMatchesIssuedAct(t *testing.T) {
+	service, _, fileService := newVerifyTestService(t)
+	content := fileService.uploaded[0]
+
+	verification, err := service.VerifyAct(goctx.Wrap(context.Background()), VerifyActRequest{
+		FileHeader: newTestFileHeader(t, "act.docx", content),
+	})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+
+	if !verification.Matched || len(verification.Acts) != 1 {
+		t.Fatalf("verification = %+v, want one matched act", verification)
+	}
+
+	act := verification.Acts[0]
+	if act.InspectionID != 42 || act.Type != AttachmentTypeAct || act.FileID != 501 {
+		t.Fatalf("act = %+v, want the docx act of inspection 42", act)
+	}
+	if act.SignatureValid == nil || !*act.SignatureValid {
+		t.Fatalf("SignatureValid = %v, want true", act.SignatureValid)
+	}
+}
+
+func TestVerifyActDoesNotMatchChangedAct(t *testing.T) {
+	service, _, fileService := newVerifyTestService(t)
+	content := fileService.uploaded[0]
+
+	changed := bytes.Clone(content)
+	changed[len(changed)-1] ^= 0xFF
+
+	verification, err := service.VerifyAct(goctx.Wrap(context.Background()), VerifyActRequest{
+		FileHeader: newTestFileHeader(t, "act.docx", changed),
+	})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+
+	if verification.Matched || len(verification.Acts) != 0 {
+		t.Fatalf("verification = %+v, want no match", verification)
+	}
+	if verification.SHA256 != actDigest(changed) {
+		t.Fatalf("SHA256 = %q, want the digest of the document", verification.SHA256)
+	}
+}
+
+func TestVerifyActByFileID(t *testing.T) {
+	service, repository, _ := newVerifyTestService(t)
+	ctx := goctx.Wrap(context.Background())
+
+	verification, err := service.VerifyAct(ctx, VerifyActRequest{FileID: 501})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+	if !verification.Matched || verification.SHA256 != repository.attachments[0].SHA256 || verification.Acts[0].InspectionID != 42 {
+		t.Fatalf("verification = %+v, want the act of inspection 42", verification)
+	}
+	if valid := verification.Acts[0].SignatureValid; valid == nil || !*valid {
+		t.Fatalf("SignatureValid = %v, want true", valid)
+	}
+
+	verification, err = service.VerifyAct(ctx, VerifyActRequest{FileID: 999})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+	if verification.Matched {
+		t.Fatalf("verification = %+v, want no match for an unknown file", verification)
+	}
+
+	_, err = service.VerifyAct(ctx, VerifyActRequest{})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("VerifyAct without a document error = %v, want ErrValidation", err)
+	}
+}
+
+func TestVerifyActByFileIDDetectsChangedFile(t *testing.T) {
+	service, repository, fileService := newVerifyTestService(t)
+
+	stored := fileService.filesByID[501].URL
+	fileService.contentsByURL[stored] = append(bytes.Clone(fileService.uploaded[0]), 0)
+
+	verification, err := service.VerifyAct(goctx.Wrap(context.Background()), VerifyActRequest{FileID: 501})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+
+	if verification.Matched || len(verification.Acts) != 0 {
+		t.Fatalf("verification = %+v, want no match for a changed file", verification)
+	}
+	if verification.SHA256 == repository.attachments[0].SHA256 {
+		t.Fatalf("SHA256 = %q, want the digest of the stored file", verification.SHA256)
+	}
+}
+
+func TestVerifyActByFileIDWithoutDigestIsUnverifiable(t *testing.T) {
+	service, repository, _ := newVerifyTestService(t)
+	repository.attachments[0].SHA256 = ""
+
+	verification, err := service.VerifyAct(goctx.Wrap(context.Background()), VerifyActRequest{FileID: 501})
+	if err != nil {
+		t.Fatalf("VerifyAct returned error: %v", err)
+	}
+
+	if verification.Matched || !verification.Unverifiable || len(verification.Acts) != 1 {
+		t.Fatalf("verification = %+v, want an unverifiable act", verification)
+	}
+}